@@ -17,7 +17,10 @@ import (
 
 	"github.com/markkurossi/iql"
 	"github.com/markkurossi/iql/data"
+	// Register the SQLite data format.
+	_ "github.com/markkurossi/iql/data/sqlite"
 	"github.com/markkurossi/iql/lang"
+	"github.com/markkurossi/iql/types"
 	"github.com/markkurossi/tabulate"
 )
 
@@ -25,9 +28,13 @@ func main() {
 	cpuprofile := flag.String("cpuprofile", "", "write cpu profile to `file`")
 	htmlFilter := flag.String("html", "", "HTML filter")
 	jsonFilter := flag.String("json", "", "JSON filter")
+	xmlFilter := flag.String("xml", "", "XML filter")
 	tableFmt := flag.String("t", "uc", "table formatting style")
+	outFmt := flag.String("f", lang.OutFmtTable, "output format")
 	expr := flag.String("e", "", "code to execute")
 	output := flag.String("o", "", "output file name (default is stdout)")
+	cache := flag.String("cache", "off",
+		"HTTP cache mode: off, on, refresh, or offline")
 	flag.Parse()
 	log.SetFlags(0)
 
@@ -60,7 +67,7 @@ func main() {
 	}
 
 	if len(*expr) > 0 {
-		client := newClient(out, program, *tableFmt)
+		client := newClient(out, program, *tableFmt, *outFmt, *cache)
 		err := client.SetStringArray(lang.SysARGS, flag.Args())
 		if err != nil {
 			log.Fatalf("%s: %s\n", program, err)
@@ -72,6 +79,15 @@ func main() {
 		return
 	}
 
+	if flag.NArg() == 0 && isTerminal(os.Stdin) {
+		client := newClient(out, program, *tableFmt, *outFmt, *cache)
+		err := newTerminalREPL(client).Run()
+		if err != nil {
+			log.Fatalf("%s: %s\n", program, err)
+		}
+		return
+	}
+
 	for _, arg := range flag.Args() {
 		f, err := os.Open(arg)
 		if err != nil {
@@ -94,8 +110,16 @@ func main() {
 			for idx, r := range result {
 				fmt.Printf("%s:%s: nth=%d:\n%v\n", arg, *htmlFilter, idx, r)
 			}
+		} else if len(*xmlFilter) > 0 {
+			result, err := data.XMLFilter(f, *xmlFilter)
+			if err != nil {
+				log.Fatalf("XML filter: %s\n", err)
+			}
+			for idx, r := range result {
+				fmt.Printf("%s:%s: nth=%d:\n%s\n", arg, *xmlFilter, idx+1, r)
+			}
 		} else {
-			client := newClient(out, program, *tableFmt)
+			client := newClient(out, program, *tableFmt, *outFmt, *cache)
 			err = client.Parse(f, arg)
 			if err != nil {
 				log.Fatalf("%s: %s\n", arg, err)
@@ -104,7 +128,9 @@ func main() {
 	}
 }
 
-func newClient(out io.Writer, program, tableFmt string) *iql.Client {
+func newClient(out io.Writer, program, tableFmt, outFmt,
+	cache string) *iql.Client {
+
 	client := iql.NewClient(out)
 	err := client.SetString(lang.SysTableFmt, tableFmt)
 	if err != nil {
@@ -112,5 +138,16 @@ func newClient(out io.Writer, program, tableFmt string) *iql.Client {
 		log.Fatalf("Possible styles are: %s\n",
 			strings.Join(tabulate.StyleNames(), ", "))
 	}
+	err = client.SetString(lang.SysOutFmt, outFmt)
+	if err != nil {
+		log.Printf("%s: %s\n", program, err)
+		log.Fatalf("Possible formats are: %s\n",
+			strings.Join(append([]string{lang.OutFmtTable},
+				types.WriterNames()...), ", "))
+	}
+	err = client.SetString(lang.SysHTTPCache, cache)
+	if err != nil {
+		log.Fatalf("%s: %s\n", program, err)
+	}
 	return client
 }
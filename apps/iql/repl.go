@@ -0,0 +1,209 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/markkurossi/iql"
+	"github.com/markkurossi/iql/lang"
+	"github.com/markkurossi/iql/types"
+)
+
+const (
+	prompt         = "iql> "
+	promptContinue = "...> "
+	historyFile    = ".iql_history"
+)
+
+// repl implements the interactive read-eval-print loop.
+type repl struct {
+	client  *iql.Client
+	in      lineReader
+	out     io.Writer
+	restore func()
+	raw     func()
+}
+
+// newTerminalREPL creates a REPL for the terminal standard input. The
+// terminal is put into raw mode for reading the input lines. If the
+// terminal does not support line editing, the REPL reads plain input
+// lines.
+func newTerminalREPL(client *iql.Client) *repl {
+	r := &repl{
+		client: client,
+		out:    os.Stdout,
+	}
+	restore, raw, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		r.in = newPlainReader(os.Stdin, os.Stdout)
+		return r
+	}
+	r.restore = restore
+	r.raw = raw
+
+	var history string
+	home, err := os.UserHomeDir()
+	if err == nil {
+		history = filepath.Join(home, historyFile)
+	}
+	r.in = newTerminal(os.Stdin, os.Stdout, history, r.complete)
+	return r
+}
+
+// Run runs the REPL until the end of input. Statements can span
+// multiple lines and they are terminated with ';'. The lines starting
+// with '.' are meta-commands. The statements are run in the original
+// terminal mode so that Ctrl-C interrupts them. The original terminal
+// mode is restored when the REPL exits.
+func (r *repl) Run() error {
+	if r.restore != nil {
+		defer r.restore()
+	}
+	var stmt []string
+	for {
+		p := prompt
+		if len(stmt) > 0 {
+			p = promptContinue
+		}
+		line, err := r.in.ReadLine(p)
+		if err != nil {
+			if err == errInterrupt {
+				stmt = nil
+				continue
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		trimmed := strings.TrimSpace(line)
+		if len(stmt) == 0 {
+			if len(trimmed) == 0 {
+				continue
+			}
+			if trimmed[0] == '.' {
+				r.in.AddHistory(trimmed)
+				if r.command(trimmed) {
+					return nil
+				}
+				continue
+			}
+		}
+		stmt = append(stmt, line)
+		if !strings.HasSuffix(trimmed, ";") {
+			continue
+		}
+		input := strings.Join(stmt, "\n")
+		stmt = nil
+
+		r.in.AddHistory(input)
+		if r.restore != nil {
+			r.restore()
+		}
+		err = r.client.Parse(strings.NewReader(input), "stdin")
+		if err != nil {
+			fmt.Fprintf(r.out, "%s\n", err)
+		}
+		if r.raw != nil {
+			r.raw()
+		}
+	}
+}
+
+// command runs the meta-command. The function returns true if the REPL
+// should exit.
+func (r *repl) command(line string) bool {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case ".quit", ".exit":
+		return true
+
+	case ".help":
+		fmt.Fprintln(r.out, `.tables     list tables
+.functions  list functions
+.vars       list variables
+.help       print this help
+.quit       exit iql`)
+
+	case ".tables":
+		for _, name := range r.globals(true) {
+			fmt.Fprintln(r.out, name)
+		}
+
+	case ".functions":
+		for _, f := range lang.Functions() {
+			if f.Impl != nil {
+				fmt.Fprintln(r.out, f.Name)
+			} else {
+				fmt.Fprintf(r.out, "%s\tuser-defined\n", f.Name)
+			}
+		}
+
+	case ".vars":
+		global := r.client.Global()
+		for _, name := range r.globals(false) {
+			b := global.Get(name)
+			fmt.Fprintf(r.out, "%s\t%s\t%s\n", name, b.Type, b.Value)
+		}
+
+	default:
+		fmt.Fprintf(r.out, "unknown command '%s', try .help\n", fields[0])
+	}
+	return false
+}
+
+// globals returns the sorted names of the global tables or variables.
+func (r *repl) globals(tables bool) []string {
+	var names []string
+	for name, b := range r.client.Global().Symbols {
+		if (b.Type == types.Table) == tables {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// complete returns the keywords, function names, and global names
+// that start with the prefix. The completions follow the letter case
+// of the prefix.
+func (r *repl) complete(prefix string) []string {
+	upper := strings.ToUpper(prefix)
+	lower := prefix == strings.ToLower(prefix)
+
+	var candidates []string
+	candidates = append(candidates, lang.Keywords()...)
+	for _, f := range lang.Functions() {
+		candidates = append(candidates, f.Name)
+	}
+	for name := range r.client.Global().Symbols {
+		candidates = append(candidates, name)
+	}
+	sort.Strings(candidates)
+
+	var result []string
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		if !strings.HasPrefix(candidate, upper) || seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+		if lower {
+			candidate = strings.ToLower(candidate)
+		} else {
+			candidate = prefix + candidate[len(upper):]
+		}
+		result = append(result, candidate)
+	}
+	return result
+}
@@ -0,0 +1,79 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/iql"
+	"github.com/markkurossi/iql/lang"
+)
+
+func TestREPL(t *testing.T) {
+	out := new(bytes.Buffer)
+	client := iql.NewClient(out)
+	err := client.SetString(lang.SysOutFmt, "csv")
+	if err != nil {
+		t.Fatalf("SetString failed: %s", err)
+	}
+	input := `
+CREATE TABLE t (id INTEGER);
+INSERT INTO t
+  VALUES (1), (2);
+SELECT id
+FROM t;
+.tables
+.unknown
+`
+	r := &repl{
+		client: client,
+		in:     newPlainReader(strings.NewReader(input), new(bytes.Buffer)),
+		out:    out,
+	}
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	expected := `id
+1
+2
+T
+unknown command '.unknown', try .help
+`
+	if out.String() != expected {
+		t.Errorf("got\n%s\nexpected\n%s", out.String(), expected)
+	}
+}
+
+func TestREPLComplete(t *testing.T) {
+	client := iql.NewClient(new(bytes.Buffer))
+	r := &repl{
+		client: client,
+	}
+	tests := []struct {
+		prefix string
+		result []string
+	}{
+		{"sel", []string{"select"}},
+		{"SEL", []string{"SELECT"}},
+		{"Tabl", []string{"TablE", "TablEFMT"}},
+		{"TERM", []string{"TERMOUT"}},
+		{"hba", []string{"hbar"}},
+	}
+	for _, test := range tests {
+		result := r.complete(test.prefix)
+		if !reflect.DeepEqual(result, test.result) {
+			t.Errorf("complete(%q): got %v, expected %v",
+				test.prefix, result, test.result)
+		}
+	}
+	if commonPrefix([]string{"TABLE", "TABLEFMT"}) != "TABLE" {
+		t.Errorf("commonPrefix failed")
+	}
+}
@@ -0,0 +1,354 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/markkurossi/vt100"
+)
+
+// lineReader reads input lines.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+	AddHistory(line string)
+}
+
+// plainReader implements lineReader over a non-terminal input.
+type plainReader struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newPlainReader(in io.Reader, out io.Writer) *plainReader {
+	return &plainReader{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	line, err := r.in.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			return line, nil
+		}
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (r *plainReader) AddHistory(line string) {
+}
+
+// Control characters.
+const (
+	keyCtrlA     = 0x01
+	keyCtrlB     = 0x02
+	keyCtrlC     = 0x03
+	keyCtrlD     = 0x04
+	keyCtrlE     = 0x05
+	keyCtrlF     = 0x06
+	keyCtrlK     = 0x0b
+	keyCtrlN     = 0x0e
+	keyCtrlP     = 0x10
+	keyCtrlU     = 0x15
+	keyTab       = 0x09
+	keyLF        = 0x0a
+	keyCR        = 0x0d
+	keyEscape    = 0x1b
+	keyBackspace = 0x7f
+	keyCtrlH     = 0x08
+)
+
+var errInterrupt = errors.New("interrupt")
+
+// terminal implements a line editor for terminal input. The editor
+// supports cursor movement, history, and tab completion.
+type terminal struct {
+	in          *bufio.Reader
+	out         io.Writer
+	history     []string
+	historyFile string
+	complete    func(prefix string) []string
+}
+
+// maxHistory specifies the maximum number of history entries.
+const maxHistory = 1000
+
+func newTerminal(in io.Reader, out io.Writer, historyFile string,
+	complete func(prefix string) []string) *terminal {
+
+	t := &terminal{
+		in:          bufio.NewReader(in),
+		out:         out,
+		historyFile: historyFile,
+		complete:    complete,
+	}
+	if len(historyFile) > 0 {
+		data, err := ioutil.ReadFile(historyFile)
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if len(line) > 0 {
+					t.history = append(t.history, line)
+				}
+			}
+		}
+	}
+	return t
+}
+
+// isTerminal tests if the file is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// AddHistory adds the line to the history and to the history file.
+func (t *terminal) AddHistory(line string) {
+	line = strings.Join(strings.Fields(line), " ")
+	if len(line) == 0 {
+		return
+	}
+	if len(t.history) > 0 && t.history[len(t.history)-1] == line {
+		return
+	}
+	t.history = append(t.history, line)
+	if len(t.history) > maxHistory {
+		t.history = t.history[len(t.history)-maxHistory:]
+	}
+	if len(t.historyFile) > 0 {
+		ioutil.WriteFile(t.historyFile,
+			[]byte(strings.Join(t.history, "\n")+"\n"), 0600)
+	}
+}
+
+// ReadLine reads an input line with line editing.
+func (t *terminal) ReadLine(prompt string) (string, error) {
+	e := &editor{
+		t:       t,
+		prompt:  prompt,
+		history: len(t.history),
+	}
+	e.redraw()
+
+	for {
+		r, _, err := t.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case keyCR, keyLF:
+			fmt.Fprint(t.out, "\r\n")
+			return string(e.line), nil
+
+		case keyCtrlC:
+			fmt.Fprint(t.out, "^C\r\n")
+			return "", errInterrupt
+
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				fmt.Fprint(t.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteForward()
+
+		case keyCtrlA:
+			e.pos = 0
+		case keyCtrlE:
+			e.pos = len(e.line)
+		case keyCtrlB:
+			e.left()
+		case keyCtrlF:
+			e.right()
+		case keyCtrlK:
+			e.line = e.line[:e.pos]
+		case keyCtrlU:
+			e.line = e.line[e.pos:]
+			e.pos = 0
+		case keyCtrlP:
+			e.historyMove(-1)
+		case keyCtrlN:
+			e.historyMove(1)
+		case keyBackspace, keyCtrlH:
+			e.deleteBackward()
+		case keyTab:
+			e.completion()
+
+		case keyEscape:
+			if err := e.escape(); err != nil {
+				return "", err
+			}
+
+		default:
+			if unicode.IsPrint(r) {
+				e.insert([]rune{r})
+			}
+		}
+		e.redraw()
+	}
+}
+
+// editor holds the state of the line being edited.
+type editor struct {
+	t       *terminal
+	prompt  string
+	line    []rune
+	pos     int
+	history int
+}
+
+func (e *editor) redraw() {
+	fmt.Fprintf(e.t.out, "\r%s%s\x1b[K", e.prompt, string(e.line))
+	back, _, _ := vt100.DisplayWidth(string(e.line[e.pos:]))
+	if back > 0 {
+		fmt.Fprintf(e.t.out, "\x1b[%dD", back)
+	}
+}
+
+func (e *editor) insert(runes []rune) {
+	line := make([]rune, 0, len(e.line)+len(runes))
+	line = append(line, e.line[:e.pos]...)
+	line = append(line, runes...)
+	line = append(line, e.line[e.pos:]...)
+	e.line = line
+	e.pos += len(runes)
+}
+
+func (e *editor) left() {
+	if e.pos > 0 {
+		e.pos--
+	}
+}
+
+func (e *editor) right() {
+	if e.pos < len(e.line) {
+		e.pos++
+	}
+}
+
+func (e *editor) deleteBackward() {
+	if e.pos > 0 {
+		e.line = append(e.line[:e.pos-1], e.line[e.pos:]...)
+		e.pos--
+	}
+}
+
+func (e *editor) deleteForward() {
+	if e.pos < len(e.line) {
+		e.line = append(e.line[:e.pos], e.line[e.pos+1:]...)
+	}
+}
+
+func (e *editor) historyMove(delta int) {
+	idx := e.history + delta
+	if idx < 0 || idx > len(e.t.history) {
+		return
+	}
+	e.history = idx
+	if idx == len(e.t.history) {
+		e.line = nil
+	} else {
+		e.line = []rune(e.t.history[idx])
+	}
+	e.pos = len(e.line)
+}
+
+// escape processes the ANSI escape sequences for the cursor keys.
+func (e *editor) escape() error {
+	r, _, err := e.t.in.ReadRune()
+	if err != nil {
+		return err
+	}
+	if r != '[' && r != 'O' {
+		return nil
+	}
+	r, _, err = e.t.in.ReadRune()
+	if err != nil {
+		return err
+	}
+	switch r {
+	case 'A':
+		e.historyMove(-1)
+	case 'B':
+		e.historyMove(1)
+	case 'C':
+		e.right()
+	case 'D':
+		e.left()
+	case 'H':
+		e.pos = 0
+	case 'F':
+		e.pos = len(e.line)
+	case '3':
+		r, _, err = e.t.in.ReadRune()
+		if err != nil {
+			return err
+		}
+		if r == '~' {
+			e.deleteForward()
+		}
+	}
+	return nil
+}
+
+// completion completes the word before the cursor. If the word has
+// multiple completions, the common prefix is inserted and the
+// alternatives are listed.
+func (e *editor) completion() {
+	if e.t.complete == nil {
+		return
+	}
+	start := e.pos
+	for start > 0 && isWordRune(e.line[start-1]) {
+		start--
+	}
+	prefix := string(e.line[start:e.pos])
+	if len(prefix) == 0 {
+		return
+	}
+	candidates := e.t.complete(prefix)
+	if len(candidates) == 0 {
+		return
+	}
+	common := commonPrefix(candidates)
+	if len(common) > len(prefix) {
+		e.insert([]rune(common[len(prefix):]))
+		if len(candidates) == 1 {
+			e.insert([]rune{' '})
+		}
+		return
+	}
+	if len(candidates) > 1 {
+		fmt.Fprintf(e.t.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$'
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,20 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import (
+	"errors"
+)
+
+// makeRaw puts the terminal fd into raw mode. The raw mode is not
+// supported on this platform.
+func makeRaw(fd int) (func(), func(), error) {
+	return nil, nil, errors.New("raw terminal mode not supported")
+}
@@ -0,0 +1,56 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// makeRaw puts the terminal fd into raw mode. The function returns a
+// function that restores the original terminal mode, and a function
+// that puts the terminal back into raw mode.
+func makeRaw(fd int) (func(), func(), error) {
+	var state syscall.Termios
+	if err := ioctlTermios(fd, ioctlGetTermios, &state); err != nil {
+		return nil, nil, err
+	}
+	raw := state
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK |
+		syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL |
+		syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON |
+		syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	// The output processing is kept enabled since the meta-commands
+	// print their output in raw mode.
+
+	if err := ioctlTermios(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, nil, err
+	}
+	return func() {
+			ioctlTermios(fd, ioctlSetTermios, &state)
+		}, func() {
+			ioctlTermios(fd, ioctlSetTermios, &raw)
+		}, nil
+}
+
+func ioctlTermios(fd int, req uintptr, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req,
+		uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
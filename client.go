@@ -32,6 +32,11 @@ func NewClient(out io.Writer) *Client {
 	}
 }
 
+// Global returns the client's global scope.
+func (c *Client) Global() *lang.Scope {
+	return c.global
+}
+
 // SetString assigns the string value to the global variable. The
 // global variable must have been declared and its type must be
 // VARCHAR.
@@ -69,6 +74,14 @@ func (c *Client) Parse(input io.Reader, source string) error {
 			}
 			return err
 		}
+		writer := c.SysOutFmt()
+		if writer != nil {
+			err = writer(c, q)
+			if err != nil {
+				return err
+			}
+			continue
+		}
 		tab, err := types.Tabulate(q, c.SysTableFmt())
 		if err != nil {
 			return err
@@ -77,6 +90,16 @@ func (c *Client) Parse(input io.Reader, source string) error {
 	}
 }
 
+// SysOutFmt returns the writer for the output format. The function
+// returns nil for the table output format.
+func (c *Client) SysOutFmt() types.Writer {
+	b := c.global.Get(lang.SysOutFmt)
+	if b == nil {
+		return nil
+	}
+	return types.Writers[b.Value.String()]
+}
+
 // SysTableFmt returns the table formatting style.
 func (c *Client) SysTableFmt() (style tabulate.Style) {
 	style = tabulate.Unicode
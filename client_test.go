@@ -7,7 +7,9 @@
 package iql
 
 import (
+	"bytes"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markkurossi/iql/lang"
@@ -19,4 +21,80 @@ func TestClient(t *testing.T) {
 	if err != nil {
 		t.Errorf("client.SetString(SysTableFmt): %s", err)
 	}
+	err = client.SetString(lang.SysOutFmt, "xml")
+	if err == nil {
+		t.Errorf("client.SetString(SysOutFmt) accepted invalid format")
+	}
+}
+
+var outFmtTests = []struct {
+	format string
+	output string
+}{
+	{
+		format: "csv",
+		output: `Int,Float,Str,Bool,Nil
+1,2.5,"a|b, ""c""",true,
+`,
+	},
+	{
+		format: "json",
+		output: `[
+  {"Int":1,"Float":2.5,"Str":"a|b, \"c\"","Bool":true,"Nil":null}
+]
+`,
+	},
+	{
+		format: "ndjson",
+		output: `{"Int":1,"Float":2.5,"Str":"a|b, \"c\"","Bool":true,"Nil":null}
+`,
+	},
+	{
+		format: "markdown",
+		output: `|Int|Float|Str|Bool|Nil|
+|---:|---:|---|---|---|
+|1|2.5|a\|b, "c"|true||
+`,
+	},
+	{
+		format: "html",
+		output: `<table>
+  <tr>
+    <th>Int</th>
+    <th>Float</th>
+    <th>Str</th>
+    <th>Bool</th>
+    <th>Nil</th>
+  </tr>
+  <tr>
+    <td align="right">1</td>
+    <td align="right">2.5</td>
+    <td>a|b, &#34;c&#34;</td>
+    <td>true</td>
+    <td></td>
+  </tr>
+</table>
+`,
+	},
+}
+
+func TestClientOutFmt(t *testing.T) {
+	for _, test := range outFmtTests {
+		out := new(bytes.Buffer)
+		client := NewClient(out)
+		err := client.SetString(lang.SysOutFmt, test.format)
+		if err != nil {
+			t.Fatalf("client.SetString(SysOutFmt): %s", err)
+		}
+		err = client.Parse(strings.NewReader(`
+SELECT 1 AS Int, 2.5 AS Float, 'a|b, "c"' AS Str, true AS Bool, NULL AS Nil;`),
+			test.format)
+		if err != nil {
+			t.Fatalf("%s: client.Parse: %s", test.format, err)
+		}
+		if out.String() != test.output {
+			t.Errorf("%s: got\n%s\nexpected\n%s", test.format, out.String(),
+				test.output)
+		}
+	}
 }
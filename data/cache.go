@@ -0,0 +1,414 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheMode specifies how the HTTP response cache is used.
+type CacheMode int
+
+// Cache modes.
+const (
+	// CacheOff disables the cache.
+	CacheOff CacheMode = iota
+	// CacheOn uses the fresh cached responses and revalidates the
+	// stale responses.
+	CacheOn
+	// CacheRefresh fetches all responses and updates the cache.
+	CacheRefresh
+	// CacheOffline uses the cached responses without accessing the
+	// network.
+	CacheOffline
+)
+
+var cacheModes = map[CacheMode]string{
+	CacheOn:      "on",
+	CacheOff:     "off",
+	CacheRefresh: "refresh",
+	CacheOffline: "offline",
+}
+
+func (m CacheMode) String() string {
+	name, ok := cacheModes[m]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{CacheMode %d}", m)
+}
+
+// ParseCacheMode parses the cache mode name.
+func ParseCacheMode(name string) (CacheMode, error) {
+	for mode, n := range cacheModes {
+		if strings.EqualFold(name, n) {
+			return mode, nil
+		}
+	}
+	return CacheOff, fmt.Errorf("invalid cache mode: %s", name)
+}
+
+// CacheOptions define the HTTP response cache options. The responses
+// are cached in process memory and in the cache directory. The cached
+// responses are fresh for the lifetime that the response's
+// Cache-Control or Expires headers specify, and for TTL if the
+// response does not specify its lifetime. The cache is disabled by
+// default.
+type CacheOptions struct {
+	Mode CacheMode
+	TTL  time.Duration
+	// Dir specifies the disk cache directory. If empty, the responses
+	// are cached only in memory.
+	Dir string
+}
+
+// Cache size limits. The responses larger than maxCacheEntry are
+// streamed from the network and they are not cached. The memory cache
+// evicts the least recently used responses when its size exceeds
+// maxMemoryCache.
+const (
+	maxCacheEntry  = 8 * 1024 * 1024
+	maxMemoryCache = 64 * 1024 * 1024
+)
+
+// cacheEntry holds a cached response.
+type cacheEntry struct {
+	URL          string
+	ContentType  string
+	Encoding     string
+	ETag         string
+	LastModified string
+	Link         string
+	Expires      time.Time
+	data         []byte
+}
+
+func (e *cacheEntry) reader() io.ReadCloser {
+	return &memory{
+		in: bytes.NewReader(e.data),
+	}
+}
+
+// header returns the cached response headers.
+func (e *cacheEntry) header() http.Header {
+	header := make(http.Header)
+	if len(e.ContentType) > 0 {
+		header.Set("Content-Type", e.ContentType)
+	}
+	if len(e.Encoding) > 0 {
+		header.Set("Content-Encoding", e.Encoding)
+	}
+	if len(e.Link) > 0 {
+		header.Set("Link", e.Link)
+	}
+	return header
+}
+
+// lruCache implements a size limited in-memory cache that evicts the
+// least recently used entries.
+type lruCache struct {
+	sync.Mutex
+	max     int
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type lruItem struct {
+	key   string
+	entry *cacheEntry
+}
+
+func newLRUCache(max int) *lruCache {
+	c := &lruCache{
+		max: max,
+	}
+	c.reset()
+	return c
+}
+
+// reset removes all entries from the cache.
+func (c *lruCache) reset() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.size = 0
+	c.entries = make(map[string]*list.Element)
+	c.lru = list.New()
+}
+
+// get returns the entry for the key or nil if the key is not cached.
+func (c *lruCache) get(key string) *cacheEntry {
+	c.Lock()
+	defer c.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*lruItem).entry
+}
+
+// put stores the entry for the key and evicts the least recently
+// used entries if the cache size exceeds its limit.
+func (c *lruCache) put(key string, entry *cacheEntry) {
+	c.Lock()
+	defer c.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	if len(entry.data) > c.max {
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&lruItem{
+		key:   key,
+		entry: entry,
+	})
+	c.size += len(entry.data)
+	for c.size > c.max {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *lruCache) remove(elem *list.Element) {
+	item := c.lru.Remove(elem).(*lruItem)
+	delete(c.entries, item.key)
+	c.size -= len(item.entry.data)
+}
+
+var memoryCache = newLRUCache(maxMemoryCache)
+
+// cacheKey computes the cache key for the URL. The key depends on
+// the request headers so that the responses are not shared between
+// different credentials.
+func cacheKey(input string, options *HTTPOptions) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", input, options.Auth)
+
+	var keys []string
+	for key := range options.Header {
+		keys = append(keys, http.CanonicalHeaderKey(key))
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(h, "%s: %s\n", key,
+			strings.Join(options.Header.Values(key), ", "))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (o *CacheOptions) path(key, suffix string) string {
+	return filepath.Join(o.Dir, key+suffix)
+}
+
+// get returns the cached response for the key or nil if the response
+// is not cached.
+func (o *CacheOptions) get(key string) *cacheEntry {
+	entry := memoryCache.get(key)
+	if entry != nil {
+		return entry
+	}
+	if len(o.Dir) == 0 {
+		return nil
+	}
+	meta, err := ioutil.ReadFile(o.path(key, ".json"))
+	if err != nil {
+		return nil
+	}
+	entry = new(cacheEntry)
+	if err := json.Unmarshal(meta, entry); err != nil {
+		return nil
+	}
+	entry.data, err = ioutil.ReadFile(o.path(key, ".data"))
+	if err != nil {
+		return nil
+	}
+	memoryCache.put(key, entry)
+
+	return entry
+}
+
+// put stores the response into the cache. The disk cache is best
+// effort and its errors are ignored.
+func (o *CacheOptions) put(key string, entry *cacheEntry, data bool) {
+	memoryCache.put(key, entry)
+
+	if len(o.Dir) == 0 {
+		return
+	}
+	if err := os.MkdirAll(o.Dir, 0700); err != nil {
+		return
+	}
+	if data {
+		if err := writeFile(o.path(key, ".data"), entry.data); err != nil {
+			return
+		}
+	}
+	meta, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	writeFile(o.path(key, ".json"), meta)
+}
+
+// writeFile writes the file through a temporary file so that the
+// readers never see partially written files.
+func writeFile(name string, data []byte) error {
+	tmp := fmt.Sprintf("%s.%d", name, os.Getpid())
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// expires computes the expiration time of the response. The function
+// returns false if the response must not be stored.
+func expires(header http.Header, now time.Time, ttl time.Duration) (
+	time.Time, bool) {
+
+	var noCache bool
+	maxAge := -1
+	directives := strings.Split(header.Get("Cache-Control"), ",")
+	for _, directive := range directives {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store":
+			return now, false
+		case directive == "no-cache":
+			noCache = true
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.Atoi(directive[8:])
+			if err == nil {
+				maxAge = seconds
+			}
+		}
+	}
+	if noCache {
+		return now, true
+	}
+	if maxAge >= 0 {
+		return now.Add(time.Duration(maxAge) * time.Second), true
+	}
+	if value := header.Get("Expires"); len(value) > 0 {
+		t, err := http.ParseTime(value)
+		if err != nil {
+			// Invalid dates mean that the response has already
+			// expired.
+			return now, true
+		}
+		return t, true
+	}
+	return now.Add(ttl), true
+}
+
+// fetch fetches the URL through the response cache. The function
+// returns the response body and headers.
+func fetch(input string, options *Options) (io.ReadCloser, http.Header,
+	error) {
+
+	cache := &options.Cache
+	method := strings.ToUpper(options.HTTP.Method)
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+
+	// Only GET requests are cached.
+	if cache.Mode == CacheOff || method != http.MethodGet {
+		resp, err := httpGet(input, &options.HTTP, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		return resp.Body, resp.Header, nil
+	}
+
+	key := cacheKey(input, &options.HTTP)
+	entry := cache.get(key)
+	now := time.Now()
+
+	if entry != nil {
+		if cache.Mode == CacheOffline ||
+			(cache.Mode == CacheOn && now.Before(entry.Expires)) {
+			return entry.reader(), entry.header(), nil
+		}
+	} else if cache.Mode == CacheOffline {
+		return nil, nil, fmt.Errorf("HTTP URL '%s' not cached", input)
+	}
+
+	// Fetch or revalidate the response.
+	header := make(http.Header)
+	if entry != nil && cache.Mode == CacheOn {
+		if len(entry.ETag) > 0 {
+			header.Set("If-None-Match", entry.ETag)
+		}
+		if len(entry.LastModified) > 0 {
+			header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+	resp, err := httpGet(input, &options.HTTP, header)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		updated := *entry
+		updated.Expires, _ = expires(resp.Header, now, cache.TTL)
+		cache.put(key, &updated, false)
+		return updated.reader(), updated.header(), nil
+	}
+
+	expiration, store := expires(resp.Header, now, cache.TTL)
+	if !store {
+		return resp.Body, resp.Header, nil
+	}
+
+	// The large responses are streamed without caching them.
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCacheEntry+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, nil, err
+	}
+	if len(data) > maxCacheEntry {
+		return &readCloser{
+			Reader: io.MultiReader(bytes.NewReader(data), resp.Body),
+			closer: resp.Body,
+		}, resp.Header, nil
+	}
+	resp.Body.Close()
+
+	entry = &cacheEntry{
+		URL:          input,
+		ContentType:  resp.Header.Get("Content-Type"),
+		Encoding:     resp.Header.Get("Content-Encoding"),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Link:         strings.Join(resp.Header.Values("Link"), ", "),
+		Expires:      expiration,
+		data:         data,
+	}
+	cache.put(key, entry, true)
+
+	return entry.reader(), entry.header(), nil
+}
@@ -0,0 +1,246 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// cacheServer creates a test server that returns CSV data with the
+// header. The count is incremented for each request.
+func cacheServer(header http.Header, count *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			*count++
+			etag := header.Get("ETag")
+			if len(etag) > 0 && r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			csvHandler(w)
+		}))
+}
+
+func fetchRows(t *testing.T, url string, options *Options) int {
+	source, err := NewWithOptions([]string{url}, "", nil, options)
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %s", err)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	return len(rows)
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	var count int
+	server := cacheServer(http.Header{
+		"Cache-Control": {"max-age=60"},
+	}, &count)
+	defer server.Close()
+
+	options := &Options{
+		Cache: CacheOptions{
+			Mode: CacheOn,
+			Dir:  t.TempDir(),
+		},
+	}
+	for i := 0; i < 3; i++ {
+		if n := fetchRows(t, server.URL, options); n != 2 {
+			t.Errorf("got %d rows, expected 2", n)
+		}
+	}
+	if count != 1 {
+		t.Errorf("got %d requests, expected 1", count)
+	}
+
+	options.Cache.Mode = CacheRefresh
+	fetchRows(t, server.URL, options)
+	if count != 2 {
+		t.Errorf("refresh: got %d requests, expected 2", count)
+	}
+
+	options.Cache.Mode = CacheOff
+	fetchRows(t, server.URL, options)
+	if count != 3 {
+		t.Errorf("off: got %d requests, expected 3", count)
+	}
+}
+
+func TestCacheRevalidate(t *testing.T) {
+	var count int
+	server := cacheServer(http.Header{
+		"Cache-Control": {"no-cache"},
+		"Etag":          {`"v1"`},
+	}, &count)
+	defer server.Close()
+
+	dir := t.TempDir()
+	options := &Options{
+		Cache: CacheOptions{
+			Mode: CacheOn,
+			Dir:  dir,
+			TTL:  time.Hour,
+		},
+	}
+	for i := 0; i < 2; i++ {
+		if n := fetchRows(t, server.URL, options); n != 2 {
+			t.Errorf("got %d rows, expected 2", n)
+		}
+	}
+	if count != 2 {
+		t.Errorf("got %d requests, expected 2", count)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.data"))
+	if err != nil || len(files) != 1 {
+		t.Errorf("response not cached on disk: %v", files)
+	}
+}
+
+func TestCacheOffline(t *testing.T) {
+	var count int
+	server := cacheServer(http.Header{
+		"Last-Modified": {"Mon, 01 Mar 2021 10:00:00 GMT"},
+	}, &count)
+	url := server.URL
+
+	options := &Options{
+		Cache: CacheOptions{
+			Mode: CacheOn,
+			Dir:  t.TempDir(),
+		},
+	}
+	fetchRows(t, url, options)
+	server.Close()
+
+	// Read the response from the disk cache.
+	memoryCache.reset()
+
+	options.Cache.Mode = CacheOffline
+	if n := fetchRows(t, url, options); n != 2 {
+		t.Errorf("got %d rows, expected 2", n)
+	}
+
+	_, err := NewWithOptions([]string{url + "/missing.csv"}, "", nil,
+		options)
+	if err == nil {
+		t.Errorf("offline fetch of uncached URL succeeded")
+	}
+}
+
+func TestCacheNoStore(t *testing.T) {
+	var count int
+	server := cacheServer(http.Header{
+		"Cache-Control": {"no-store, max-age=60"},
+	}, &count)
+	defer server.Close()
+
+	options := &Options{
+		Cache: CacheOptions{
+			Mode: CacheOn,
+			TTL:  time.Hour,
+		},
+	}
+	fetchRows(t, server.URL, options)
+	fetchRows(t, server.URL, options)
+	if count != 2 {
+		t.Errorf("got %d requests, expected 2", count)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	a := cacheKey("http://example.com/", &HTTPOptions{
+		Auth: "Bearer a",
+	})
+	b := cacheKey("http://example.com/", &HTTPOptions{
+		Auth: "Bearer b",
+	})
+	if a == b {
+		t.Errorf("credentials do not affect cache key")
+	}
+}
+
+func TestCacheDefaultOff(t *testing.T) {
+	var count int
+	server := cacheServer(http.Header{
+		"Cache-Control": {"max-age=60"},
+	}, &count)
+	defer server.Close()
+
+	fetchRows(t, server.URL, &Options{})
+	fetchRows(t, server.URL, &Options{})
+	if count != 2 {
+		t.Errorf("got %d requests, expected 2", count)
+	}
+}
+
+func TestCacheLarge(t *testing.T) {
+	var count int
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			count++
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Write([]byte("Name,Value\na,"))
+			w.Write(bytes.Repeat([]byte("x"), maxCacheEntry))
+			w.Write([]byte("\n"))
+		}))
+	defer server.Close()
+
+	options := &Options{
+		Cache: CacheOptions{
+			Mode: CacheOn,
+		},
+	}
+	for i := 0; i < 2; i++ {
+		if n := fetchRows(t, server.URL, options); n != 1 {
+			t.Errorf("got %d rows, expected 1", n)
+		}
+	}
+	if count != 2 {
+		t.Errorf("got %d requests, expected 2", count)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := newLRUCache(10)
+	for _, key := range []string{"a", "b", "c"} {
+		c.put(key, &cacheEntry{
+			data: []byte("data"),
+		})
+	}
+	if c.get("a") != nil {
+		t.Errorf("least recently used entry not evicted")
+	}
+	if c.get("b") == nil || c.get("c") == nil {
+		t.Errorf("recent entries evicted")
+	}
+	c.put("d", &cacheEntry{
+		data: []byte("data"),
+	})
+	if c.get("b") != nil || c.get("c") == nil || c.get("d") == nil {
+		t.Errorf("invalid eviction order")
+	}
+	if c.size != 8 {
+		t.Errorf("got size %d, expected 8", c.size)
+	}
+	c.put("e", &cacheEntry{
+		data: make([]byte, 11),
+	})
+	if c.get("e") != nil {
+		t.Errorf("too large entry cached")
+	}
+}
@@ -0,0 +1,183 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"fmt"
+
+	"github.com/markkurossi/iql/types"
+)
+
+// batchReader reads the rows of columnar inputs in batches.
+type batchReader interface {
+	// next reads the next batch of rows. The reader can skip batches
+	// whose rows can't match the predicates. The function returns
+	// io.EOF when all batches have been read.
+	next(predicates []types.Predicate) ([]types.Row, error)
+
+	// close closes the reader inputs.
+	close()
+}
+
+// columnar implements a data source from columnar data formats. The
+// column types are resolved from the input schema and the rows are
+// read lazily in batches.
+type columnar struct {
+	name         string
+	columns      []types.ColumnSelector
+	reader       batchReader
+	predicates   []types.Predicate
+	rows         []types.Row
+	materialized bool
+	consumed     bool
+}
+
+// Filter implements the Filterable.Filter(). The predicates are
+// evaluated for the rows as they are read from the input. The
+// batches whose rows can't match the predicates are skipped.
+func (src *columnar) Filter(pred types.Predicate) bool {
+	if src.consumed || pred.Column < 0 || pred.Column >= len(src.columns) {
+		return false
+	}
+	src.predicates = append(src.predicates, pred)
+	return true
+}
+
+// Columns implements the Source.Columns().
+func (src *columnar) Columns() []types.ColumnSelector {
+	return src.columns
+}
+
+// Get implements the Source.Get().
+func (src *columnar) Get() ([]types.Row, error) {
+	if src.materialized {
+		return src.rows, nil
+	}
+	it, err := src.Rows()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := types.ReadAll(it)
+	if err != nil {
+		return nil, err
+	}
+	src.rows = rows
+	src.materialized = true
+	return src.rows, nil
+}
+
+// Rows implements the Source.Rows().
+func (src *columnar) Rows() (types.RowIterator, error) {
+	if src.materialized {
+		return types.NewRowsIterator(src.rows), nil
+	}
+	if src.consumed {
+		return nil, fmt.Errorf("%s: input already consumed", src.name)
+	}
+	src.consumed = true
+	return &columnarIterator{
+		source: src,
+	}, nil
+}
+
+// match tests if the row matches the source's predicates.
+func (src *columnar) match(row types.Row) (bool, error) {
+	for _, pred := range src.predicates {
+		match, err := pred.Match(row[pred.Column],
+			src.columns[pred.Column].Type)
+		if err != nil {
+			return false, fmt.Errorf("%s: %s", src.name, err)
+		}
+		if !match {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// columnarIterator iterates the rows of the columnar source batches.
+type columnarIterator struct {
+	source *columnar
+	batch  []types.Row
+}
+
+// Next implements the RowIterator.Next().
+func (it *columnarIterator) Next() (types.Row, error) {
+	for {
+		for len(it.batch) > 0 {
+			row := it.batch[0]
+			it.batch = it.batch[1:]
+			match, err := it.source.match(row)
+			if err != nil {
+				return nil, err
+			}
+			if match {
+				return row, nil
+			}
+		}
+		batch, err := it.source.reader.next(it.source.predicates)
+		if err != nil {
+			return nil, err
+		}
+		it.batch = batch
+	}
+}
+
+// Close implements the RowIterator.Close().
+func (it *columnarIterator) Close() error {
+	it.batch = nil
+	it.source.reader.close()
+	return nil
+}
+
+// mayMatch tests if the values between min and max can match the
+// predicate. The function is used to skip batches whose rows can't
+// match the predicate. If the batch has null values, the hasNull must
+// be true.
+func mayMatch(pred types.Predicate, min, max types.Value, t types.Type,
+	hasNull bool) bool {
+
+	if pred.Value == types.Null || min == nil || max == nil {
+		return true
+	}
+	// test tests if the value compares to the predicate value with
+	// the operator. The ok is false if the values can't be compared.
+	test := func(op types.Op, val types.Value) (match, ok bool) {
+		p := types.Predicate{
+			Op:    op,
+			Value: pred.Value,
+		}
+		match, err := p.Match(types.NewValueColumn(val), t)
+		return match, err == nil
+	}
+	possible := func(op types.Op, val types.Value) bool {
+		match, ok := test(op, val)
+		return match || !ok
+	}
+
+	switch pred.Op {
+	case types.OpEq:
+		return possible(types.OpLe, min) && possible(types.OpGe, max)
+	case types.OpNEq:
+		if hasNull {
+			return true
+		}
+		minEq, minOk := test(types.OpEq, min)
+		maxEq, maxOk := test(types.OpEq, max)
+		return !(minEq && minOk && maxEq && maxOk)
+	case types.OpLt:
+		return possible(types.OpLt, min)
+	case types.OpLe:
+		return possible(types.OpLe, min)
+	case types.OpGt:
+		return possible(types.OpGt, max)
+	case types.OpGe:
+		return possible(types.OpGe, max)
+	default:
+		return true
+	}
+}
@@ -0,0 +1,412 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+// Compression specifies input compression.
+type Compression int
+
+// Known input compressions.
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionBzip2
+	CompressionXZ
+	CompressionZip
+)
+
+var compressionSuffixes = map[string]Compression{
+	".gz":  CompressionGzip,
+	".bz2": CompressionBzip2,
+	".xz":  CompressionXZ,
+	".zip": CompressionZip,
+}
+
+var contentEncodings = map[string]Compression{
+	"identity": CompressionNone,
+	"gzip":     CompressionGzip,
+	"x-gzip":   CompressionGzip,
+	"bzip2":    CompressionBzip2,
+	"xz":       CompressionXZ,
+}
+
+var compressionMagics = []struct {
+	magic       []byte
+	compression Compression
+}{
+	{[]byte{0x1f, 0x8b}, CompressionGzip},
+	{[]byte("BZh"), CompressionBzip2},
+	{[]byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, CompressionXZ},
+	{[]byte("PK\x03\x04"), CompressionZip},
+}
+
+var compressionNames = map[Compression]string{
+	CompressionNone:  "none",
+	CompressionGzip:  "gzip",
+	CompressionBzip2: "bzip2",
+	CompressionXZ:    "xz",
+	CompressionZip:   "zip",
+}
+
+func (c Compression) String() string {
+	name, ok := compressionNames[c]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{Compression %d}", c)
+}
+
+// splitCompression removes the compression suffix from the path. The
+// function returns the path without the compression suffix and the
+// compression.
+func splitCompression(p string) (string, Compression) {
+	idx := strings.LastIndexByte(p, '.')
+	if idx < 0 {
+		return p, CompressionNone
+	}
+	c, ok := compressionSuffixes[strings.ToLower(p[idx:])]
+	if !ok {
+		return p, CompressionNone
+	}
+	return p[:idx], c
+}
+
+// splitMembers splits the zip archive member glob from the input
+// URL. The member glob follows the '#' character after the archive
+// name.
+func splitMembers(input string) (string, string) {
+	idx := strings.LastIndexByte(input, '#')
+	if idx < 0 {
+		return input, ""
+	}
+	archive := input[:idx]
+	_, c := splitCompression(archive)
+	if c != CompressionZip {
+		return input, ""
+	}
+	return archive, input[idx+1:]
+}
+
+// parseContentEncoding parses the HTTP Content-Encoding header value.
+func parseContentEncoding(value string) (Compression, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if len(value) == 0 {
+		return CompressionNone, nil
+	}
+	c, ok := contentEncodings[value]
+	if !ok {
+		return CompressionNone,
+			fmt.Errorf("unsupported Content-Encoding: %s", value)
+	}
+	return c, nil
+}
+
+// readCloser combines a reader with the closer of its underlying
+// input.
+type readCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (rc *readCloser) Close() error {
+	return rc.closer.Close()
+}
+
+// sniffCompression detects the compression from the magic bytes of
+// the input. The zip archives are detected only if zip is true. The
+// function returns the input for reading and the compression.
+func sniffCompression(in io.ReadCloser, zip bool) (io.ReadCloser,
+	Compression, error) {
+
+	var magic []byte
+	var result io.ReadCloser
+
+	if f, ok := in.(*os.File); ok && isRegular(f) {
+		// Regular files are sniffed without consuming them so that
+		// the data sources can access the files directly.
+		var buf [6]byte
+		n, err := f.ReadAt(buf[:], 0)
+		if err != nil && err != io.EOF {
+			return nil, CompressionNone, err
+		}
+		magic = buf[:n]
+		result = f
+	} else {
+		r := bufio.NewReader(in)
+		var err error
+		magic, err = r.Peek(6)
+		if err != nil && err != io.EOF {
+			return nil, CompressionNone, err
+		}
+		result = &readCloser{
+			Reader: r,
+			closer: in,
+		}
+	}
+	for _, m := range compressionMagics {
+		if m.compression == CompressionZip && !zip {
+			continue
+		}
+		if bytes.HasPrefix(magic, m.magic) {
+			return result, m.compression, nil
+		}
+	}
+	return result, CompressionNone, nil
+}
+
+// decompress wraps the input with the decompressor of the
+// compression.
+func decompress(in io.ReadCloser, c Compression) (io.ReadCloser, error) {
+	var r io.Reader
+	var err error
+
+	switch c {
+	case CompressionNone:
+		return in, nil
+	case CompressionGzip:
+		r, err = gzip.NewReader(in)
+	case CompressionBzip2:
+		r = bzip2.NewReader(in)
+	case CompressionXZ:
+		r, err = xz.NewReader(in)
+	default:
+		err = fmt.Errorf("unsupported compression: %s", c)
+	}
+	if err != nil {
+		in.Close()
+		return nil, fmt.Errorf("%s: %s", c, err)
+	}
+	return &readCloser{
+		Reader: r,
+		closer: in,
+	}, nil
+}
+
+// zipMember holds an opened zip archive member.
+type zipMember struct {
+	name  string
+	input io.ReadCloser
+}
+
+// openZip opens the members of the zip archive that match the glob
+// pattern. If the pattern is empty, all members are opened. The
+// archive is closed when all its members are closed.
+func openZip(in io.ReadCloser, pattern string) ([]zipMember, error) {
+	archive, ac, err := readZip(in)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*zip.File
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if len(pattern) > 0 {
+			match, err := path.Match(pattern, file.Name)
+			if err != nil {
+				ac.Close()
+				return nil, fmt.Errorf("zip: %s: %s", err, pattern)
+			}
+			if !match {
+				continue
+			}
+		}
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		ac.Close()
+		if len(pattern) > 0 {
+			return nil, fmt.Errorf("zip: no members match '%s'", pattern)
+		}
+		return nil, fmt.Errorf("zip: empty archive")
+	}
+
+	closer := &refCloser{
+		closer: ac,
+		count:  len(files),
+	}
+	var result []zipMember
+	for idx, file := range files {
+		r, err := file.Open()
+		if err != nil {
+			for _, m := range result {
+				m.input.Close()
+			}
+			for i := idx; i < len(files); i++ {
+				closer.Close()
+			}
+			return nil, fmt.Errorf("zip: %s: %s", file.Name, err)
+		}
+		result = append(result, zipMember{
+			name: file.Name,
+			input: &readCloser{
+				Reader: r,
+				closer: closer,
+			},
+		})
+	}
+	return result, nil
+}
+
+// readZip opens the zip archive from the input. The function returns
+// the archive and the closer of its input. The input is closed on
+// errors.
+func readZip(in io.ReadCloser) (*zip.Reader, io.Closer, error) {
+	readerAt, size, closer, err := openReaderAt(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	archive, err := zip.NewReader(readerAt, size)
+	if err != nil {
+		closer.Close()
+		return nil, nil, fmt.Errorf("zip: %s", err)
+	}
+	return archive, closer, nil
+}
+
+// openReaderAt returns a random access reader for the input. Files
+// are read in place and other inputs are read into memory. The
+// function returns the reader, the input size, and the closer of the
+// input. The input is closed on errors.
+func openReaderAt(in io.ReadCloser) (io.ReaderAt, int64, io.Closer, error) {
+	f, ok := in.(*os.File)
+	if ok {
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, nil, err
+		}
+		return f, fi.Size(), f, nil
+	}
+	data, err := ioutil.ReadAll(in)
+	in.Close()
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return bytes.NewReader(data), int64(len(data)), ioutil.NopCloser(nil), nil
+}
+
+// refCloser closes its closer when it has been closed count times.
+type refCloser struct {
+	closer io.Closer
+	count  int
+}
+
+func (rc *refCloser) Close() error {
+	rc.count--
+	if rc.count == 0 {
+		return rc.closer.Close()
+	}
+	return nil
+}
+
+// input holds a decompressed input and its format. The err holds
+// the format resolution error if the format could not be resolved
+// from the input meta data.
+type input struct {
+	in     io.ReadCloser
+	format Format
+	err    error
+}
+
+// decompressInputs decompresses the inputs and resolves their
+// format. If the compression is CompressionNone, the compression is
+// detected from the magic bytes of the inputs. The format of the zip
+// archive members is resolved from their names.
+func decompressInputs(inputs []io.ReadCloser, c Compression, members string,
+	resolver Resolver) ([]input, error) {
+
+	var result []input
+
+	fail := func(idx int, err error) ([]input, error) {
+		for _, r := range result {
+			r.in.Close()
+		}
+		for ; idx < len(inputs); idx++ {
+			inputs[idx].Close()
+		}
+		return nil, err
+	}
+
+	for idx, in := range inputs {
+		compression := c
+		if compression == CompressionNone {
+			// Zip archives are detected only if the input format is
+			// unknown since some data formats are zip archives.
+			_, err := resolver.Format()
+			var sniffed io.ReadCloser
+			sniffed, compression, err = sniffCompression(in, err != nil)
+			if err != nil {
+				return fail(idx, err)
+			}
+			inputs[idx] = sniffed
+			in = sniffed
+		}
+		if compression != CompressionZip {
+			r, err := decompress(in, compression)
+			if err != nil {
+				return fail(idx+1, err)
+			}
+			f, err := resolver.Format()
+			result = append(result, input{
+				in:     r,
+				format: f,
+				err:    err,
+			})
+			continue
+		}
+
+		zipMembers, err := openZip(in, members)
+		if err != nil {
+			return fail(idx+1, err)
+		}
+		for i, m := range zipMembers {
+			var r Resolver
+			name, mc := splitCompression(m.name)
+			r.ResolvePath(name)
+			m.input, err = decompress(m.input, mc)
+			if err != nil {
+				for _, rest := range zipMembers[i+1:] {
+					rest.input.Close()
+				}
+				return fail(idx+1, fmt.Errorf("zip: %s: %s", m.name, err))
+			}
+			f, err := r.Format()
+			if err != nil {
+				err = fmt.Errorf("zip: %s: %s", m.name, err)
+			}
+			result = append(result, input{
+				in:     m.input,
+				format: f,
+				err:    err,
+			})
+		}
+	}
+	return result, nil
+}
+
+// isRegular tests if the file is a regular file.
+func isRegular(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode().IsRegular()
+}
@@ -0,0 +1,149 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/markkurossi/iql/types"
+	"github.com/ulikunitz/xz"
+)
+
+var compressCSV = "Name,Value\na,1\nb,2\n"
+
+func gzipData(t *testing.T, data string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(data))
+	if err := w.Close(); err != nil {
+		t.Fatalf("gzip failed: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func xzData(t *testing.T, data string) []byte {
+	var buf bytes.Buffer
+	w, err := xz.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("xz failed: %s", err)
+	}
+	w.Write([]byte(data))
+	if err := w.Close(); err != nil {
+		t.Fatalf("xz failed: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	file := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", file, err)
+	}
+	return file
+}
+
+func countRows(t *testing.T, url string, columns []types.ColumnSelector) int {
+	source, err := New([]string{url}, "", columns)
+	if err != nil {
+		t.Fatalf("New(%s) failed: %s", url, err)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("Get(%s) failed: %s", url, err)
+	}
+	return len(rows)
+}
+
+func TestCompressSuffix(t *testing.T) {
+	tests := map[string]string{
+		"data.csv.gz": writeTestFile(t, "data.csv.gz",
+			gzipData(t, compressCSV)),
+		"data.csv.xz": writeTestFile(t, "data.csv.xz",
+			xzData(t, compressCSV)),
+		"test.json.bz2": "test.json.bz2",
+	}
+	for name, file := range tests {
+		var columns []types.ColumnSelector
+		if name == "test.json.bz2" {
+			columns = xmlColumns("id", "name")
+		}
+		if n := countRows(t, file, columns); n != 2 {
+			t.Errorf("%s: got %d rows, expected 2", name, n)
+		}
+	}
+}
+
+func TestCompressMagic(t *testing.T) {
+	file := writeTestFile(t, "data.csv", gzipData(t, compressCSV))
+	if n := countRows(t, file, nil); n != 2 {
+		t.Errorf("got %d rows, expected 2", n)
+	}
+}
+
+func TestCompressContentEncoding(t *testing.T) {
+	data := xzData(t, compressCSV)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Encoding", "xz")
+			w.Write(data)
+		}))
+	defer server.Close()
+
+	if n := countRows(t, server.URL, nil); n != 2 {
+		t.Errorf("got %d rows, expected 2", n)
+	}
+}
+
+func TestCompressZip(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	members := map[string][]byte{
+		"2020/a.csv":    []byte(compressCSV),
+		"2021/b.csv.gz": gzipData(t, compressCSV),
+		"2021/c.csv":    []byte(compressCSV),
+		"README.txt":    []byte("data files"),
+	}
+	for _, name := range []string{
+		"2020/a.csv", "2021/b.csv.gz", "2021/c.csv", "README.txt",
+	} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("zip failed: %s", err)
+		}
+		if _, err := io.Copy(f, bytes.NewReader(members[name])); err != nil {
+			t.Fatalf("zip failed: %s", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("zip failed: %s", err)
+	}
+	file := writeTestFile(t, "data.zip", buf.Bytes())
+
+	if n := countRows(t, file+"#*/*.csv*", nil); n != 6 {
+		t.Errorf("got %d rows, expected 6", n)
+	}
+	if n := countRows(t, file+"#2021/c.csv", nil); n != 2 {
+		t.Errorf("got %d rows, expected 2", n)
+	}
+	_, err := New([]string{file + "#2022/*"}, "", nil)
+	if err == nil {
+		t.Errorf("unmatched member glob accepted")
+	}
+	_, err = New([]string{file}, "", nil)
+	if err == nil {
+		t.Errorf("unknown member format accepted")
+	}
+}
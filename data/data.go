@@ -11,7 +11,6 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -22,8 +21,16 @@ import (
 )
 
 var (
-	_ types.Source = &CSV{}
-	_ types.Source = &HTML{}
+	_ types.Source     = &CSV{}
+	_ types.Source     = &HTML{}
+	_ types.Source     = &JSON{}
+	_ types.Source     = &NDJSON{}
+	_ types.Source     = &XML{}
+	_ types.Source     = &XLSX{}
+	_ types.Source     = &columnar{}
+	_ types.Filterable = &CSV{}
+	_ types.Filterable = &NDJSON{}
+	_ types.Filterable = &columnar{}
 )
 
 // NewSource defines a constructor for data sources.
@@ -33,7 +40,18 @@ type NewSource func(in []io.ReadCloser, filter string,
 // New creates a new data source for the URL.
 func New(urls []string, filter string, columns []types.ColumnSelector) (
 	types.Source, error) {
+	return NewWithOptions(urls, filter, columns, nil)
+}
+
+// NewWithOptions creates a new data source for the URL. The options
+// specify how the URLs are accessed. If the options are nil, the
+// default options are used.
+func NewWithOptions(urls []string, filter string,
+	columns []types.ColumnSelector, options *Options) (types.Source, error) {
 
+	if options == nil {
+		options = new(Options)
+	}
 	if len(urls) == 0 {
 		return nil, fmt.Errorf("empty URL list")
 	}
@@ -42,53 +60,83 @@ func New(urls []string, filter string, columns []types.ColumnSelector) (
 	var format Format
 
 	for idx, url := range urls {
-		input, f, err := openInput(url)
+		input, f, ft, err := openInput(url, filter, options)
 		if err != nil {
+			for _, in := range inputs {
+				in.Close()
+			}
 			return nil, err
 		}
 		if idx > 0 && format != f {
+			for _, in := range append(inputs, input...) {
+				in.Close()
+			}
 			return nil, fmt.Errorf("mixed source formats: %s, %s", format, f)
 		}
+		filter = ft
 		format = f
 		inputs = append(inputs, input...)
 	}
 
+	registry.RLock()
 	n, ok := formats[format]
+	registry.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("unknown data format '%s'", format)
 	}
 	return n(inputs, filter, columns)
 }
 
-func openInput(input string) ([]io.ReadCloser, Format, error) {
+// openInput opens the input URL. The function returns the inputs,
+// their format, and the filter for the format.
+func openInput(input, filter string, options *Options) ([]io.ReadCloser,
+	Format, string, error) {
+
 	var resolver Resolver
+	var inputs []io.ReadCloser
+
+	input, members := splitMembers(input)
 
 	u, err := url.Parse(input)
-	if err != nil {
-		resolver.ResolvePath(input)
-	} else {
-		resolver.ResolvePath(u.Path)
+	p := input
+	if err == nil {
+		p = u.Path
 	}
+	p, compression := splitCompression(p)
+	resolver.ResolvePath(p)
+
 	if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
-		resp, err := http.Get(input)
-		if err != nil {
-			return nil, 0, err
-		}
-		if resp.StatusCode != http.StatusOK {
-			io.Copy(ioutil.Discard, resp.Body)
-			resp.Body.Close()
-			return nil, 0, fmt.Errorf("HTTP URL '%s' not found", input)
+		var header http.Header
+		if options.Page.Mode == PageNone {
+			body, h, err := fetch(input, options)
+			if err != nil {
+				return nil, 0, "", err
+			}
+			inputs = append(inputs, body)
+			header = h
+		} else {
+			inputs, header, err = openPages(input, filter, options)
+			if err != nil {
+				return nil, 0, "", err
+			}
 		}
 
-		resolver.ResolveMediaType(resp.Header.Get("Content-Type"))
+		resolver.ResolveMediaType(header.Get("Content-Type"))
 
-		format, err := resolver.Format()
-		return []io.ReadCloser{resp.Body}, format, err
-	}
-	if err == nil && u.Scheme == "data" {
+		if compression == CompressionNone {
+			compression, err = parseContentEncoding(
+				header.Get("Content-Encoding"))
+			if err != nil {
+				for _, in := range inputs {
+					in.Close()
+				}
+				return nil, 0, "", err
+			}
+		}
+	} else if err == nil && u.Scheme == "data" {
 		idx := strings.IndexByte(input, ',')
 		if idx < 0 {
-			return nil, 0, fmt.Errorf("malformed data URI: %s", input)
+			return nil, 0, "", fmt.Errorf("malformed data URI: %s", input)
 		}
 		data := input[idx+1:]
 		contentType := input[5:idx]
@@ -107,45 +155,100 @@ func openInput(input string) ([]io.ReadCloser, Format, error) {
 		case "base64":
 			decoded, err = base64.StdEncoding.DecodeString(data)
 			if err != nil {
-				return nil, 0, err
+				return nil, 0, "", err
 			}
 		case "":
 			decoded = []byte(data)
 		default:
-			return nil, 0, fmt.Errorf("unknown data URI encoding: %s", encoding)
+			return nil, 0, "", fmt.Errorf("unknown data URI encoding: %s", encoding)
 		}
 
 		// Resolve format.
 		resolver.ResolveMediaType(contentType)
 
-		format, err := resolver.Format()
-
-		return []io.ReadCloser{
-			&memory{
-				in: bytes.NewReader(decoded),
-			},
-		}, format, err
+		inputs = append(inputs, &memory{
+			in: bytes.NewReader(decoded),
+		})
+	} else {
+		matches, err := filepath.Glob(input)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		if len(matches) == 0 {
+			return nil, 0, "", fmt.Errorf("file not found: %s", input)
+		}
+		for _, match := range matches {
+			f, err := os.Open(match)
+			if err != nil {
+				for _, in := range inputs {
+					in.Close()
+				}
+				return nil, 0, "", err
+			}
+			inputs = append(inputs, f)
+		}
 	}
 
-	matches, err := filepath.Glob(input)
+	decompressed, err := decompressInputs(inputs, compression, members,
+		resolver)
 	if err != nil {
-		return nil, 0, err
-	}
-	if len(matches) == 0 {
-		return nil, 0, fmt.Errorf("file not found: %s", input)
+		return nil, 0, "", err
 	}
+	return resolveInputs(decompressed, filter, options)
+}
+
+// resolveInputs resolves the format of the inputs. The format of the
+// options overrides the resolved formats. The inputs whose format
+// could not be resolved from their meta data are sniffed. If the
+// sniffed CSV data uses a delimiter other than comma, the delimiter
+// is added to the returned filter.
+func resolveInputs(inputs []input, filter string, options *Options) (
+	[]io.ReadCloser, Format, string, error) {
+
 	var result []io.ReadCloser
-	for _, match := range matches {
-		f, err := os.Open(match)
-		if err != nil {
-			return nil, 0, err
+	var format Format
+
+	fail := func(idx int, err error) ([]io.ReadCloser, Format, string,
+		error) {
+
+		for _, in := range result {
+			in.Close()
+		}
+		for ; idx < len(inputs); idx++ {
+			inputs[idx].in.Close()
 		}
-		result = append(result, f)
+		return nil, 0, "", err
 	}
 
-	format, err := resolver.Format()
-
-	return result, format, err
+	for idx, in := range inputs {
+		f := in.format
+		if options.Format != FormatUnknown {
+			f = options.Format
+		} else if in.err != nil {
+			r, sniffed, comma, err := sniff(in.in)
+			if err != nil {
+				return fail(idx, err)
+			}
+			inputs[idx].in = r
+			in.in = r
+			if sniffed == FormatUnknown {
+				return fail(idx, in.err)
+			}
+			f = sniffed
+			if f == FormatCSV && comma != ',' &&
+				!strings.Contains(filter, "comma=") {
+				filter = strings.TrimSpace(
+					fmt.Sprintf("comma=%c %s", comma, filter))
+			}
+		}
+		if idx > 0 && f != format {
+			return fail(idx, fmt.Errorf("mixed source formats: %s, %s",
+				format, f))
+		}
+		format = f
+		result = append(result, in.in)
+	}
+	return result, format, filter, nil
 }
 
 type memory struct {
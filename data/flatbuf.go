@@ -0,0 +1,191 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// errFlatbuf reports malformed flatbuffers. The fbTable accessors
+// panic with errFlatbuf on out of bounds data and fbRecover converts
+// the panic to an error.
+var errFlatbuf = errors.New("invalid flatbuffer")
+
+// fbRecover recovers from errFlatbuf panics and stores the error,
+// prefixed with the message, to err.
+func fbRecover(err *error, msg string) {
+	if r := recover(); r != nil {
+		if r != errFlatbuf {
+			panic(r)
+		}
+		*err = fmt.Errorf("%s: %s", msg, errFlatbuf)
+	}
+}
+
+// fbTable accesses the fields of a flatbuffers table.
+type fbTable struct {
+	buf     []byte
+	pos     int
+	vtable  int
+	vlength int
+}
+
+// fbRoot returns the root table of the flatbuffer.
+func fbRoot(buf []byte) fbTable {
+	return fbTableAt(buf, int(fbUint32(buf, 0)))
+}
+
+func fbTableAt(buf []byte, pos int) fbTable {
+	vtable := pos - int(int32(fbUint32(buf, pos)))
+	vlength := int(fbUint16(buf, vtable))
+	if vlength < 4 || vlength > len(buf)-vtable {
+		panic(errFlatbuf)
+	}
+	return fbTable{
+		buf:     buf,
+		pos:     pos,
+		vtable:  vtable,
+		vlength: vlength,
+	}
+}
+
+func fbUint16(buf []byte, pos int) uint16 {
+	if pos < 0 || pos+2 > len(buf) {
+		panic(errFlatbuf)
+	}
+	return binary.LittleEndian.Uint16(buf[pos:])
+}
+
+func fbUint32(buf []byte, pos int) uint32 {
+	if pos < 0 || pos+4 > len(buf) {
+		panic(errFlatbuf)
+	}
+	return binary.LittleEndian.Uint32(buf[pos:])
+}
+
+func fbUint64(buf []byte, pos int) uint64 {
+	if pos < 0 || pos+8 > len(buf) {
+		panic(errFlatbuf)
+	}
+	return binary.LittleEndian.Uint64(buf[pos:])
+}
+
+// field returns the position of the field i or 0 if the field is not
+// set.
+func (t fbTable) field(i int) int {
+	o := 4 + 2*i
+	if o+2 > t.vlength {
+		return 0
+	}
+	off := int(fbUint16(t.buf, t.vtable+o))
+	if off == 0 {
+		return 0
+	}
+	return t.pos + off
+}
+
+func (t fbTable) has(i int) bool {
+	return t.field(i) != 0
+}
+
+func (t fbTable) uint8(i int, def uint8) uint8 {
+	p := t.field(i)
+	if p == 0 {
+		return def
+	}
+	if p >= len(t.buf) {
+		panic(errFlatbuf)
+	}
+	return t.buf[p]
+}
+
+func (t fbTable) bool(i int, def bool) bool {
+	var d uint8
+	if def {
+		d = 1
+	}
+	return t.uint8(i, d) != 0
+}
+
+func (t fbTable) int16(i int, def int16) int16 {
+	p := t.field(i)
+	if p == 0 {
+		return def
+	}
+	return int16(fbUint16(t.buf, p))
+}
+
+func (t fbTable) int32(i int, def int32) int32 {
+	p := t.field(i)
+	if p == 0 {
+		return def
+	}
+	return int32(fbUint32(t.buf, p))
+}
+
+func (t fbTable) int64(i int, def int64) int64 {
+	p := t.field(i)
+	if p == 0 {
+		return def
+	}
+	return int64(fbUint64(t.buf, p))
+}
+
+// indirect returns the target of the offset field i or 0 if the field
+// is not set.
+func (t fbTable) indirect(i int) int {
+	p := t.field(i)
+	if p == 0 {
+		return 0
+	}
+	return p + int(fbUint32(t.buf, p))
+}
+
+// table returns the table field i.
+func (t fbTable) table(i int) (fbTable, bool) {
+	p := t.indirect(i)
+	if p == 0 {
+		return fbTable{}, false
+	}
+	return fbTableAt(t.buf, p), true
+}
+
+// string returns the string field i.
+func (t fbTable) string(i int) string {
+	start, n := t.vector(i)
+	if n > len(t.buf)-start {
+		panic(errFlatbuf)
+	}
+	return string(t.buf[start : start+n])
+}
+
+// vector returns the start position of the elements and the length
+// of the vector field i.
+func (t fbTable) vector(i int) (int, int) {
+	p := t.indirect(i)
+	if p == 0 {
+		return 0, 0
+	}
+	n := int(fbUint32(t.buf, p))
+	if n < 0 || n > len(t.buf) {
+		panic(errFlatbuf)
+	}
+	return p + 4, n
+}
+
+// tables returns the elements of the table vector field i.
+func (t fbTable) tables(i int) []fbTable {
+	start, n := t.vector(i)
+	var result []fbTable
+	for j := 0; j < n; j++ {
+		p := start + 4*j
+		result = append(result, fbTableAt(t.buf, p+int(fbUint32(t.buf, p))))
+	}
+	return result
+}
@@ -7,10 +7,12 @@
 package data
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"mime"
 	"strings"
+	"sync"
 )
 
 // Format specifies input data format.
@@ -22,24 +24,55 @@ const (
 	FormatCSV
 	FormatHTML
 	FormatJSON
+	FormatXML
+	FormatNDJSON
+	FormatXLSX
+	FormatParquet
+	FormatArrow
 )
 
+// registry protects the format maps below. The maps are modified by
+// Register.
+var registry sync.RWMutex
+
 var mediatypes = map[string]Format{
-	"text/csv":         FormatCSV,
-	"text/html":        FormatHTML,
-	"application/json": FormatJSON,
+	"text/csv":             FormatCSV,
+	"text/html":            FormatHTML,
+	"application/json":     FormatJSON,
+	"application/xml":      FormatXML,
+	"text/xml":             FormatXML,
+	"application/rss+xml":  FormatXML,
+	"application/x-ndjson": FormatNDJSON,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": FormatXLSX,
+	"application/vnd.apache.parquet":                                    FormatParquet,
+	"application/x-parquet":                                             FormatParquet,
+	"application/vnd.apache.arrow.stream":                               FormatArrow,
+	"application/vnd.apache.arrow.file":                                 FormatArrow,
 }
 
 var suffixes = map[string]Format{
-	".csv":  FormatCSV,
-	".html": FormatHTML,
-	".json": FormatJSON,
+	".csv":     FormatCSV,
+	".html":    FormatHTML,
+	".json":    FormatJSON,
+	".xml":     FormatXML,
+	".ndjson":  FormatNDJSON,
+	".jsonl":   FormatNDJSON,
+	".xlsx":    FormatXLSX,
+	".parquet": FormatParquet,
+	".arrow":   FormatArrow,
+	".arrows":  FormatArrow,
+	".feather": FormatArrow,
 }
 
 var formats = map[Format]NewSource{
-	FormatCSV:  NewCSV,
-	FormatHTML: NewHTML,
-	FormatJSON: NewJSON,
+	FormatCSV:     NewCSV,
+	FormatHTML:    NewHTML,
+	FormatJSON:    NewJSON,
+	FormatXML:     NewXML,
+	FormatNDJSON:  NewNDJSON,
+	FormatXLSX:    NewXLSX,
+	FormatParquet: NewParquet,
+	FormatArrow:   NewArrow,
 }
 
 var formatNames = map[Format]string{
@@ -47,16 +80,140 @@ var formatNames = map[Format]string{
 	FormatCSV:     "csv",
 	FormatHTML:    "html",
 	FormatJSON:    "json",
+	FormatXML:     "xml",
+	FormatNDJSON:  "ndjson",
+	FormatXLSX:    "xlsx",
+	FormatParquet: "parquet",
+	FormatArrow:   "arrow",
 }
 
 func (f Format) String() string {
+	registry.RLock()
 	name, ok := formatNames[f]
+	registry.RUnlock()
 	if ok {
 		return name
 	}
 	return fmt.Sprintf("{Format %d}", f)
 }
 
+// ParseFormat parses the data format name.
+func ParseFormat(name string) (Format, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	for format, n := range formatNames {
+		if format != FormatUnknown && strings.EqualFold(name, n) {
+			return format, nil
+		}
+	}
+	return FormatUnknown, fmt.Errorf("unknown data format '%s'", name)
+}
+
+// Register registers a new data format. The name is the format name
+// for the FORMAT clause and Format.String(). The fileSuffixes and
+// mediaTypes list the file suffixes, including the leading '.'
+// character, and the content media types that are resolved to the
+// format. The newSource creates data sources for the format. The
+// function returns the new format or an error if the name, a suffix,
+// or a media type is already registered.
+func Register(name string, fileSuffixes, mediaTypes []string,
+	newSource NewSource) (Format, error) {
+
+	if len(name) == 0 {
+		return FormatUnknown, errors.New("register: empty format name")
+	}
+	if newSource == nil {
+		return FormatUnknown,
+			fmt.Errorf("register: %s: no source constructor", name)
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	for _, n := range formatNames {
+		if strings.EqualFold(name, n) {
+			return FormatUnknown,
+				fmt.Errorf("register: format '%s' already registered", name)
+		}
+	}
+
+	var sfx []string
+	for _, suffix := range fileSuffixes {
+		suffix = strings.ToLower(suffix)
+		if len(suffix) < 2 || suffix[0] != '.' {
+			return FormatUnknown,
+				fmt.Errorf("register: %s: invalid suffix '%s'", name, suffix)
+		}
+		if _, ok := compressionSuffixes[suffix]; ok {
+			return FormatUnknown,
+				fmt.Errorf("register: %s: suffix '%s' is a compression suffix",
+					name, suffix)
+		}
+		if _, ok := suffixes[suffix]; ok {
+			return FormatUnknown,
+				fmt.Errorf("register: %s: suffix '%s' already registered",
+					name, suffix)
+		}
+		sfx = append(sfx, suffix)
+	}
+
+	var mts []string
+	for _, t := range mediaTypes {
+		mediatype, _, err := mime.ParseMediaType(t)
+		if err != nil {
+			return FormatUnknown,
+				fmt.Errorf("register: %s: media type '%s': %s", name, t, err)
+		}
+		if _, ok := mediatypes[mediatype]; ok {
+			return FormatUnknown,
+				fmt.Errorf("register: %s: media type '%s' already registered",
+					name, mediatype)
+		}
+		mts = append(mts, mediatype)
+	}
+
+	format := Format(len(formatNames))
+	formatNames[format] = name
+	formats[format] = newSource
+	for _, suffix := range sfx {
+		suffixes[suffix] = format
+	}
+	for _, t := range mts {
+		mediatypes[t] = format
+	}
+	return format, nil
+}
+
+// RegisterMagic registers the magic prefix of the data of the
+// format. The inputs that start with the magic are sniffed as the
+// format. The function returns an error if the format is not
+// registered, or if the magic is empty or already registered.
+func RegisterMagic(format Format, magic []byte) error {
+	if len(magic) == 0 {
+		return fmt.Errorf("register: %s: empty magic", format)
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := formats[format]; !ok {
+		return fmt.Errorf("register: unknown format %d", format)
+	}
+	for _, m := range binaryMagics {
+		if bytes.HasPrefix(magic, m.prefix) ||
+			bytes.HasPrefix(m.prefix, magic) {
+			return fmt.Errorf("register: %s: magic %q already registered",
+				formatNames[format], magic)
+		}
+	}
+	binaryMagics = append(binaryMagics, binaryMagic{
+		prefix: append([]byte(nil), magic...),
+		format: format,
+	})
+	return nil
+}
+
 // Resolver resolves data format from input meta data.
 type Resolver struct {
 	format Format
@@ -81,7 +238,9 @@ func (r *Resolver) ResolvePath(path string) {
 		r.err = errors.New("no file suffix")
 		return
 	}
+	registry.RLock()
 	f, ok := suffixes[strings.ToLower(path[idx:])]
+	registry.RUnlock()
 	if !ok {
 		r.err = fmt.Errorf("unknown file suffix '%s'", path[idx:])
 		return
@@ -100,8 +259,9 @@ func (r *Resolver) ResolveMediaType(t string) {
 		r.err = err
 		return
 	}
-	var ok bool
+	registry.RLock()
 	f, ok := mediatypes[mediatype]
+	registry.RUnlock()
 	if !ok {
 		r.err = fmt.Errorf("unknown Content-Type: %s", mediatype)
 		return
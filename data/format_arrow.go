@@ -0,0 +1,846 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/markkurossi/iql/types"
+)
+
+// Arrow type identifiers of the Type union.
+const (
+	arrowNull            = 1
+	arrowInt             = 2
+	arrowFloatingPoint   = 3
+	arrowBinary          = 4
+	arrowUtf8            = 5
+	arrowBool            = 6
+	arrowDecimal         = 7
+	arrowDate            = 8
+	arrowTime            = 9
+	arrowTimestamp       = 10
+	arrowInterval        = 11
+	arrowList            = 12
+	arrowStruct          = 13
+	arrowUnion           = 14
+	arrowFixedSizeBinary = 15
+	arrowFixedSizeList   = 16
+	arrowMap             = 17
+	arrowDuration        = 18
+	arrowLargeBinary     = 19
+	arrowLargeUtf8       = 20
+	arrowLargeList       = 21
+	arrowRunEndEncoded   = 22
+	arrowListView        = 25
+	arrowLargeListView   = 26
+)
+
+var arrowTypeNames = map[uint8]string{
+	arrowTime:          "time",
+	arrowInterval:      "interval",
+	arrowList:          "list",
+	arrowStruct:        "struct",
+	arrowUnion:         "union",
+	arrowFixedSizeList: "fixed size list",
+	arrowMap:           "map",
+	arrowDuration:      "duration",
+	arrowLargeList:     "large list",
+	arrowRunEndEncoded: "run-end encoded",
+	arrowListView:      "list view",
+	arrowLargeListView: "large list view",
+}
+
+// Arrow message header types.
+const (
+	arrowSchema          = 1
+	arrowDictionaryBatch = 2
+	arrowRecordBatch     = 3
+)
+
+// arrowFileMagic starts the Arrow IPC files.
+const arrowFileMagic = "ARROW1"
+
+// arrowField defines a top-level field of the Arrow schema.
+type arrowField struct {
+	name      string
+	typeID    uint8
+	bitWidth  int
+	signed    bool
+	precision int
+	unit      int
+	scale     int
+	node      int
+	buffer    int
+	iqlType   types.Type
+	err       error
+}
+
+// arrowStream holds an opened Arrow IPC stream or file. The messages
+// of the streams are read sequentially from in. The file messages are
+// read from the file at the record batch blocks of the file footer.
+type arrowStream struct {
+	in       *bufio.Reader
+	file     io.ReaderAt
+	blocks   []arrowBlock
+	closer   io.Closer
+	fields   []*arrowField
+	selected []*arrowField
+}
+
+// arrowBlock defines the location of a message in the Arrow file.
+type arrowBlock struct {
+	offset int64
+	length int64
+}
+
+// arrowReader reads the Arrow record batches.
+type arrowReader struct {
+	streams []*arrowStream
+	idx     int
+}
+
+// NewArrow creates a new Arrow data source from the input. The input
+// can be in the Arrow IPC streaming or file format. The column types
+// are resolved from the Arrow schema and only the selected columns
+// are decoded from the record batches. The source supports
+// primitive, string, binary, decimal, date, and timestamp columns.
+func NewArrow(input []io.ReadCloser, filter string,
+	columns []types.ColumnSelector) (types.Source, error) {
+
+	reader := new(arrowReader)
+
+	if len(filter) > 0 {
+		for _, in := range input {
+			in.Close()
+		}
+		return nil, fmt.Errorf("arrow: filter not supported: %s", filter)
+	}
+	if len(input) == 0 {
+		return nil, errors.New("arrow: no input")
+	}
+	for idx, in := range input {
+		s, err := openArrow(in)
+		if err != nil {
+			reader.close()
+			for _, rest := range input[idx+1:] {
+				rest.Close()
+			}
+			return nil, err
+		}
+		reader.streams = append(reader.streams, s)
+	}
+
+	// Resolve the columns from the schema of the first stream.
+	if len(columns) == 0 {
+		for _, field := range reader.streams[0].fields {
+			columns = append(columns, types.ColumnSelector{
+				Name: types.Reference{
+					Column: field.name,
+				},
+			})
+		}
+	}
+	for idx, s := range reader.streams {
+		for i, col := range columns {
+			field, err := s.field(col.Name.Column)
+			if err != nil {
+				reader.close()
+				return nil, err
+			}
+			if idx > 0 && reader.streams[0].selected[i].iqlType != field.iqlType {
+				reader.close()
+				return nil, fmt.Errorf("arrow: column %s: type mismatch: "+
+					"%s, %s", field.name, reader.streams[0].selected[i].iqlType,
+					field.iqlType)
+			}
+			s.selected = append(s.selected, field)
+		}
+	}
+	for i := range columns {
+		columns[i].Type = reader.streams[0].selected[i].iqlType
+	}
+
+	return &columnar{
+		name:    "arrow",
+		columns: columns,
+		reader:  reader,
+	}, nil
+}
+
+// openArrow opens the Arrow stream or file and reads its schema.
+func openArrow(in io.ReadCloser) (*arrowStream, error) {
+	var file bool
+	if f, ok := in.(*os.File); ok {
+		var magic [len(arrowFileMagic)]byte
+		n, _ := f.ReadAt(magic[:], 0)
+		file = string(magic[:n]) == arrowFileMagic
+	} else {
+		r := bufio.NewReader(in)
+		magic, _ := r.Peek(len(arrowFileMagic))
+		file = string(magic) == arrowFileMagic
+		in = &readCloser{
+			Reader: r,
+			closer: in,
+		}
+	}
+	if file {
+		return openArrowFile(in)
+	}
+
+	s := &arrowStream{
+		in:     bufio.NewReader(in),
+		closer: in,
+	}
+	header, schema, _, err := s.readMessage()
+	if err == nil && header != arrowSchema {
+		err = errors.New("arrow: stream does not start with schema")
+	}
+	if err == nil {
+		err = s.parseSchema(schema)
+	}
+	if err != nil {
+		s.close()
+		if err == io.EOF {
+			err = errors.New("arrow: empty stream")
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// openArrowFile opens the Arrow file and reads its schema and record
+// batch locations from the file footer.
+func openArrowFile(in io.ReadCloser) (*arrowStream, error) {
+	input, size, closer, err := openReaderAt(in)
+	if err != nil {
+		return nil, err
+	}
+	s := &arrowStream{
+		file:   input,
+		closer: closer,
+	}
+	err = s.readFooter(size)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// readFooter reads the schema and record batch locations from the
+// footer of the Arrow file of size bytes.
+func (s *arrowStream) readFooter(size int64) error {
+	input := s.file
+
+	// The file ends with the footer, its 4-byte length, and the
+	// magic.
+	trailerSize := int64(4 + len(arrowFileMagic))
+	if size < 8+trailerSize {
+		return errors.New("arrow: file too short")
+	}
+	var trailer [10]byte
+	if _, err := input.ReadAt(trailer[:], size-trailerSize); err != nil {
+		return fmt.Errorf("arrow: %s", err)
+	}
+	if string(trailer[4:]) != arrowFileMagic {
+		return errors.New("arrow: invalid file magic")
+	}
+	footerSize := int64(binary.LittleEndian.Uint32(trailer[:]))
+	if footerSize <= 0 || footerSize > size-8-trailerSize {
+		return fmt.Errorf("arrow: invalid footer size %d", footerSize)
+	}
+	footer := make([]byte, footerSize)
+	_, err := input.ReadAt(footer, size-trailerSize-footerSize)
+	if err != nil {
+		return fmt.Errorf("arrow: %s", err)
+	}
+
+	blocks, err := s.parseFooter(footer, size)
+	if err != nil {
+		return err
+	}
+	s.blocks = blocks
+	return nil
+}
+
+// parseFooter parses the schema and the record batch blocks from the
+// Arrow file footer.
+func (s *arrowStream) parseFooter(data []byte, size int64) (
+	blocks []arrowBlock, err error) {
+
+	defer fbRecover(&err, "arrow: invalid footer")
+
+	footer := fbRoot(data)
+	schema, ok := footer.table(1)
+	if !ok {
+		return nil, errors.New("arrow: footer without schema")
+	}
+	if err := s.parseSchema(schema); err != nil {
+		return nil, err
+	}
+
+	// The Block structs hold the 8-byte offset, 4-byte metadata
+	// length with 4 bytes of padding, and the 8-byte body length.
+	start, n := footer.vector(3)
+	for i := 0; i < n; i++ {
+		p := start + i*24
+		offset := int64(fbUint64(data, p))
+		length := int64(int32(fbUint32(data, p+8))) +
+			int64(fbUint64(data, p+16))
+		if offset < 8 || length <= 0 || length > size-offset {
+			return nil, errors.New("arrow: invalid record batch block")
+		}
+		blocks = append(blocks, arrowBlock{
+			offset: offset,
+			length: length,
+		})
+	}
+	return blocks, nil
+}
+
+func (s *arrowStream) close() {
+	if s.closer != nil {
+		s.closer.Close()
+		s.closer = nil
+	}
+}
+
+func (r *arrowReader) close() {
+	for _, s := range r.streams {
+		s.close()
+	}
+}
+
+// nextMessage reads the next message from the stream or the next
+// record batch from the file. The function returns io.EOF when all
+// messages have been read.
+func (s *arrowStream) nextMessage() (uint8, fbTable, []byte, error) {
+	if s.file != nil {
+		if len(s.blocks) == 0 {
+			return 0, fbTable{}, nil, io.EOF
+		}
+		b := s.blocks[0]
+		s.blocks = s.blocks[1:]
+		s.in = bufio.NewReader(io.NewSectionReader(s.file, b.offset,
+			b.length))
+	}
+	return s.readMessage()
+}
+
+// readMessage reads the next message from the stream. The function
+// returns the message header type, the header table, and the message
+// body. The function returns io.EOF at the end of the stream.
+func (s *arrowStream) readMessage() (
+	header uint8, table fbTable, body []byte, err error) {
+
+	var buf [4]byte
+	if _, err = io.ReadFull(s.in, buf[:]); err != nil {
+		if err != io.EOF {
+			err = fmt.Errorf("arrow: %s", err)
+		}
+		return
+	}
+	size := binary.LittleEndian.Uint32(buf[:])
+	if size == 0xffffffff {
+		// Continuation marker.
+		if _, err = io.ReadFull(s.in, buf[:]); err != nil {
+			err = fmt.Errorf("arrow: %s", err)
+			return
+		}
+		size = binary.LittleEndian.Uint32(buf[:])
+	}
+	if size == 0 {
+		// End of stream.
+		err = io.EOF
+		return
+	}
+	if size > math.MaxInt32 {
+		err = fmt.Errorf("arrow: invalid message size %d", size)
+		return
+	}
+	metadata := make([]byte, size)
+	if _, err = io.ReadFull(s.in, metadata); err != nil {
+		err = fmt.Errorf("arrow: %s", err)
+		return
+	}
+
+	defer fbRecover(&err, "arrow: invalid message")
+
+	msg := fbRoot(metadata)
+	header = msg.uint8(1, 0)
+	table, ok := msg.table(2)
+	if !ok {
+		panic(errFlatbuf)
+	}
+	bodyLength := msg.int64(3, 0)
+	if bodyLength < 0 || bodyLength > math.MaxInt32 {
+		err = fmt.Errorf("arrow: invalid body length %d", bodyLength)
+		return
+	}
+	body = make([]byte, bodyLength)
+	if _, err = io.ReadFull(s.in, body); err != nil {
+		err = fmt.Errorf("arrow: %s", err)
+	}
+	return
+}
+
+// parseSchema parses the fields of the schema message.
+func (s *arrowStream) parseSchema(schema fbTable) (err error) {
+	defer fbRecover(&err, "arrow: invalid schema")
+
+	var node, buffer int
+	for _, t := range schema.tables(1) {
+		field := &arrowField{
+			name:   t.string(0),
+			typeID: t.uint8(2, 0),
+			node:   node,
+			buffer: buffer,
+		}
+		nodes, buffers, err := arrowLayout(t)
+		if err != nil {
+			return fmt.Errorf("arrow: field %s: %s", field.name, err)
+		}
+		node += nodes
+		buffer += buffers
+
+		typ, _ := t.table(3)
+		if t.has(4) {
+			field.err = errors.New("dictionary encoding not supported")
+		} else {
+			field.resolveType(typ)
+		}
+		s.fields = append(s.fields, field)
+	}
+	return nil
+}
+
+// arrowLayout returns the number of field nodes and buffers the field
+// and its children use in record batches.
+func arrowLayout(field fbTable) (int, int, error) {
+	var buffers int
+
+	if field.has(4) {
+		// Dictionary encoded field holds the dictionary indices.
+		buffers = 2
+	} else {
+		switch field.uint8(2, 0) {
+		case arrowNull, arrowRunEndEncoded:
+		case arrowStruct, arrowFixedSizeList:
+			buffers = 1
+		case arrowInt, arrowFloatingPoint, arrowBool, arrowDecimal,
+			arrowDate, arrowTime, arrowTimestamp, arrowInterval,
+			arrowFixedSizeBinary, arrowDuration,
+			arrowList, arrowLargeList, arrowMap:
+			buffers = 2
+		case arrowBinary, arrowUtf8, arrowLargeBinary, arrowLargeUtf8,
+			arrowListView, arrowLargeListView:
+			buffers = 3
+		default:
+			return 0, 0, fmt.Errorf("unsupported type layout %d",
+				field.uint8(2, 0))
+		}
+	}
+	nodes := 1
+	for _, child := range field.tables(5) {
+		n, b, err := arrowLayout(child)
+		if err != nil {
+			return 0, 0, err
+		}
+		nodes += n
+		buffers += b
+	}
+	return nodes, buffers, nil
+}
+
+// resolveType resolves the IQL type of the field from the Arrow type
+// table.
+func (f *arrowField) resolveType(typ fbTable) {
+	switch f.typeID {
+	case arrowNull:
+		// Columns without values default to bool like the empty
+		// columns of the other sources.
+		f.iqlType = types.Bool
+
+	case arrowInt:
+		f.bitWidth = int(typ.int32(0, 0))
+		f.signed = typ.bool(1, false)
+		switch f.bitWidth {
+		case 8, 16, 32, 64:
+			f.iqlType = types.Int
+		default:
+			f.err = fmt.Errorf("invalid integer width %d", f.bitWidth)
+		}
+
+	case arrowFloatingPoint:
+		f.precision = int(typ.int16(0, 0))
+		if f.precision > 2 {
+			f.err = fmt.Errorf("invalid floating point precision %d",
+				f.precision)
+		}
+		f.iqlType = types.Float
+
+	case arrowBinary, arrowUtf8, arrowLargeBinary, arrowLargeUtf8:
+		f.iqlType = types.String
+
+	case arrowFixedSizeBinary:
+		f.bitWidth = int(typ.int32(0, 0)) * 8
+		if f.bitWidth <= 0 {
+			f.err = fmt.Errorf("invalid binary width %d", f.bitWidth/8)
+		}
+		f.iqlType = types.String
+
+	case arrowBool:
+		f.iqlType = types.Bool
+
+	case arrowDecimal:
+		f.scale = int(typ.int32(1, 0))
+		f.bitWidth = int(typ.int32(2, 128))
+		switch f.bitWidth {
+		case 32, 64, 128, 256:
+			f.iqlType = types.Float
+		default:
+			f.err = fmt.Errorf("invalid decimal width %d", f.bitWidth)
+		}
+
+	case arrowDate:
+		f.unit = int(typ.int16(0, 1))
+		if f.unit > 1 {
+			f.err = fmt.Errorf("invalid date unit %d", f.unit)
+		}
+		f.iqlType = types.Date
+
+	case arrowTimestamp:
+		f.unit = int(typ.int16(0, 0))
+		if f.unit > 3 {
+			f.err = fmt.Errorf("invalid timestamp unit %d", f.unit)
+		}
+		f.iqlType = types.Date
+
+	default:
+		name, ok := arrowTypeNames[f.typeID]
+		if !ok {
+			name = fmt.Sprintf("%d", f.typeID)
+		}
+		f.err = fmt.Errorf("type %s not supported", name)
+	}
+}
+
+// field returns the schema field by its name.
+func (s *arrowStream) field(name string) (*arrowField, error) {
+	for _, field := range s.fields {
+		if field.name == name {
+			if field.err != nil {
+				return nil, fmt.Errorf("arrow: column %s: %s",
+					name, field.err)
+			}
+			return field, nil
+		}
+	}
+	return nil, fmt.Errorf("arrow: unknown column: %s", name)
+}
+
+// next implements the batchReader.next().
+func (r *arrowReader) next(predicates []types.Predicate) (
+	[]types.Row, error) {
+
+	for r.idx < len(r.streams) {
+		s := r.streams[r.idx]
+		header, table, body, err := s.nextMessage()
+		if err == io.EOF {
+			s.close()
+			r.idx++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch header {
+		case arrowRecordBatch:
+			rows, err := s.readBatch(table, body)
+			if err != nil {
+				return nil, err
+			}
+			if len(rows) == 0 {
+				continue
+			}
+			return rows, nil
+
+		case arrowDictionaryBatch:
+			// The dictionary encoded columns are not supported and
+			// the dictionaries of the other columns are not needed.
+
+		default:
+			return nil, fmt.Errorf("arrow: unexpected message %d", header)
+		}
+	}
+	return nil, io.EOF
+}
+
+// readBatch decodes the selected columns of the record batch.
+func (s *arrowStream) readBatch(batch fbTable, body []byte) (
+	rows []types.Row, err error) {
+
+	defer fbRecover(&err, "arrow: invalid record batch")
+
+	if batch.has(3) {
+		return nil, errors.New("arrow: compressed record batches " +
+			"not supported")
+	}
+	length := batch.int64(0, 0)
+	if length < 0 || length > int64(len(body))*8+1<<20 {
+		return nil, fmt.Errorf("arrow: invalid batch length %d", length)
+	}
+	rows = make([]types.Row, length)
+
+	nodes, numNodes := batch.vector(1)
+	buffers, numBuffers := batch.vector(2)
+
+	for _, field := range s.selected {
+		if field.node >= numNodes {
+			return nil, fmt.Errorf("arrow: column %s: missing field node",
+				field.name)
+		}
+		p := nodes + field.node*16
+		nodeLength := int64(fbUint64(batch.buf, p))
+		nullCount := int64(fbUint64(batch.buf, p+8))
+		if nodeLength != length {
+			return nil, fmt.Errorf("arrow: column %s: got %d values, "+
+				"expected %d", field.name, nodeLength, length)
+		}
+
+		var data [3][]byte
+		for i := 0; i < 3 && field.buffer+i < numBuffers; i++ {
+			p := buffers + (field.buffer+i)*16
+			offset := int64(fbUint64(batch.buf, p))
+			size := int64(fbUint64(batch.buf, p+8))
+			if offset < 0 || size < 0 || size > int64(len(body))-offset {
+				return nil, fmt.Errorf("arrow: column %s: invalid buffer",
+					field.name)
+			}
+			data[i] = body[offset : offset+size]
+		}
+		values, err := field.decode(data, int(length), nullCount > 0)
+		if err != nil {
+			return nil, fmt.Errorf("arrow: column %s: %s", field.name, err)
+		}
+		for i, val := range values {
+			if val == types.Null {
+				rows[i] = append(rows[i], types.NullColumn{})
+			} else {
+				rows[i] = append(rows[i], types.NewValueColumn(val))
+			}
+		}
+	}
+	return rows, nil
+}
+
+var errTruncated = errors.New("truncated buffer")
+
+// decode decodes the column values from the field's buffers.
+func (f *arrowField) decode(data [3][]byte, length int, hasNull bool) (
+	[]types.Value, error) {
+
+	values := make([]types.Value, length)
+	if f.typeID == arrowNull {
+		for i := range values {
+			values[i] = types.Null
+		}
+		return values, nil
+	}
+
+	validity := data[0]
+	if !hasNull {
+		validity = nil
+	} else if len(validity) > 0 && len(validity)*8 < length {
+		return nil, errTruncated
+	}
+	valid := func(i int) bool {
+		return len(validity) == 0 || validity[i/8]&(1<<(i%8)) != 0
+	}
+
+	switch f.typeID {
+	case arrowBinary, arrowUtf8, arrowLargeBinary, arrowLargeUtf8:
+		width := 4
+		if f.typeID == arrowLargeBinary || f.typeID == arrowLargeUtf8 {
+			width = 8
+		}
+		offsets := data[1]
+		if length > 0 && len(offsets) < (length+1)*width {
+			return nil, errTruncated
+		}
+		offset := func(i int) int64 {
+			if width == 4 {
+				return int64(int32(binary.LittleEndian.Uint32(
+					offsets[i*4:])))
+			}
+			return int64(binary.LittleEndian.Uint64(offsets[i*8:]))
+		}
+		for i := range values {
+			if !valid(i) {
+				values[i] = types.Null
+				continue
+			}
+			start := offset(i)
+			end := offset(i + 1)
+			if start < 0 || start > end || end > int64(len(data[2])) {
+				return nil, errTruncated
+			}
+			values[i] = types.StringValue(data[2][start:end])
+		}
+		return values, nil
+
+	case arrowBool:
+		if len(data[1])*8 < length {
+			return nil, errTruncated
+		}
+		for i := range values {
+			if !valid(i) {
+				values[i] = types.Null
+				continue
+			}
+			values[i] = types.BoolValue(data[1][i/8]&(1<<(i%8)) != 0)
+		}
+		return values, nil
+	}
+
+	width := f.width()
+	if len(data[1]) < length*width {
+		return nil, errTruncated
+	}
+	for i := range values {
+		if !valid(i) {
+			values[i] = types.Null
+			continue
+		}
+		values[i] = f.value(data[1][i*width : (i+1)*width])
+	}
+	return values, nil
+}
+
+// width returns the byte width of the fixed-width field values.
+func (f *arrowField) width() int {
+	switch f.typeID {
+	case arrowFloatingPoint:
+		return 2 << f.precision
+	case arrowDate:
+		if f.unit == 0 {
+			return 4
+		}
+		return 8
+	case arrowTimestamp:
+		return 8
+	default:
+		return f.bitWidth / 8
+	}
+}
+
+// value decodes the fixed-width value.
+func (f *arrowField) value(data []byte) types.Value {
+	switch f.typeID {
+	case arrowInt:
+		switch f.bitWidth {
+		case 8:
+			if f.signed {
+				return types.IntValue(int8(data[0]))
+			}
+			return types.IntValue(data[0])
+		case 16:
+			v := binary.LittleEndian.Uint16(data)
+			if f.signed {
+				return types.IntValue(int16(v))
+			}
+			return types.IntValue(v)
+		case 32:
+			v := binary.LittleEndian.Uint32(data)
+			if f.signed {
+				return types.IntValue(int32(v))
+			}
+			return types.IntValue(v)
+		default:
+			return types.IntValue(binary.LittleEndian.Uint64(data))
+		}
+
+	case arrowFloatingPoint:
+		switch f.precision {
+		case 0:
+			return types.FloatValue(halfFloat(
+				binary.LittleEndian.Uint16(data)))
+		case 1:
+			return types.FloatValue(
+				math.Float32frombits(binary.LittleEndian.Uint32(data)))
+		default:
+			return types.FloatValue(
+				math.Float64frombits(binary.LittleEndian.Uint64(data)))
+		}
+
+	case arrowDecimal:
+		// Convert the little-endian value to big-endian.
+		be := make([]byte, len(data))
+		for i, b := range data {
+			be[len(data)-1-i] = b
+		}
+		return types.FloatValue(decimalValue(be, f.scale))
+
+	case arrowDate:
+		if f.unit == 0 {
+			days := int64(int32(binary.LittleEndian.Uint32(data)))
+			return types.DateValue(time.Unix(days*24*60*60, 0).UTC())
+		}
+		v := int64(binary.LittleEndian.Uint64(data))
+		return types.DateValue(time.Unix(0, 0).Add(
+			time.Duration(v) * time.Millisecond).UTC())
+
+	case arrowTimestamp:
+		v := int64(binary.LittleEndian.Uint64(data))
+		switch f.unit {
+		case 0:
+			return types.DateValue(time.Unix(v, 0).UTC())
+		case 1:
+			return types.DateValue(time.Unix(0, 0).Add(
+				time.Duration(v) * time.Millisecond).UTC())
+		case 2:
+			return types.DateValue(time.Unix(0, 0).Add(
+				time.Duration(v) * time.Microsecond).UTC())
+		default:
+			return types.DateValue(time.Unix(0, v).UTC())
+		}
+
+	default:
+		// Fixed size binary.
+		return types.StringValue(data)
+	}
+}
+
+// halfFloat converts the IEEE 754 half-precision value to float.
+func halfFloat(v uint16) float64 {
+	sign := 1
+	if v&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(v>>10) & 0x1f
+	frac := float64(v & 0x3ff)
+
+	switch exp {
+	case 0:
+		return float64(sign) * math.Ldexp(frac, -24)
+	case 0x1f:
+		if frac != 0 {
+			return math.NaN()
+		}
+		return math.Inf(sign)
+	default:
+		return float64(sign) * math.Ldexp(1+frac/1024, exp-15)
+	}
+}
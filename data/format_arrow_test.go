@@ -0,0 +1,152 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+
+	"github.com/markkurossi/iql/types"
+)
+
+var arrowRows = [][]string{
+	{"1", "alpha", "1.5", "true", "2021-01-01 00:00:00",
+		"2021-01-01 12:00:00", "10", "12.34"},
+	{"2", "NULL", "2.5", "false", "NULL", "2021-01-01 12:00:01.5", "20",
+		"-0.05"},
+	{"3", "gamma", "NULL", "true", "2021-01-02 00:00:00",
+		"2021-01-02 12:00:00", "65535", "NULL"},
+	{"4", "delta", "10", "false", "2021-03-14 00:00:00", "NULL", "1", "1"},
+	{"5", "alpha", "20", "false", "2021-03-15 00:00:00", "NULL", "2",
+		"999.99"},
+}
+
+func TestArrow(t *testing.T) {
+	expected := []struct {
+		name string
+		t    types.Type
+	}{
+		{"id", types.Int},
+		{"name", types.String},
+		{"price", types.Float},
+		{"active", types.Bool},
+		{"day", types.Date},
+		{"ts", types.Date},
+		{"count", types.Int},
+		{"amount", types.Float},
+	}
+	for _, file := range []string{"test.arrows", "test.arrow"} {
+		source, err := New([]string{file}, "", nil)
+		if err != nil {
+			t.Fatalf("%s: New failed: %s", file, err)
+		}
+		columns := source.Columns()
+		if len(columns) != len(expected) {
+			t.Fatalf("%s: got %d columns, expected %d",
+				file, len(columns), len(expected))
+		}
+		for i, col := range columns {
+			if col.Name.Column != expected[i].name ||
+				col.Type != expected[i].t {
+				t.Errorf("%s: column %d: got %s %s, expected %s %s", file, i,
+					col.Name.Column, col.Type, expected[i].name,
+					expected[i].t)
+			}
+		}
+		rows, err := source.Get()
+		if err != nil {
+			t.Fatalf("%s: Get failed: %s", file, err)
+		}
+		verifyRows(t, rows, arrowRows)
+	}
+}
+
+func TestArrowColumns(t *testing.T) {
+	source, err := New([]string{"test.arrows", "test.arrow"}, "",
+		xmlColumns("amount", "id"))
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+	if !source.(types.Filterable).Filter(types.Predicate{
+		Column: 1,
+		Op:     types.OpGe,
+		Value:  types.IntValue(4),
+	}) {
+		t.Fatalf("Filter failed")
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	verifyRows(t, rows, [][]string{
+		{"1", "4"}, {"999.99", "5"}, {"1", "4"}, {"999.99", "5"},
+	})
+}
+
+func TestArrowErrors(t *testing.T) {
+	for _, test := range []struct {
+		filter  string
+		columns []types.ColumnSelector
+	}{
+		{"id", nil},
+		{"", xmlColumns("missing")},
+	} {
+		_, err := New([]string{"test.arrows"}, test.filter, test.columns)
+		if err == nil {
+			t.Errorf("New(%q, %v) succeeded", test.filter, test.columns)
+		}
+	}
+	file := writeTestFile(t, "bad.arrows",
+		[]byte("\xff\xff\xff\xff\x08\x00\x00\x00\x04\x00\x00\x00bad!"))
+	_, err := New([]string{file}, "", nil)
+	if err == nil {
+		t.Errorf("invalid stream accepted")
+	}
+
+	data, err := ioutil.ReadFile("test.arrow")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %s", err)
+	}
+	badMagic := append([]byte(nil), data...)
+	copy(badMagic[len(badMagic)-len(arrowFileMagic):], "ARROW0")
+	for _, test := range []struct {
+		name string
+		data []byte
+	}{
+		{"magic.arrow", data[:len(arrowFileMagic)+2]},
+		{"truncated.arrow", data[:99]},
+		{"footer.arrow", data[:len(data)-20]},
+		{"badmagic.arrow", badMagic},
+	} {
+		file := writeTestFile(t, test.name, test.data)
+		_, err := New([]string{file}, "", nil)
+		if err == nil {
+			t.Errorf("%s: invalid file accepted", test.name)
+		}
+	}
+
+	// The offset and size pairs whose sums overflow must not crash
+	// the decoder.
+	for i := 0; i+16 <= len(data); i += 8 {
+		corrupted := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint64(corrupted[i:], 1<<62)
+		binary.LittleEndian.PutUint64(corrupted[i+8:], 1<<62)
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("offset %d: %v", i, r)
+				}
+			}()
+			file := writeTestFile(t, "overflow.arrow", corrupted)
+			source, err := New([]string{file}, "", nil)
+			if err == nil {
+				source.Get()
+			}
+		}()
+	}
+}
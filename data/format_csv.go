@@ -17,30 +17,58 @@ import (
 	"github.com/markkurossi/iql/types"
 )
 
+// DefaultSampleSize specifies the default number of rows that are
+// used to resolve the column types of streaming data sources.
+const DefaultSampleSize = 1024
+
 // CSV implements a data source from comma-separated values (CSV).
+// The source reads its input lazily. The first rows of the input are
+// read when the source is created and they are used to resolve the
+// column types. The column types are widened if the later rows do not
+// match them.
 type CSV struct {
-	columns []types.ColumnSelector
-	rows    []types.Row
+	columns          []types.ColumnSelector
+	input            []io.ReadCloser
+	inputIdx         int
+	reader           *csv.Reader
+	indices          []int
+	skip             int
+	comment          rune
+	comma            rune
+	headers          bool
+	prependHeaders   []string
+	trimLeadingSpace bool
+	sampleSize       int
+	sample           []types.Row
+	predicates       []types.Predicate
+	rows             []types.Row
+	materialized     bool
+	consumed         bool
 }
 
 // NewCSV creates a new CSV data source from the input.
 func NewCSV(input []io.ReadCloser, filter string,
 	columns []types.ColumnSelector) (types.Source, error) {
 
-	for _, in := range input {
-		defer in.Close()
+	source := &CSV{
+		input:            input,
+		headers:          true,
+		trimLeadingSpace: true,
+		comma:            ',',
+		sampleSize:       DefaultSampleSize,
 	}
+	err := source.init(filter, columns)
+	if err != nil {
+		source.close()
+		return nil, err
+	}
+	return source, nil
+}
 
-	// Parse filter options
-
+func (c *CSV) init(filter string, columns []types.ColumnSelector) error {
 	var err error
-	var skip int
-	var comment rune
 
-	headers := true
-	var prependHeaders []string
-	trimLeadingSpace := true
-	comma := ','
+	// Parse filter options
 
 	for _, option := range strings.Split(filter, " ") {
 		if len(option) == 0 {
@@ -51,165 +79,246 @@ func NewCSV(input []io.ReadCloser, filter string,
 		case 1:
 			switch parts[0] {
 			case "keep-leading-space":
-				trimLeadingSpace = false
+				c.trimLeadingSpace = false
 
 			case "noheaders":
-				headers = false
+				c.headers = false
 
 			default:
-				return nil, fmt.Errorf("csv: invalid filter flag: %s", parts[0])
+				return fmt.Errorf("csv: invalid filter flag: %s", parts[0])
 			}
 
 		case 2:
 			switch parts[0] {
 			case "skip":
-				skip, err = strconv.Atoi(parts[1])
+				c.skip, err = strconv.Atoi(parts[1])
 				if err != nil {
-					return nil, fmt.Errorf("csv: invalid skip count: %s",
-						parts[1])
+					return fmt.Errorf("csv: invalid skip count: %s", parts[1])
 				}
 
 			case "comma":
 				runes := []rune(parts[1])
 				if len(runes) != 1 {
-					return nil, fmt.Errorf("csv: comma must be rune: %s",
-						parts[1])
+					return fmt.Errorf("csv: comma must be rune: %s", parts[1])
 				}
-				comma = runes[0]
+				c.comma = runes[0]
 
 			case "comment":
 				runes := []rune(parts[1])
 				if len(runes) != 1 {
-					return nil, fmt.Errorf("csv: comment must be rune: %s",
+					return fmt.Errorf("csv: comment must be rune: %s",
 						parts[1])
 				}
-				comment = runes[0]
+				c.comment = runes[0]
 
 			case "prepend-headers":
-				prependHeaders = strings.Split(parts[1], ",")
+				c.prependHeaders = strings.Split(parts[1], ",")
+
+			case "sample":
+				c.sampleSize, err = strconv.Atoi(parts[1])
+				if err != nil || c.sampleSize < 0 {
+					return fmt.Errorf("csv: invalid sample size: %s",
+						parts[1])
+				}
 
 			default:
-				return nil, fmt.Errorf("csv: unknown option: %s", parts[0])
+				return fmt.Errorf("csv: unknown option: %s", parts[0])
 			}
 
 		default:
-			return nil, fmt.Errorf("csv: invalid filter option: %s", option)
+			return fmt.Errorf("csv: invalid filter option: %s", option)
 		}
 	}
 
-	var rows []types.Row
-	var indices []int
+	if len(c.input) == 0 {
+		return errors.New("csv: no input")
+	}
+
+	// Open the first input and resolve column indices.
+	header, err := c.openInput()
+	if err != nil {
+		return err
+	}
+	if c.headers {
+		// Mapping from column names to column indices.
+		if header == nil {
+			return errors.New("csv: no records")
+		}
 
-	for idx, in := range input {
-		reader := csv.NewReader(in)
-		reader.Comment = comment
-		reader.TrimLeadingSpace = trimLeadingSpace
-		reader.Comma = comma
+		r0 := append(c.prependHeaders, header...)
 
-		if len(prependHeaders) > 0 {
-			reader.FieldsPerRecord = -1
+		// Collect all column names; unselected columns are appended
+		// to the source's columns array.
+		seen := make(map[string]bool)
+		for _, col := range columns {
+			seen[col.Name.Column] = true
+		}
+		names := make(map[string]int)
+		for idx, col := range r0 {
+			names[col] = idx
+
+			if !seen[col] {
+				seen[col] = true
+				columns = append(columns, types.ColumnSelector{
+					Name: types.Reference{
+						Column: col,
+					},
+				})
+			}
 		}
 
-		records, err := reader.ReadAll()
-		if err != nil {
-			return nil, err
+		for _, col := range columns {
+			i, ok := names[col.Name.Column]
+			if !ok {
+				return fmt.Errorf("csv: unknown column: %s", col.Name.Column)
+			}
+			c.indices = append(c.indices, i)
+		}
+	} else {
+		if len(columns) == 0 {
+			return errors.New("csv: 'SELECT *' not supported without headers")
 		}
-		if skip > len(records) {
-			skip = len(records)
+		for _, col := range columns {
+			i, err := strconv.Atoi(col.Name.Column)
+			if err != nil {
+				return err
+			}
+			c.indices = append(c.indices, i)
 		}
-		records = records[skip:]
+	}
+	c.columns = columns
 
-		if idx == 0 {
-			if headers {
-				// Mapping from column names to column indices.
-				if len(records) == 0 {
-					return nil, errors.New("csv: no records")
-				}
+	// Read the type resolution sample.
+	for c.sampleSize == 0 || len(c.sample) < c.sampleSize {
+		row, err := c.next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		for i := range c.columns {
+			c.columns[i].ResolveString(row[i].String())
+		}
+		c.sample = append(c.sample, row)
+	}
 
-				r0 := append(prependHeaders, records[0]...)
+	return nil
+}
 
-				// Collect all column names; unselected columns are
-				// appended to the source's columns array.
-				seen := make(map[string]bool)
-				for _, col := range columns {
-					seen[col.Name.Column] = true
-				}
-				names := make(map[string]int)
-				for idx, col := range r0 {
-					names[col] = idx
-
-					if !seen[col] {
-						seen[col] = true
-						columns = append(columns, types.ColumnSelector{
-							Name: types.Reference{
-								Column: col,
-							},
-						})
-					}
-				}
+// openInput opens the current input and skips its leading
+// records. The function returns the input's header record if the
+// input has headers.
+func (c *CSV) openInput() ([]string, error) {
+	c.reader = csv.NewReader(c.input[c.inputIdx])
+	c.reader.Comment = c.comment
+	c.reader.TrimLeadingSpace = c.trimLeadingSpace
+	c.reader.Comma = c.comma
+
+	if len(c.prependHeaders) > 0 {
+		c.reader.FieldsPerRecord = -1
+	}
 
-				for _, col := range columns {
-					i, ok := names[col.Name.Column]
-					if !ok {
-						return nil, fmt.Errorf("csv: unknown column: %s",
-							col.Name.Column)
-					}
-					indices = append(indices, i)
-				}
-			} else {
-				if len(columns) == 0 {
-					return nil, errors.New(
-						"csv: 'SELECT *' not supported without headers")
-				}
-				for _, col := range columns {
-					i, err := strconv.Atoi(col.Name.Column)
-					if err != nil {
-						return nil, err
-					}
-					indices = append(indices, i)
-				}
+	for i := 0; i < c.skip; i++ {
+		_, err := c.reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				return nil, nil
 			}
+			return nil, err
+		}
+	}
+	if !c.headers {
+		return nil, nil
+	}
+	record, err := c.reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil
 		}
-		if headers {
-			records = records[1:]
+		return nil, err
+	}
+	return record, nil
+}
+
+// next reads the next row from the inputs. The function returns
+// io.EOF when all inputs have been read.
+func (c *CSV) next() (types.Row, error) {
+	for {
+		if c.reader == nil {
+			return nil, io.EOF
+		}
+		record, err := c.reader.Read()
+		if err == nil {
+			return c.row(record), nil
+		}
+		if err != io.EOF {
+			return nil, err
 		}
 
-		rows, err = processCSV(rows, records, indices, columns)
+		// Move to the next input.
+		c.input[c.inputIdx].Close()
+		c.inputIdx++
+		if c.inputIdx >= len(c.input) {
+			c.reader = nil
+			return nil, io.EOF
+		}
+		_, err = c.openInput()
 		if err != nil {
 			return nil, err
 		}
 	}
-
-	return &CSV{
-		columns: columns,
-		rows:    rows,
-	}, nil
 }
 
-func processCSV(rows []types.Row, records [][]string, indices []int,
-	columns []types.ColumnSelector) ([]types.Row, error) {
+func (c *CSV) row(record []string) types.Row {
+	var row types.Row
+	for i := range c.columns {
+		idx := c.indices[i]
+		var val string
 
-	for _, record := range records {
-		var row types.Row
-		for i := range columns {
-			idx := indices[i]
-			var val string
-
-			if idx < 0 {
-				if -idx <= len(record) {
-					val = record[len(record)+idx]
-				}
-			} else {
-				if idx < len(record) {
-					val = record[idx]
-				}
+		if idx < 0 {
+			if -idx <= len(record) {
+				val = record[len(record)+idx]
+			}
+		} else {
+			if idx < len(record) {
+				val = record[idx]
 			}
-			columns[i].ResolveString(val)
-			row = append(row, types.StringColumn(val))
 		}
-		rows = append(rows, row)
+		row = append(row, types.StringColumn(val))
 	}
-	return rows, nil
+	return row
+}
+
+// match tests if the row matches the source's predicates.
+func (c *CSV) match(row types.Row) (bool, error) {
+	for _, pred := range c.predicates {
+		match, err := pred.Match(row[pred.Column], c.columns[pred.Column].Type)
+		if err != nil {
+			return false, fmt.Errorf("csv: %s", err)
+		}
+		if !match {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (c *CSV) close() {
+	for ; c.inputIdx < len(c.input); c.inputIdx++ {
+		c.input[c.inputIdx].Close()
+	}
+	c.reader = nil
+}
+
+// Filter implements the Filterable.Filter(). The predicates are
+// evaluated for the rows as they are read from the input.
+func (c *CSV) Filter(pred types.Predicate) bool {
+	if c.consumed || pred.Column < 0 || pred.Column >= len(c.columns) {
+		return false
+	}
+	c.predicates = append(c.predicates, pred)
+	return true
 }
 
 // Columns implements the Source.Columns().
@@ -219,5 +328,84 @@ func (c *CSV) Columns() []types.ColumnSelector {
 
 // Get implements the Source.Get().
 func (c *CSV) Get() ([]types.Row, error) {
+	if c.materialized {
+		return c.rows, nil
+	}
+	it, err := c.Rows()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := types.ReadAll(it)
+	if err != nil {
+		return nil, err
+	}
+	c.rows = rows
+	c.materialized = true
 	return c.rows, nil
 }
+
+// Rows implements the Source.Rows().
+func (c *CSV) Rows() (types.RowIterator, error) {
+	if c.materialized {
+		return types.NewRowsIterator(c.rows), nil
+	}
+	if c.consumed {
+		return nil, errors.New("csv: input already consumed")
+	}
+	c.consumed = true
+	return &csvIterator{
+		source: c,
+	}, nil
+}
+
+// csvIterator iterates the type resolution sample and the remaining
+// input rows.
+type csvIterator struct {
+	source *CSV
+	next   int
+}
+
+// Next implements the RowIterator.Next().
+func (it *csvIterator) Next() (types.Row, error) {
+	for {
+		row, err := it.read()
+		if err != nil {
+			return nil, err
+		}
+		match, err := it.source.match(row)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			return row, nil
+		}
+	}
+}
+
+func (it *csvIterator) read() (types.Row, error) {
+	c := it.source
+	if it.next < len(c.sample) {
+		row := c.sample[it.next]
+		c.sample[it.next] = nil
+		it.next++
+		return row, nil
+	}
+	row, err := c.next()
+	if err != nil {
+		return nil, err
+	}
+	// Widen the column types that were resolved from the sample if
+	// the row does not match them. The query widens the values of
+	// such rows when it reads them.
+	for i := range c.columns {
+		c.columns[i].ResolveString(row[i].String())
+	}
+	return row, nil
+}
+
+// Close implements the RowIterator.Close().
+func (it *csvIterator) Close() error {
+	it.source.sample = nil
+	it.source.close()
+	return nil
+}
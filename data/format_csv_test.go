@@ -7,7 +7,10 @@
 package data
 
 import (
+	"io"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markkurossi/iql/types"
@@ -82,3 +85,98 @@ func TestCSVOptions(t *testing.T) {
 	}
 	tab.Print(os.Stdout)
 }
+
+func TestCSVStreaming(t *testing.T) {
+	input := []io.ReadCloser{
+		ioutil.NopCloser(strings.NewReader("Ints,Strings\n1,a\n2,b\nc,d\n")),
+	}
+	source, err := NewCSV(input, "sample=2", nil)
+	if err != nil {
+		t.Fatalf("NewCSV failed: %s", err)
+	}
+	columns := source.Columns()
+	if len(columns) != 2 {
+		t.Fatalf("unexpected number of columns: %d", len(columns))
+	}
+	if columns[0].Type != types.Int {
+		t.Errorf("unexpected column type: got %s, expected %s",
+			columns[0].Type, types.Int)
+	}
+	it, err := source.Rows()
+	if err != nil {
+		t.Fatalf("csv.Rows() failed: %s", err)
+	}
+	defer it.Close()
+	for i := 0; i < 2; i++ {
+		_, err = it.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %s", err)
+		}
+	}
+	row, err := it.Next()
+	if err != nil {
+		t.Fatalf("Next failed: %s", err)
+	}
+	if row[0].String() != "c" {
+		t.Errorf("unexpected value: got %s, expected c", row[0])
+	}
+	if columns[0].Type != types.String {
+		t.Errorf("column type not widened: got %s, expected %s",
+			columns[0].Type, types.String)
+	}
+
+	_, err = source.Rows()
+	if err == nil {
+		t.Errorf("consumed input iterated twice")
+	}
+}
+
+func TestCSVFilter(t *testing.T) {
+	input := []io.ReadCloser{
+		ioutil.NopCloser(strings.NewReader(
+			"Ints,Strings\n1,a\n2,b\n3,c\n4,d\n")),
+	}
+	source, err := NewCSV(input, "sample=2", nil)
+	if err != nil {
+		t.Fatalf("NewCSV failed: %s", err)
+	}
+	filterable, ok := source.(types.Filterable)
+	if !ok {
+		t.Fatalf("CSV source is not filterable")
+	}
+	if !filterable.Filter(types.Predicate{
+		Column: 0,
+		Op:     types.OpGt,
+		Value:  types.IntValue(1),
+	}) {
+		t.Fatalf("Filter failed")
+	}
+	if !filterable.Filter(types.Predicate{
+		Column: 1,
+		Op:     types.OpNEq,
+		Value:  types.StringValue("c"),
+	}) {
+		t.Fatalf("Filter failed")
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("csv.Get() failed: %s", err)
+	}
+	expected := []string{"b", "d"}
+	if len(rows) != len(expected) {
+		t.Fatalf("unexpected number of rows: got %d, expected %d",
+			len(rows), len(expected))
+	}
+	for i, row := range rows {
+		if row[1].String() != expected[i] {
+			t.Errorf("row %d: got %s, expected %s", i, row[1], expected[i])
+		}
+	}
+	if filterable.Filter(types.Predicate{
+		Column: 0,
+		Op:     types.OpEq,
+		Value:  types.IntValue(1),
+	}) {
+		t.Errorf("Filter succeeded for consumed input")
+	}
+}
@@ -110,3 +110,8 @@ func (html *HTML) Columns() []types.ColumnSelector {
 func (html *HTML) Get() ([]types.Row, error) {
 	return html.rows, nil
 }
+
+// Rows implements the Source.Rows().
+func (html *HTML) Rows() (types.RowIterator, error) {
+	return types.NewRowsIterator(html.rows), nil
+}
@@ -53,12 +53,11 @@ func NewJSON(input []io.ReadCloser, filter string,
 	var rows []types.Row
 
 	for idx, in := range input {
-		data, err := ioutil.ReadAll(in)
-		if err != nil {
-			return nil, err
-		}
+		// Decode numbers as json.Number to keep their full precision.
+		decoder := json.NewDecoder(in)
+		decoder.UseNumber()
 		var v interface{}
-		err = json.Unmarshal(data, &v)
+		err := decoder.Decode(&v)
 		if err != nil {
 			return nil, err
 		}
@@ -112,9 +111,13 @@ func processJSON(filtered []interface{}, rows []types.Row, filter string,
 			if err != nil {
 				return nil, err
 			}
-			row = append(row,
-				types.StringColumn(strings.TrimSpace(fmt.Sprintf("%v", sel))))
-			columns[i].ResolveString(row[i].String())
+			val := jsonValue(sel)
+			if val == types.Null {
+				row = append(row, types.NullColumn{})
+			} else {
+				row = append(row, types.NewValueColumn(val))
+				columns[i].ResolveValue(val)
+			}
 		}
 		rows = append(rows, row)
 	}
@@ -122,6 +125,60 @@ func processJSON(filtered []interface{}, rows []types.Row, filter string,
 	return rows, nil
 }
 
+// jsonValue converts the decoded JSON value into a typed value. JSON
+// arrays are converted to array values and objects to record values.
+func jsonValue(v interface{}) types.Value {
+	switch val := v.(type) {
+	case nil:
+		return types.Null
+
+	case bool:
+		return types.BoolValue(val)
+
+	case json.Number:
+		i, err := val.Int64()
+		if err == nil {
+			return types.IntValue(i)
+		}
+		if !strings.ContainsAny(val.String(), ".eE") {
+			// Integers outside the int64 range are kept as strings
+			// so that they do not lose precision.
+			return types.StringValue(val.String())
+		}
+		f, err := val.Float64()
+		if err == nil {
+			return types.FloatValue(f)
+		}
+		return types.StringValue(val.String())
+
+	case float64:
+		return types.FloatValue(val)
+
+	case string:
+		return types.StringValue(strings.TrimSpace(val))
+
+	case []interface{}:
+		var elType types.ColumnSelector
+		var data []types.Value
+		for _, el := range val {
+			ev := jsonValue(el)
+			elType.ResolveValue(ev)
+			data = append(data, ev)
+		}
+		return types.NewArray(elType.Type, data)
+
+	case map[string]interface{}:
+		data := make(map[string]types.Value)
+		for k, el := range val {
+			data[k] = jsonValue(el)
+		}
+		return types.NewRecord(data)
+
+	default:
+		return types.StringValue(fmt.Sprintf("%v", val))
+	}
+}
+
 // Columns implements the Source.Columns().
 func (src *JSON) Columns() []types.ColumnSelector {
 	return src.columns
@@ -131,3 +188,8 @@ func (src *JSON) Columns() []types.ColumnSelector {
 func (src *JSON) Get() ([]types.Row, error) {
 	return src.rows, nil
 }
+
+// Rows implements the Source.Rows().
+func (src *JSON) Rows() (types.RowIterator, error) {
+	return types.NewRowsIterator(src.rows), nil
+}
@@ -0,0 +1,75 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/iql/types"
+)
+
+var jsonTypesInput = `{
+  "items": [
+    {"b": true,  "i": 1, "f": 1.5, "s": " a ", "n": null, "big": 1e21,
+     "huge": 18446744073709551615, "arr": [1, 2], "obj": {"x": "y"}},
+    {"b": false, "i": 2, "f": 2,   "s": "b",   "n": null, "big": 2,
+     "huge": -9223372036854775809, "arr": [], "obj": {}}
+  ]
+}`
+
+func TestJSONTypes(t *testing.T) {
+	var columns []types.ColumnSelector
+	for _, name := range []string{"b", "i", "f", "s", "n", "big", "huge", "arr",
+		"obj"} {
+		columns = append(columns, types.ColumnSelector{
+			Name: types.Reference{
+				Column: name,
+			},
+		})
+	}
+	source, err := NewJSON([]io.ReadCloser{
+		ioutil.NopCloser(strings.NewReader(jsonTypesInput)),
+	}, "items", columns)
+	if err != nil {
+		t.Fatalf("NewJSON failed: %s", err)
+	}
+	expected := []types.Type{
+		types.Bool, types.Int, types.Float, types.String, types.Bool,
+		types.Float, types.String, types.Array, types.Record,
+	}
+	for idx, col := range source.Columns() {
+		if col.Type != expected[idx] {
+			t.Errorf("column %s: got type %s, expected %s",
+				col, col.Type, expected[idx])
+		}
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	values := [][]string{
+		{"true", "1", "1.5", "a", "NULL", "1e+21", "18446744073709551615",
+			"[1 2]", "{x:y}"},
+		{"false", "2", "2", "b", "NULL", "2", "-9223372036854775809", "[]",
+			"{}"},
+	}
+	for rowIdx, row := range rows {
+		for colIdx, col := range row {
+			if col.String() != values[rowIdx][colIdx] {
+				t.Errorf("%d.%d: got '%s', expected '%s'", rowIdx, colIdx,
+					col.String(), values[rowIdx][colIdx])
+			}
+		}
+	}
+	_, ok := rows[0][4].(types.NullColumn)
+	if !ok {
+		t.Errorf("null value not mapped to NullColumn: %T", rows[0][4])
+	}
+}
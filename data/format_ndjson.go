@@ -0,0 +1,432 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/markkurossi/iql/types"
+	"github.com/markkurossi/jsonq"
+)
+
+// NDJSON implements a data source from newline-delimited JSON
+// (NDJSON) data. Each input line is a JSON document. The source reads
+// its input lazily. The first records of the input are read when the
+// source is created and they are used to resolve the column types. The
+// columns of 'SELECT *' are resolved from all input records.
+type NDJSON struct {
+	columns      []types.ColumnSelector
+	keys         []bool
+	filter       string
+	input        []io.ReadCloser
+	inputIdx     int
+	reader       *bufio.Reader
+	line         int
+	pending      []ndjsonRecord
+	sampleSize   int
+	sample       []types.Row
+	predicates   []types.Predicate
+	rows         []types.Row
+	materialized bool
+	consumed     bool
+}
+
+// ndjsonRecord holds a record and its input line number.
+type ndjsonRecord struct {
+	line int
+	v    interface{}
+}
+
+// NewNDJSON creates a new NDJSON data source from the input. The
+// filter is a JSONQ selector that selects the records from the input
+// lines. If the filter is empty, each line is a record.
+func NewNDJSON(input []io.ReadCloser, filter string,
+	columns []types.ColumnSelector) (types.Source, error) {
+
+	source := &NDJSON{
+		filter:     filter,
+		input:      input,
+		sampleSize: DefaultSampleSize,
+	}
+	err := source.init(columns)
+	if err != nil {
+		source.close()
+		return nil, err
+	}
+	return source, nil
+}
+
+func (src *NDJSON) init(columns []types.ColumnSelector) error {
+	if len(src.input) == 0 {
+		return errors.New("ndjson: no input")
+	}
+
+	// SELECT * selects the union of the record keys.
+	selectAll := len(columns) == 0
+	seen := make(map[string]bool)
+	if selectAll {
+		if src.seekable() {
+			if err := src.scanKeys(seen); err != nil {
+				return err
+			}
+		} else {
+			// The input can be read only once so all its records
+			// are read into the sample.
+			src.sampleSize = 0
+		}
+	}
+	src.reader = bufio.NewReader(src.input[0])
+
+	// Read the sample records.
+	var records []ndjsonRecord
+	for src.sampleSize == 0 || len(records) < src.sampleSize {
+		record, err := src.next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		records = append(records, record)
+	}
+
+	if selectAll {
+		for _, record := range records {
+			if err := addKeys(seen, record); err != nil {
+				return err
+			}
+		}
+		for key := range seen {
+			columns = append(columns, types.ColumnSelector{
+				Name: types.Reference{
+					Column: key,
+				},
+			})
+		}
+		sort.Slice(columns, func(i, j int) bool {
+			return columns[i].Name.Column < columns[j].Name.Column
+		})
+		for range columns {
+			src.keys = append(src.keys, true)
+		}
+	} else {
+		src.keys = make([]bool, len(columns))
+	}
+	src.columns = columns
+
+	for _, record := range records {
+		values, err := src.values(record)
+		if err != nil {
+			return err
+		}
+		for i, val := range values {
+			src.columns[i].ResolveValue(val)
+		}
+		src.sample = append(src.sample, newRow(values))
+	}
+
+	return nil
+}
+
+// seekable tests if all inputs can be rewound for reading them again.
+func (src *NDJSON) seekable() bool {
+	for _, input := range src.input {
+		seeker, ok := input.(io.Seeker)
+		if !ok {
+			return false
+		}
+		_, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// scanKeys reads the keys of all input records into seen and rewinds
+// the inputs.
+func (src *NDJSON) scanKeys(seen map[string]bool) error {
+	for _, input := range src.input {
+		reader := bufio.NewReader(input)
+		src.line = 0
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				src.line++
+				if err := src.decode(line); err != nil {
+					return err
+				}
+				for _, record := range src.pending {
+					if err := addKeys(seen, record); err != nil {
+						return err
+					}
+				}
+				src.pending = nil
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+		_, err := input.(io.Seeker).Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+	}
+	src.line = 0
+	return nil
+}
+
+// addKeys adds the keys of the object record into seen.
+func addKeys(seen map[string]bool, record ndjsonRecord) error {
+	obj, ok := record.v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("ndjson: line %d: 'SELECT *' not supported "+
+			"for non-object records", record.line)
+	}
+	for key := range obj {
+		seen[key] = true
+	}
+	return nil
+}
+
+// next reads the next record from the inputs. The function returns
+// io.EOF when all inputs have been read.
+func (src *NDJSON) next() (ndjsonRecord, error) {
+	for len(src.pending) == 0 {
+		if src.reader == nil {
+			return ndjsonRecord{}, io.EOF
+		}
+		line, err := src.reader.ReadBytes('\n')
+		if len(line) > 0 {
+			src.line++
+			if err := src.decode(line); err != nil {
+				return ndjsonRecord{}, err
+			}
+		}
+		if err == nil {
+			continue
+		}
+		if err != io.EOF {
+			return ndjsonRecord{}, err
+		}
+
+		// Move to the next input.
+		src.input[src.inputIdx].Close()
+		src.inputIdx++
+		if src.inputIdx >= len(src.input) {
+			src.reader = nil
+			continue
+		}
+		src.reader = bufio.NewReader(src.input[src.inputIdx])
+		src.line = 0
+	}
+	record := src.pending[0]
+	src.pending = src.pending[1:]
+	return record, nil
+}
+
+// decode decodes the input line and adds its records to the pending
+// records.
+func (src *NDJSON) decode(line []byte) error {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return fmt.Errorf("ndjson: line %d: %s", src.line, err)
+	}
+	if decoder.More() {
+		return fmt.Errorf("ndjson: line %d: trailing data after JSON value",
+			src.line)
+	}
+	filtered, err := jsonq.Ctx(v).Select(src.filter).Get()
+	if err != nil {
+		return fmt.Errorf("ndjson: line %d: %s", src.line, err)
+	}
+	for _, f := range filtered {
+		src.pending = append(src.pending, ndjsonRecord{
+			line: src.line,
+			v:    f,
+		})
+	}
+	return nil
+}
+
+// values selects the column values from the record. The columns of
+// 'SELECT *' are record keys and the records that do not have the key
+// get a NULL value.
+func (src *NDJSON) values(record ndjsonRecord) ([]types.Value, error) {
+	var values []types.Value
+	for i, col := range src.columns {
+		var sel interface{}
+		if src.keys[i] {
+			obj, ok := record.v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("ndjson: line %d: record is not "+
+					"an object", record.line)
+			}
+			sel = obj[col.Name.Column]
+		} else {
+			var err error
+			sel, err = jsonq.Get(record.v, col.Name.Column)
+			if err != nil {
+				return nil, fmt.Errorf("ndjson: line %d: %s", record.line, err)
+			}
+		}
+		values = append(values, jsonValue(sel))
+	}
+	return values, nil
+}
+
+func newRow(values []types.Value) types.Row {
+	var row types.Row
+	for _, val := range values {
+		if val == types.Null {
+			row = append(row, types.NullColumn{})
+		} else {
+			row = append(row, types.NewValueColumn(val))
+		}
+	}
+	return row
+}
+
+// match tests if the row matches the source's predicates.
+func (src *NDJSON) match(row types.Row) (bool, error) {
+	for _, pred := range src.predicates {
+		match, err := pred.Match(row[pred.Column],
+			src.columns[pred.Column].Type)
+		if err != nil {
+			return false, fmt.Errorf("ndjson: %s", err)
+		}
+		if !match {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (src *NDJSON) close() {
+	for ; src.inputIdx < len(src.input); src.inputIdx++ {
+		src.input[src.inputIdx].Close()
+	}
+	src.reader = nil
+}
+
+// Filter implements the Filterable.Filter(). The predicates are
+// evaluated for the rows as they are read from the input.
+func (src *NDJSON) Filter(pred types.Predicate) bool {
+	if src.consumed || pred.Column < 0 || pred.Column >= len(src.columns) {
+		return false
+	}
+	src.predicates = append(src.predicates, pred)
+	return true
+}
+
+// Columns implements the Source.Columns().
+func (src *NDJSON) Columns() []types.ColumnSelector {
+	return src.columns
+}
+
+// Get implements the Source.Get().
+func (src *NDJSON) Get() ([]types.Row, error) {
+	if src.materialized {
+		return src.rows, nil
+	}
+	it, err := src.Rows()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := types.ReadAll(it)
+	if err != nil {
+		return nil, err
+	}
+	src.rows = rows
+	src.materialized = true
+	return src.rows, nil
+}
+
+// Rows implements the Source.Rows().
+func (src *NDJSON) Rows() (types.RowIterator, error) {
+	if src.materialized {
+		return types.NewRowsIterator(src.rows), nil
+	}
+	if src.consumed {
+		return nil, errors.New("ndjson: input already consumed")
+	}
+	src.consumed = true
+	return &ndjsonIterator{
+		source: src,
+	}, nil
+}
+
+// ndjsonIterator iterates the sample rows and the remaining input
+// rows.
+type ndjsonIterator struct {
+	source *NDJSON
+	next   int
+}
+
+// Next implements the RowIterator.Next().
+func (it *ndjsonIterator) Next() (types.Row, error) {
+	for {
+		row, err := it.read()
+		if err != nil {
+			return nil, err
+		}
+		match, err := it.source.match(row)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			return row, nil
+		}
+	}
+}
+
+func (it *ndjsonIterator) read() (types.Row, error) {
+	src := it.source
+	if it.next < len(src.sample) {
+		row := src.sample[it.next]
+		src.sample[it.next] = nil
+		it.next++
+		return row, nil
+	}
+	record, err := src.next()
+	if err != nil {
+		return nil, err
+	}
+	values, err := src.values(record)
+	if err != nil {
+		return nil, err
+	}
+	// Widen the column types that were resolved from the sample if
+	// the values do not match them. The query widens the values of
+	// such rows when it reads them.
+	for i, val := range values {
+		src.columns[i].ResolveValue(val)
+	}
+	return newRow(values), nil
+}
+
+// Close implements the RowIterator.Close().
+func (it *ndjsonIterator) Close() error {
+	it.source.sample = nil
+	it.source.close()
+	return nil
+}
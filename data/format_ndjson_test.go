@@ -0,0 +1,203 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/iql/types"
+)
+
+var ndjsonInput = `{"id": 1, "name": "a", "tags": ["x"]}
+
+{"id": 2, "name": "b", "score": 1.5}
+{"id": 3, "score": 2}
+`
+
+func newNDJSON(input, filter string, columns []types.ColumnSelector) (
+	types.Source, error) {
+	return NewNDJSON([]io.ReadCloser{
+		ioutil.NopCloser(strings.NewReader(input)),
+	}, filter, columns)
+}
+
+func TestNDJSONSelectAll(t *testing.T) {
+	source, err := newNDJSON(ndjsonInput, "", nil)
+	if err != nil {
+		t.Fatalf("NewNDJSON failed: %s", err)
+	}
+	var names []string
+	for _, col := range source.Columns() {
+		names = append(names, col.Name.Column)
+	}
+	if strings.Join(names, ",") != "id,name,score,tags" {
+		t.Errorf("got columns %v", names)
+	}
+	expected := []types.Type{types.Int, types.String, types.Float, types.Array}
+	for idx, col := range source.Columns() {
+		if col.Type != expected[idx] {
+			t.Errorf("column %s: got type %s, expected %s",
+				col, col.Type, expected[idx])
+		}
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	values := [][]string{
+		{"1", "a", "NULL", "[x]"},
+		{"2", "b", "1.5", "NULL"},
+		{"3", "NULL", "2", "NULL"},
+	}
+	if len(rows) != len(values) {
+		t.Fatalf("got %d rows, expected %d", len(rows), len(values))
+	}
+	for rowIdx, row := range rows {
+		for colIdx, col := range row {
+			if col.String() != values[rowIdx][colIdx] {
+				t.Errorf("%d.%d: got '%s', expected '%s'", rowIdx, colIdx,
+					col.String(), values[rowIdx][colIdx])
+			}
+		}
+	}
+}
+
+func TestNDJSONFilter(t *testing.T) {
+	input := `{"items": [{"v": 1}, {"v": 2}]}
+{"items": [{"v": 3}]}
+`
+	source, err := newNDJSON(input, "items", xmlColumns("v"))
+	if err != nil {
+		t.Fatalf("NewNDJSON failed: %s", err)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	if len(rows) != 3 {
+		t.Errorf("got %d rows, expected 3", len(rows))
+	}
+}
+
+func TestNDJSONStreaming(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < DefaultSampleSize; i++ {
+		sb.WriteString(`{"v": 1}` + "\n")
+	}
+	sb.WriteString(`{"v": "string"}` + "\n")
+
+	source, err := newNDJSON(sb.String(), "", xmlColumns("v"))
+	if err != nil {
+		t.Fatalf("NewNDJSON failed: %s", err)
+	}
+	if source.Columns()[0].Type != types.Int {
+		t.Errorf("got type %s, expected %s", source.Columns()[0].Type,
+			types.Int)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	if rows[len(rows)-1][0].String() != "string" {
+		t.Errorf("got value %s, expected string", rows[len(rows)-1][0])
+	}
+	if source.Columns()[0].Type != types.String {
+		t.Errorf("column type not widened: got %s, expected %s",
+			source.Columns()[0].Type, types.String)
+	}
+}
+
+func TestNDJSONKeyUnion(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < DefaultSampleSize; i++ {
+		sb.WriteString(`{"v": 1}` + "\n")
+	}
+	sb.WriteString(`{"v": 2, "w": 3}` + "\n")
+	file := writeTestFile(t, "keys.ndjson", []byte(sb.String()))
+
+	for _, name := range []string{"file", "stream"} {
+		var source types.Source
+		var err error
+		if name == "file" {
+			source, err = New([]string{file}, "", nil)
+		} else {
+			source, err = newNDJSON(sb.String(), "", nil)
+		}
+		if err != nil {
+			t.Fatalf("%s: NewNDJSON failed: %s", name, err)
+		}
+		var names []string
+		for _, col := range source.Columns() {
+			names = append(names, col.Name.Column)
+		}
+		if strings.Join(names, ",") != "v,w" {
+			t.Errorf("%s: got columns %v", name, names)
+		}
+		rows, err := source.Get()
+		if err != nil {
+			t.Fatalf("%s: Get failed: %s", name, err)
+		}
+		if len(rows) != DefaultSampleSize+1 {
+			t.Fatalf("%s: got %d rows, expected %d", name, len(rows),
+				DefaultSampleSize+1)
+		}
+		if rows[0][1].String() != "NULL" {
+			t.Errorf("%s: got w=%s, expected NULL", name, rows[0][1])
+		}
+		last := rows[len(rows)-1]
+		if last[0].String() != "2" || last[1].String() != "3" {
+			t.Errorf("%s: got last row %v, expected [2 3]", name, last)
+		}
+	}
+}
+
+var ndjsonErrorTests = []struct {
+	input string
+	err   string
+}{
+	{
+		input: "{\"v\": 1}\n{\"v\": \n",
+		err:   "ndjson: line 2:",
+	},
+	{
+		input: "{\"v\": 1}\n\n{\"v\": 2} x\n",
+		err:   "ndjson: line 3: trailing data",
+	},
+	{
+		input: "{\"v\": 1}\n[1]\n",
+		err:   "ndjson: line 2: 'SELECT *'",
+	},
+}
+
+func TestNDJSONErrors(t *testing.T) {
+	for _, test := range ndjsonErrorTests {
+		_, err := newNDJSON(test.input, "", nil)
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("%q: expected error '%s', got: %v", test.input,
+				test.err, err)
+		}
+	}
+}
+
+func TestNDJSONFormat(t *testing.T) {
+	source, err := New([]string{
+		"data:application/x-ndjson;base64,eyJhIjoxfQp7ImEiOjJ9Cg==",
+	}, "", nil)
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	if len(rows) != 2 {
+		t.Errorf("got %d rows, expected 2", len(rows))
+	}
+}
@@ -0,0 +1,984 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"math/bits"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/markkurossi/iql/types"
+)
+
+// Parquet physical types.
+const (
+	parquetBoolean           = 0
+	parquetInt32             = 1
+	parquetInt64             = 2
+	parquetInt96             = 3
+	parquetFloat             = 4
+	parquetDouble            = 5
+	parquetByteArray         = 6
+	parquetFixedLenByteArray = 7
+)
+
+// Parquet repetition types.
+const (
+	parquetRequired = 0
+	parquetOptional = 1
+	parquetRepeated = 2
+)
+
+// Parquet page types.
+const (
+	parquetDataPage       = 0
+	parquetIndexPage      = 1
+	parquetDictionaryPage = 2
+	parquetDataPageV2     = 3
+)
+
+// Parquet encodings.
+const (
+	parquetPlain           = 0
+	parquetPlainDictionary = 2
+	parquetRLE             = 3
+	parquetRLEDictionary   = 8
+)
+
+var parquetCodecs = map[int64]string{
+	0: "uncompressed",
+	1: "snappy",
+	2: "gzip",
+	3: "lzo",
+	4: "brotli",
+	5: "lz4",
+	6: "zstd",
+	7: "lz4_raw",
+}
+
+// parquetKind specifies how the physical values of a column are
+// converted to IQL values. It is resolved from the converted type and
+// the logical type annotations of the column.
+type parquetKind int
+
+const (
+	parquetKindNone parquetKind = iota
+	parquetKindDate
+	parquetKindMillis
+	parquetKindMicros
+	parquetKindNanos
+	parquetKindDecimal
+	parquetKindUnsigned
+	parquetKindUUID
+)
+
+// julianUnixEpoch is the Julian day number of the Unix epoch.
+const julianUnixEpoch = 2440588
+
+// parquetColumn defines a leaf column of the Parquet schema.
+type parquetColumn struct {
+	name       string
+	index      int
+	physical   int64
+	typeLength int
+	kind       parquetKind
+	scale      int
+	maxDef     int
+	repeated   bool
+	iqlType    types.Type
+}
+
+// parquetFile holds an opened Parquet file.
+type parquetFile struct {
+	input     io.ReaderAt
+	size      int64
+	closer    io.Closer
+	columns   []*parquetColumn
+	rowGroups []thriftFields
+	selected  []*parquetColumn
+}
+
+// parquetReader reads the Parquet row groups as batches.
+type parquetReader struct {
+	files    []*parquetFile
+	fileIdx  int
+	groupIdx int
+}
+
+// NewParquet creates a new Parquet data source from the input. The
+// column types are resolved from the Parquet schema and only the
+// selected columns are read from the input. The row groups whose
+// column statistics show that their rows can't match the source
+// predicates are skipped. The source supports flat schemas and nested
+// groups that are not repeated. The nested columns are named by their
+// dot-separated paths.
+func NewParquet(input []io.ReadCloser, filter string,
+	columns []types.ColumnSelector) (types.Source, error) {
+
+	reader := new(parquetReader)
+
+	if len(filter) > 0 {
+		for _, in := range input {
+			in.Close()
+		}
+		return nil, fmt.Errorf("parquet: filter not supported: %s", filter)
+	}
+	if len(input) == 0 {
+		return nil, errors.New("parquet: no input")
+	}
+	for idx, in := range input {
+		f, err := openParquet(in)
+		if err != nil {
+			reader.close()
+			for _, rest := range input[idx+1:] {
+				rest.Close()
+			}
+			return nil, err
+		}
+		reader.files = append(reader.files, f)
+	}
+
+	// Resolve the columns from the schema of the first file.
+	if len(columns) == 0 {
+		for _, col := range reader.files[0].columns {
+			columns = append(columns, types.ColumnSelector{
+				Name: types.Reference{
+					Column: col.name,
+				},
+			})
+		}
+	}
+	for idx, f := range reader.files {
+		for i, col := range columns {
+			pc, err := f.column(col.Name.Column)
+			if err != nil {
+				reader.close()
+				return nil, err
+			}
+			if idx > 0 && reader.files[0].selected[i].iqlType != pc.iqlType {
+				reader.close()
+				return nil, fmt.Errorf("parquet: column %s: type mismatch: "+
+					"%s, %s", pc.name, reader.files[0].selected[i].iqlType,
+					pc.iqlType)
+			}
+			f.selected = append(f.selected, pc)
+		}
+	}
+	for i := range columns {
+		columns[i].Type = reader.files[0].selected[i].iqlType
+	}
+
+	return &columnar{
+		name:    "parquet",
+		columns: columns,
+		reader:  reader,
+	}, nil
+}
+
+// openParquet opens the Parquet file and reads its metadata.
+func openParquet(in io.ReadCloser) (*parquetFile, error) {
+	input, size, closer, err := openReaderAt(in)
+	if err != nil {
+		return nil, err
+	}
+	f := &parquetFile{
+		input:  input,
+		size:   size,
+		closer: closer,
+	}
+	if err := f.readMetadata(); err != nil {
+		f.close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *parquetFile) readMetadata() error {
+	if f.size < 12 {
+		return errors.New("parquet: file too short")
+	}
+	var magic [4]byte
+	if _, err := f.input.ReadAt(magic[:], 0); err != nil {
+		return fmt.Errorf("parquet: %s", err)
+	}
+	if string(magic[:]) != "PAR1" {
+		return errors.New("parquet: invalid file magic")
+	}
+	var trailer [8]byte
+	if _, err := f.input.ReadAt(trailer[:], f.size-8); err != nil {
+		return fmt.Errorf("parquet: %s", err)
+	}
+	switch string(trailer[4:]) {
+	case "PAR1":
+	case "PARE":
+		return errors.New("parquet: encrypted files not supported")
+	default:
+		return errors.New("parquet: invalid file trailer")
+	}
+	length := int64(binary.LittleEndian.Uint32(trailer[:4]))
+	if length > f.size-12 {
+		return errors.New("parquet: invalid metadata length")
+	}
+	data := make([]byte, length)
+	if _, err := f.input.ReadAt(data, f.size-8-length); err != nil {
+		return fmt.Errorf("parquet: %s", err)
+	}
+	metadata, _, err := decodeThrift(data)
+	if err != nil {
+		return fmt.Errorf("parquet: metadata: %s", err)
+	}
+
+	schema := metadata.Structs(2)
+	if len(schema) == 0 {
+		return errors.New("parquet: empty schema")
+	}
+	idx := 1
+	err = f.parseSchema(schema, &idx, int(schema[0].Int(5, 0)), nil, 0, false)
+	if err != nil {
+		return err
+	}
+	f.rowGroups = metadata.Structs(4)
+	for _, rg := range f.rowGroups {
+		if len(rg.Structs(1)) != len(f.columns) {
+			return errors.New("parquet: row group column count mismatch")
+		}
+	}
+	return nil
+}
+
+// parseSchema parses count schema elements starting from the index
+// idx. The path, maxDef, and repeated specify the path of the parent
+// group, its maximum definition level, and if it is repeated.
+func (f *parquetFile) parseSchema(schema []thriftFields, idx *int, count int,
+	path []string, maxDef int, repeated bool) error {
+
+	for i := 0; i < count; i++ {
+		if *idx >= len(schema) {
+			return errors.New("parquet: truncated schema")
+		}
+		el := schema[*idx]
+		*idx++
+
+		elPath := append(append([]string(nil), path...), el.String(4))
+		elDef := maxDef
+		elRepeated := repeated
+		switch el.Int(3, parquetRequired) {
+		case parquetOptional:
+			elDef++
+		case parquetRepeated:
+			elDef++
+			elRepeated = true
+		}
+		children := int(el.Int(5, 0))
+		if children > 0 {
+			err := f.parseSchema(schema, idx, children, elPath, elDef,
+				elRepeated)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		col := &parquetColumn{
+			name:       strings.Join(elPath, "."),
+			index:      len(f.columns),
+			physical:   el.Int(1, -1),
+			typeLength: int(el.Int(2, 0)),
+			scale:      int(el.Int(7, 0)),
+			maxDef:     elDef,
+			repeated:   elRepeated,
+		}
+		col.resolveKind(el.Int(6, -1), el.Struct(10))
+		f.columns = append(f.columns, col)
+	}
+	return nil
+}
+
+// resolveKind resolves the column kind and its IQL type from the
+// converted type and the logical type of the column.
+func (col *parquetColumn) resolveKind(converted int64, logical thriftFields) {
+	switch {
+	case logical.Has(6) || converted == 6:
+		col.kind = parquetKindDate
+
+	case logical.Has(8):
+		unit := logical.Struct(8).Struct(2)
+		switch {
+		case unit.Has(1):
+			col.kind = parquetKindMillis
+		case unit.Has(3):
+			col.kind = parquetKindNanos
+		default:
+			col.kind = parquetKindMicros
+		}
+
+	case converted == 9:
+		col.kind = parquetKindMillis
+
+	case converted == 10:
+		col.kind = parquetKindMicros
+
+	case logical.Has(5):
+		col.kind = parquetKindDecimal
+		col.scale = int(logical.Struct(5).Int(1, int64(col.scale)))
+
+	case converted == 5:
+		col.kind = parquetKindDecimal
+
+	case logical.Has(10) && !logical.Struct(10).Bool(2, true):
+		col.kind = parquetKindUnsigned
+
+	case converted >= 11 && converted <= 14:
+		col.kind = parquetKindUnsigned
+
+	case logical.Has(14):
+		col.kind = parquetKindUUID
+	}
+
+	switch col.physical {
+	case parquetBoolean:
+		col.iqlType = types.Bool
+	case parquetInt32, parquetInt64:
+		col.iqlType = types.Int
+	case parquetInt96:
+		col.iqlType = types.Date
+	case parquetFloat, parquetDouble:
+		col.iqlType = types.Float
+	default:
+		col.iqlType = types.String
+	}
+	switch col.kind {
+	case parquetKindDate:
+		if col.physical == parquetInt32 {
+			col.iqlType = types.Date
+		}
+	case parquetKindMillis, parquetKindMicros, parquetKindNanos:
+		if col.physical == parquetInt64 {
+			col.iqlType = types.Date
+		}
+	case parquetKindDecimal:
+		if col.physical != parquetBoolean && col.physical != parquetFloat &&
+			col.physical != parquetDouble && col.physical != parquetInt96 {
+			col.iqlType = types.Float
+		}
+	}
+}
+
+// column returns the leaf column by its name.
+func (f *parquetFile) column(name string) (*parquetColumn, error) {
+	for _, col := range f.columns {
+		if col.name == name {
+			if col.repeated {
+				return nil, fmt.Errorf("parquet: repeated column %s "+
+					"not supported", name)
+			}
+			if col.physical < parquetBoolean ||
+				col.physical > parquetFixedLenByteArray {
+				return nil, fmt.Errorf("parquet: column %s: invalid type %d",
+					name, col.physical)
+			}
+			return col, nil
+		}
+	}
+	return nil, fmt.Errorf("parquet: unknown column: %s", name)
+}
+
+func (f *parquetFile) close() {
+	if f.closer != nil {
+		f.closer.Close()
+		f.closer = nil
+	}
+}
+
+func (r *parquetReader) close() {
+	for _, f := range r.files {
+		f.close()
+	}
+}
+
+// next implements the batchReader.next().
+func (r *parquetReader) next(predicates []types.Predicate) (
+	[]types.Row, error) {
+
+	for r.fileIdx < len(r.files) {
+		f := r.files[r.fileIdx]
+		if r.groupIdx >= len(f.rowGroups) {
+			f.close()
+			r.fileIdx++
+			r.groupIdx = 0
+			continue
+		}
+		rg := f.rowGroups[r.groupIdx]
+		r.groupIdx++
+
+		numRows := int(rg.Int(3, 0))
+		if numRows > f.maxValues() {
+			return nil, fmt.Errorf("parquet: invalid row count %d", numRows)
+		}
+		if numRows <= 0 || f.skip(rg, predicates) {
+			continue
+		}
+		chunks := rg.Structs(1)
+
+		rows := make([]types.Row, numRows)
+		for _, col := range f.selected {
+			values, err := f.readColumn(col, chunks[col.index])
+			if err != nil {
+				return nil, err
+			}
+			if len(values) != numRows {
+				return nil, fmt.Errorf("parquet: column %s: got %d values, "+
+					"expected %d", col.name, len(values), numRows)
+			}
+			for i, val := range values {
+				if val == types.Null {
+					rows[i] = append(rows[i], types.NullColumn{})
+				} else {
+					rows[i] = append(rows[i], types.NewValueColumn(val))
+				}
+			}
+		}
+		return rows, nil
+	}
+	return nil, io.EOF
+}
+
+// maxValues returns the maximum number of values that a row group or
+// a column chunk can have. The values take at least one bit, except
+// in the RLE runs, so the row and value counts of the file metadata
+// are bounded by the file size.
+func (f *parquetFile) maxValues() int {
+	return int(f.size)*8 + 1<<20
+}
+
+// skip tests if the row group can be skipped based on the column
+// statistics and the predicates.
+func (f *parquetFile) skip(rg thriftFields,
+	predicates []types.Predicate) bool {
+
+	chunks := rg.Structs(1)
+	for _, pred := range predicates {
+		col := f.selected[pred.Column]
+		stats := chunks[col.index].Struct(3).Struct(12)
+		if stats == nil {
+			continue
+		}
+		var min, max []byte
+		if stats.Has(5) && stats.Has(6) {
+			min = stats.Binary(6)
+			max = stats.Binary(5)
+		} else if col.signedStats() && stats.Has(1) && stats.Has(2) {
+			min = stats.Binary(2)
+			max = stats.Binary(1)
+		} else {
+			continue
+		}
+		if col.physical == parquetInt96 || col.kind == parquetKindUnsigned ||
+			(col.kind == parquetKindDecimal &&
+				col.physical != parquetInt32 && col.physical != parquetInt64) {
+			continue
+		}
+		minVal, err := col.statValue(min)
+		if err != nil {
+			continue
+		}
+		maxVal, err := col.statValue(max)
+		if err != nil {
+			continue
+		}
+		hasNull := stats.Int(3, -1) != 0
+		if !mayMatch(pred, minVal, maxVal, col.iqlType, hasNull) {
+			return true
+		}
+	}
+	return false
+}
+
+// signedStats tests if the legacy min and max statistics are valid
+// for the column. The legacy statistics use signed comparison.
+func (col *parquetColumn) signedStats() bool {
+	switch col.physical {
+	case parquetBoolean, parquetInt32, parquetInt64, parquetFloat,
+		parquetDouble:
+		return true
+	default:
+		return false
+	}
+}
+
+// statValue decodes the statistics value. The byte array statistics
+// are stored without the length prefix.
+func (col *parquetColumn) statValue(data []byte) (types.Value, error) {
+	if col.physical == parquetByteArray {
+		return col.value(data), nil
+	}
+	values, err := col.decodePlain(data, 1)
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+// readColumn reads the column chunk values.
+func (f *parquetFile) readColumn(col *parquetColumn, chunk thriftFields) (
+	[]types.Value, error) {
+
+	if chunk.Has(1) {
+		return nil, fmt.Errorf("parquet: column %s: external column chunks "+
+			"not supported", col.name)
+	}
+	md := chunk.Struct(3)
+	if md == nil {
+		return nil, fmt.Errorf("parquet: column %s: no metadata", col.name)
+	}
+	codec := md.Int(4, 0)
+	numValues := int(md.Int(5, 0))
+	if numValues < 0 || numValues > f.maxValues() {
+		return nil, fmt.Errorf("parquet: column %s: invalid value count %d",
+			col.name, numValues)
+	}
+	start := md.Int(9, 0)
+	if offset := md.Int(11, 0); offset > 0 && offset < start {
+		start = offset
+	}
+	length := md.Int(7, 0)
+	if start < 0 || length < 0 || length > f.size-start {
+		return nil, fmt.Errorf("parquet: column %s: invalid chunk", col.name)
+	}
+	data := make([]byte, length)
+	if _, err := f.input.ReadAt(data, start); err != nil {
+		return nil, fmt.Errorf("parquet: column %s: %s", col.name, err)
+	}
+
+	var dictionary []types.Value
+	var result []types.Value
+
+	for len(result) < numValues && len(data) > 0 {
+		header, n, err := decodeThrift(data)
+		if err != nil {
+			return nil, fmt.Errorf("parquet: column %s: page header: %s",
+				col.name, err)
+		}
+		size := int(header.Int(3, -1))
+		if size < 0 || size > len(data)-n {
+			return nil, fmt.Errorf("parquet: column %s: invalid page size",
+				col.name)
+		}
+		page := data[n : n+size]
+		data = data[n+size:]
+
+		switch header.Int(1, -1) {
+		case parquetDictionaryPage:
+			dh := header.Struct(7)
+			page, err = decompressPage(codec, page)
+			if err != nil {
+				return nil, fmt.Errorf("parquet: column %s: %s", col.name, err)
+			}
+			// The PLAIN encoded values take at least one bit.
+			count := int(dh.Int(1, 0))
+			if count < 0 || count > len(page)*8 {
+				return nil, fmt.Errorf("parquet: column %s: invalid "+
+					"dictionary size %d", col.name, count)
+			}
+			dictionary, err = col.decodePlain(page, count)
+			if err != nil {
+				return nil, fmt.Errorf("parquet: column %s: dictionary: %s",
+					col.name, err)
+			}
+
+		case parquetDataPage:
+			dh := header.Struct(5)
+			page, err = decompressPage(codec, page)
+			if err != nil {
+				return nil, fmt.Errorf("parquet: column %s: %s", col.name, err)
+			}
+			count := int(dh.Int(1, 0))
+			if count < 0 || count > numValues-len(result) {
+				return nil, fmt.Errorf("parquet: column %s: invalid page "+
+					"value count %d", col.name, count)
+			}
+			var levels []int
+			if col.maxDef > 0 {
+				if enc := dh.Int(3, parquetRLE); enc != parquetRLE {
+					return nil, fmt.Errorf("parquet: column %s: unsupported "+
+						"definition level encoding %d", col.name, enc)
+				}
+				levels, page, err = decodeLevels(page, col.maxDef, count)
+				if err != nil {
+					return nil, fmt.Errorf("parquet: column %s: %s",
+						col.name, err)
+				}
+			}
+			values, err := col.decodePage(page, dh.Int(2, parquetPlain),
+				levels, count, dictionary)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, values...)
+
+		case parquetDataPageV2:
+			dh := header.Struct(8)
+			count := int(dh.Int(1, 0))
+			if count < 0 || count > numValues-len(result) {
+				return nil, fmt.Errorf("parquet: column %s: invalid page "+
+					"value count %d", col.name, count)
+			}
+			defLen := int(dh.Int(5, 0))
+			repLen := int(dh.Int(6, 0))
+			if defLen < 0 || repLen < 0 || repLen > len(page) ||
+				defLen > len(page)-repLen {
+				return nil, fmt.Errorf("parquet: column %s: invalid levels",
+					col.name)
+			}
+			var levels []int
+			if col.maxDef > 0 {
+				levels, err = decodeRLE(page[repLen:repLen+defLen],
+					bits.Len(uint(col.maxDef)), count)
+				if err != nil {
+					return nil, fmt.Errorf("parquet: column %s: %s",
+						col.name, err)
+				}
+			}
+			page = page[repLen+defLen:]
+			if dh.Bool(7, true) {
+				page, err = decompressPage(codec, page)
+				if err != nil {
+					return nil, fmt.Errorf("parquet: column %s: %s",
+						col.name, err)
+				}
+			}
+			values, err := col.decodePage(page, dh.Int(4, parquetPlain),
+				levels, count, dictionary)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, values...)
+		}
+	}
+	return result, nil
+}
+
+// decodePage decodes the values of the data page. The levels hold
+// the definition levels of the values or nil if the column is
+// required.
+func (col *parquetColumn) decodePage(data []byte, encoding int64,
+	levels []int, count int, dictionary []types.Value) (
+	[]types.Value, error) {
+
+	nonNull := count
+	if levels != nil {
+		nonNull = 0
+		for _, level := range levels {
+			if level == col.maxDef {
+				nonNull++
+			}
+		}
+	}
+
+	var values []types.Value
+	var err error
+
+	switch encoding {
+	case parquetPlain:
+		values, err = col.decodePlain(data, nonNull)
+
+	case parquetPlainDictionary, parquetRLEDictionary:
+		if dictionary == nil {
+			return nil, fmt.Errorf("parquet: column %s: no dictionary",
+				col.name)
+		}
+		if len(data) == 0 {
+			if nonNull > 0 {
+				err = errors.New("truncated dictionary indices")
+			}
+			break
+		}
+		var indices []int
+		indices, err = decodeRLE(data[1:], int(data[0]), nonNull)
+		if err != nil {
+			break
+		}
+		for _, idx := range indices {
+			if idx >= len(dictionary) {
+				err = fmt.Errorf("invalid dictionary index %d", idx)
+				break
+			}
+			values = append(values, dictionary[idx])
+		}
+
+	case parquetRLE:
+		if col.physical != parquetBoolean {
+			err = errors.New("RLE encoding for non-boolean values")
+			break
+		}
+		var bools []int
+		bools, _, err = decodeLevels(data, 1, nonNull)
+		for _, b := range bools {
+			values = append(values, types.BoolValue(b != 0))
+		}
+
+	default:
+		err = fmt.Errorf("unsupported encoding %d", encoding)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parquet: column %s: %s", col.name, err)
+	}
+
+	if levels == nil {
+		return values, nil
+	}
+	result := make([]types.Value, 0, count)
+	for _, level := range levels {
+		if level == col.maxDef {
+			result = append(result, values[0])
+			values = values[1:]
+		} else {
+			result = append(result, types.Null)
+		}
+	}
+	return result, nil
+}
+
+// decodePlain decodes count PLAIN encoded values.
+func (col *parquetColumn) decodePlain(data []byte, count int) (
+	[]types.Value, error) {
+
+	var size int
+	switch col.physical {
+	case parquetBoolean:
+		if len(data)*8 < count {
+			return nil, errShortPlain
+		}
+		var result []types.Value
+		for i := 0; i < count; i++ {
+			result = append(result, types.BoolValue(data[i/8]&(1<<(i%8)) != 0))
+		}
+		return result, nil
+
+	case parquetInt32, parquetFloat:
+		size = 4
+	case parquetInt64, parquetDouble:
+		size = 8
+	case parquetInt96:
+		size = 12
+	case parquetFixedLenByteArray:
+		size = col.typeLength
+
+	case parquetByteArray:
+		var result []types.Value
+		for i := 0; i < count; i++ {
+			if len(data) < 4 {
+				return nil, errShortPlain
+			}
+			n := int(binary.LittleEndian.Uint32(data))
+			if n < 0 || n > len(data)-4 {
+				return nil, errShortPlain
+			}
+			result = append(result, col.value(data[4:4+n]))
+			data = data[4+n:]
+		}
+		return result, nil
+	}
+	if size <= 0 || len(data)/size < count {
+		return nil, errShortPlain
+	}
+	var result []types.Value
+	for i := 0; i < count; i++ {
+		result = append(result, col.value(data[i*size:(i+1)*size]))
+	}
+	return result, nil
+}
+
+var errShortPlain = errors.New("truncated PLAIN values")
+
+// value converts the physical value to IQL value.
+func (col *parquetColumn) value(data []byte) types.Value {
+	switch col.physical {
+	case parquetInt32:
+		v := int32(binary.LittleEndian.Uint32(data))
+		switch col.kind {
+		case parquetKindDate:
+			return types.DateValue(time.Unix(int64(v)*24*60*60, 0).UTC())
+		case parquetKindDecimal:
+			return types.FloatValue(float64(v) / math.Pow10(col.scale))
+		case parquetKindUnsigned:
+			return types.IntValue(uint32(v))
+		}
+		return types.IntValue(v)
+
+	case parquetInt64:
+		v := int64(binary.LittleEndian.Uint64(data))
+		switch col.kind {
+		case parquetKindMillis:
+			return types.DateValue(time.Unix(0, 0).Add(
+				time.Duration(v) * time.Millisecond).UTC())
+		case parquetKindMicros:
+			return types.DateValue(time.Unix(0, 0).Add(
+				time.Duration(v) * time.Microsecond).UTC())
+		case parquetKindNanos:
+			return types.DateValue(time.Unix(0, v).UTC())
+		case parquetKindDecimal:
+			return types.FloatValue(float64(v) / math.Pow10(col.scale))
+		}
+		return types.IntValue(v)
+
+	case parquetInt96:
+		nanos := int64(binary.LittleEndian.Uint64(data))
+		day := int64(binary.LittleEndian.Uint32(data[8:]))
+		return types.DateValue(time.Unix((day-julianUnixEpoch)*24*60*60,
+			nanos).UTC())
+
+	case parquetFloat:
+		return types.FloatValue(
+			math.Float32frombits(binary.LittleEndian.Uint32(data)))
+
+	case parquetDouble:
+		return types.FloatValue(
+			math.Float64frombits(binary.LittleEndian.Uint64(data)))
+
+	case parquetBoolean:
+		return types.BoolValue(len(data) > 0 && data[0] != 0)
+	}
+
+	// Byte arrays.
+	switch col.kind {
+	case parquetKindDecimal:
+		return types.FloatValue(decimalValue(data, col.scale))
+
+	case parquetKindUUID:
+		if len(data) == 16 {
+			return types.StringValue(fmt.Sprintf("%x-%x-%x-%x-%x",
+				data[0:4], data[4:6], data[6:8], data[8:10], data[10:]))
+		}
+	}
+	return types.StringValue(data)
+}
+
+// decimalValue converts the big-endian two's complement unscaled
+// decimal value to float.
+func decimalValue(data []byte, scale int) float64 {
+	v := new(big.Int).SetBytes(data)
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(len(data)*8)))
+	}
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(v),
+		new(big.Float).SetFloat64(math.Pow10(scale))).Float64()
+	return f
+}
+
+// decodeLevels decodes count RLE encoded levels that are prefixed
+// with their 4-byte length. The function returns the levels and the
+// data following the levels.
+func decodeLevels(data []byte, maxLevel, count int) ([]int, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, errors.New("truncated levels")
+	}
+	n := int(binary.LittleEndian.Uint32(data))
+	if n < 0 || n > len(data)-4 {
+		return nil, nil, errors.New("truncated levels")
+	}
+	levels, err := decodeRLE(data[4:4+n], bits.Len(uint(maxLevel)), count)
+	if err != nil {
+		return nil, nil, err
+	}
+	return levels, data[4+n:], nil
+}
+
+// decodeRLE decodes count values from the RLE and bit-packing hybrid
+// encoded data.
+func decodeRLE(data []byte, bitWidth, count int) ([]int, error) {
+	if bitWidth < 0 || bitWidth > 32 {
+		return nil, fmt.Errorf("invalid bit width %d", bitWidth)
+	}
+	if count < 0 {
+		return nil, fmt.Errorf("invalid value count %d", count)
+	}
+	// The RLE runs can encode any number of values so the result is
+	// preallocated only for the values of the bit-packed runs.
+	capacity := count
+	if capacity > len(data)*8 {
+		capacity = len(data) * 8
+	}
+	result := make([]int, 0, capacity)
+	for len(result) < count {
+		header, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.New("truncated RLE data")
+		}
+		data = data[n:]
+		if header&1 == 0 {
+			// RLE run.
+			run := int(header >> 1)
+			width := (bitWidth + 7) / 8
+			if len(data) < width {
+				return nil, errors.New("truncated RLE run")
+			}
+			var v int
+			for i := 0; i < width; i++ {
+				v |= int(data[i]) << (8 * i)
+			}
+			data = data[width:]
+			for i := 0; i < run && len(result) < count; i++ {
+				result = append(result, v)
+			}
+		} else {
+			// Bit-packed groups of 8 values.
+			values := int(header>>1) * 8
+			size := values * bitWidth / 8
+			if len(data) < size {
+				return nil, errors.New("truncated bit-packed run")
+			}
+			var bit int
+			for i := 0; i < values && len(result) < count; i++ {
+				var v int
+				for j := 0; j < bitWidth; j++ {
+					if data[bit/8]&(1<<(bit%8)) != 0 {
+						v |= 1 << j
+					}
+					bit++
+				}
+				result = append(result, v)
+			}
+			data = data[size:]
+		}
+	}
+	return result, nil
+}
+
+// decompressPage decompresses the page data with the codec.
+func decompressPage(codec int64, data []byte) ([]byte, error) {
+	switch codec {
+	case 0:
+		return data, nil
+
+	case 1:
+		return snappy.Decode(nil, data)
+
+	case 2:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+
+	default:
+		name, ok := parquetCodecs[codec]
+		if !ok {
+			name = fmt.Sprintf("%d", codec)
+		}
+		return nil, fmt.Errorf("unsupported compression codec %s", name)
+	}
+}
@@ -0,0 +1,282 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/iql/types"
+)
+
+var parquetRows = [][]string{
+	{"1", "alpha", "1.5", "true", "2021-01-01 00:00:00",
+		"2021-01-01 12:00:00"},
+	{"2", "NULL", "2.5", "false", "NULL", "2021-01-01 12:00:01.5"},
+	{"3", "gamma", "NULL", "true", "2021-01-02 00:00:00",
+		"2021-01-02 12:00:00"},
+	{"4", "delta", "10", "false", "2021-03-14 00:00:00", "NULL"},
+	{"5", "alpha", "20", "false", "2021-03-15 00:00:00", "NULL"},
+}
+
+func TestParquet(t *testing.T) {
+	source, err := New([]string{"test.parquet"}, "", nil)
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+	expected := []struct {
+		name string
+		t    types.Type
+	}{
+		{"id", types.Int},
+		{"name", types.String},
+		{"price", types.Float},
+		{"active", types.Bool},
+		{"day", types.Date},
+		{"ts", types.Date},
+	}
+	columns := source.Columns()
+	if len(columns) != len(expected) {
+		t.Fatalf("got %d columns, expected %d", len(columns), len(expected))
+	}
+	for i, col := range columns {
+		if col.Name.Column != expected[i].name || col.Type != expected[i].t {
+			t.Errorf("column %d: got %s %s, expected %s %s", i,
+				col.Name.Column, col.Type, expected[i].name, expected[i].t)
+		}
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	verifyRows(t, rows, parquetRows)
+}
+
+func verifyRows(t *testing.T, rows []types.Row, expected [][]string) {
+	t.Helper()
+	if len(rows) != len(expected) {
+		t.Fatalf("got %d rows, expected %d", len(rows), len(expected))
+	}
+	for i, row := range rows {
+		if len(row) != len(expected[i]) {
+			t.Fatalf("row %d: got %d columns, expected %d",
+				i, len(row), len(expected[i]))
+		}
+		for j, col := range row {
+			if col.String() != expected[i][j] {
+				t.Errorf("row %d: column %d: got %s, expected %s",
+					i, j, col, expected[i][j])
+			}
+		}
+	}
+}
+
+var parquetFilterTests = []struct {
+	pred types.Predicate
+	rows [][]string
+}{
+	{
+		pred: types.Predicate{
+			Column: 0,
+			Op:     types.OpGt,
+			Value:  types.IntValue(3),
+		},
+		rows: [][]string{{"4", "delta"}, {"5", "alpha"}},
+	},
+	{
+		pred: types.Predicate{
+			Column: 1,
+			Op:     types.OpEq,
+			Value:  types.StringValue("gamma"),
+		},
+		rows: [][]string{{"3", "gamma"}},
+	},
+	{
+		pred: types.Predicate{
+			Column: 1,
+			Op:     types.OpEq,
+			Value:  types.StringValue("alpha"),
+		},
+		rows: [][]string{{"1", "alpha"}, {"5", "alpha"}},
+	},
+}
+
+func TestParquetFilter(t *testing.T) {
+	for idx, test := range parquetFilterTests {
+		source, err := New([]string{"test.parquet"}, "",
+			xmlColumns("id", "name"))
+		if err != nil {
+			t.Fatalf("test %d: New failed: %s", idx, err)
+		}
+		if !source.(types.Filterable).Filter(test.pred) {
+			t.Fatalf("test %d: Filter(%s) failed", idx, test.pred)
+		}
+		rows, err := source.Get()
+		if err != nil {
+			t.Fatalf("test %d: Get failed: %s", idx, err)
+		}
+		verifyRows(t, rows, test.rows)
+	}
+}
+
+func openTestFile(t *testing.T, name string) io.ReadCloser {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("failed to open %s: %s", name, err)
+	}
+	return f
+}
+
+func TestParquetSkip(t *testing.T) {
+	f, err := openParquet(openTestFile(t, "test.parquet"))
+	if err != nil {
+		t.Fatalf("openParquet failed: %s", err)
+	}
+	defer f.close()
+
+	id, _ := f.column("id")
+	name, _ := f.column("name")
+	price, _ := f.column("price")
+	f.selected = []*parquetColumn{id, name, price}
+
+	tests := []struct {
+		pred types.Predicate
+		skip []bool
+	}{
+		{types.Predicate{Column: 0, Op: types.OpEq, Value: types.IntValue(2)},
+			[]bool{false, true}},
+		{types.Predicate{Column: 0, Op: types.OpLt, Value: types.IntValue(4)},
+			[]bool{false, true}},
+		{types.Predicate{Column: 0, Op: types.OpGe, Value: types.IntValue(4)},
+			[]bool{true, false}},
+		{types.Predicate{Column: 0, Op: types.OpNEq, Value: types.IntValue(4)},
+			[]bool{false, false}},
+		{types.Predicate{Column: 1, Op: types.OpEq,
+			Value: types.StringValue("beta")}, []bool{false, false}},
+		{types.Predicate{Column: 1, Op: types.OpGt,
+			Value: types.StringValue("e")}, []bool{false, true}},
+		{types.Predicate{Column: 2, Op: types.OpGt,
+			Value: types.FloatValue(2.5)}, []bool{true, false}},
+		{types.Predicate{Column: 2, Op: types.OpEq, Value: types.Null},
+			[]bool{false, false}},
+	}
+	for idx, test := range tests {
+		for i, rg := range f.rowGroups {
+			skip := f.skip(rg, []types.Predicate{test.pred})
+			if skip != test.skip[i] {
+				t.Errorf("test %d: %s: row group %d: skip=%v, expected %v",
+					idx, test.pred, i, skip, test.skip[i])
+			}
+		}
+	}
+}
+
+func TestParquetErrors(t *testing.T) {
+	for _, test := range []struct {
+		filter  string
+		columns []types.ColumnSelector
+	}{
+		{"id", nil},
+		{"", xmlColumns("missing")},
+	} {
+		_, err := New([]string{"test.parquet"}, test.filter, test.columns)
+		if err == nil {
+			t.Errorf("New(%q, %v) succeeded", test.filter, test.columns)
+		}
+	}
+	file := writeTestFile(t, "bad.parquet", []byte("PAR1 not parquet PAR1"))
+	_, err := New([]string{file}, "", nil)
+	if err == nil {
+		t.Errorf("invalid file accepted")
+	}
+}
+
+func TestParquetCorrupted(t *testing.T) {
+	data, err := ioutil.ReadFile("test.parquet")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %s", err)
+	}
+	get := func(name string, data []byte) error {
+		file := writeTestFile(t, name, data)
+		source, err := New([]string{file}, "", nil)
+		if err != nil {
+			return err
+		}
+		_, err = source.Get()
+		return err
+	}
+
+	// The byte 84 is the value count of the first data page of the
+	// name column.
+	for _, count := range []byte{0x7f, 0x7e} {
+		corrupted := append([]byte(nil), data...)
+		corrupted[84] = count
+		if err := get("count.parquet", corrupted); err == nil {
+			t.Errorf("invalid page value count %#x accepted", count)
+		}
+	}
+
+	// The first row group's num_rows follows its total_byte_size in
+	// the footer. Replace it with 2^40 and update the footer length.
+	footer := []byte{0x16, 0xde, 0x03, 0x16, 0x06}
+	idx := bytes.Index(data, footer)
+	if idx < 0 {
+		t.Fatalf("row group num_rows not found")
+	}
+	var numRows [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(numRows[:], 1<<41)
+	corrupted := append([]byte(nil), data[:idx+4]...)
+	corrupted = append(corrupted, numRows[:n]...)
+	corrupted = append(corrupted, data[idx+5:]...)
+	footerLen := corrupted[len(corrupted)-8 : len(corrupted)-4]
+	binary.LittleEndian.PutUint32(footerLen,
+		binary.LittleEndian.Uint32(footerLen)+uint32(n-1))
+	err = get("rows.parquet", corrupted)
+	if err == nil || !strings.Contains(err.Error(), "invalid row count") {
+		t.Errorf("invalid row count accepted: %v", err)
+	}
+
+	// Corrupted bytes must not crash the decoder.
+	for i := range data {
+		for _, b := range []byte{0x00, 0x7f, 0xff} {
+			corrupted := append([]byte(nil), data...)
+			corrupted[i] = b
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("byte %d=%#x: %v", i, b, r)
+					}
+				}()
+				get("corrupted.parquet", corrupted)
+			}()
+		}
+	}
+}
+
+func TestThriftNesting(t *testing.T) {
+	// A struct with a list field of nested single-element lists.
+	data := bytes.Repeat([]byte{0x19}, 1<<20)
+	_, _, err := decodeThrift(data)
+	if err == nil || !strings.Contains(err.Error(), "too deep nesting") {
+		t.Errorf("nested lists accepted: %v", err)
+	}
+
+	// A map of maps.
+	data = []byte{0x1b}
+	for i := 0; i < 1<<20; i++ {
+		data = append(data, 0x01, 0xbb)
+	}
+	_, _, err = decodeThrift(data)
+	if err == nil || !strings.Contains(err.Error(), "too deep nesting") {
+		t.Errorf("nested maps accepted: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/markkurossi/iql/types"
+)
+
+var (
+	testFormat     Format
+	testFormatErr  error
+	testFormatOnce sync.Once
+)
+
+// registerTestFormat registers the 'psv' format for pipe-separated
+// values with the magic "Name|". The format is registered only once
+// since the registry is global.
+func registerTestFormat() (Format, error) {
+	testFormatOnce.Do(func() {
+		testFormat, testFormatErr = Register("psv", []string{".psv"},
+			[]string{"text/x-psv"},
+			func(in []io.ReadCloser, filter string,
+				columns []types.ColumnSelector) (types.Source, error) {
+				return NewCSV(in, "comma=| "+filter, columns)
+			})
+		if testFormatErr == nil {
+			testFormatErr = RegisterMagic(testFormat, []byte("Name|"))
+		}
+	})
+	return testFormat, testFormatErr
+}
+
+func TestRegister(t *testing.T) {
+	format, err := registerTestFormat()
+	if err != nil {
+		t.Fatalf("Register failed: %s", err)
+	}
+	if format.String() != "psv" {
+		t.Errorf("String: got %s, expected psv", format)
+	}
+	f, err := ParseFormat("PSV")
+	if err != nil || f != format {
+		t.Errorf("ParseFormat(PSV): got %s, %v", f, err)
+	}
+
+	file := writeTestFile(t, "data.psv", []byte("Name|Value\na|1\nb|2\n"))
+	if n := countRows(t, file, nil); n != 2 {
+		t.Errorf("suffix: got %d rows, expected 2", n)
+	}
+	// Base64 of "Name|Value\na|1\n".
+	url := "data:text/x-psv;base64,TmFtZXxWYWx1ZQphfDEK"
+	if n := countRows(t, url, nil); n != 1 {
+		t.Errorf("media type: got %d rows, expected 1", n)
+	}
+	f, _ = sniffFormat([]byte("Name|Value\na|1\n"), true)
+	if f != format {
+		t.Errorf("magic: got %s, expected %s", f, format)
+	}
+}
+
+var registerErrors = []struct {
+	name       string
+	suffixes   []string
+	mediaTypes []string
+}{
+	{"", nil, nil},
+	{"CSV", nil, nil},
+	{"psv", nil, nil},
+	{"foo", []string{"foo"}, nil},
+	{"foo", []string{".csv"}, nil},
+	{"foo", []string{".gz"}, nil},
+	{"foo", nil, []string{"text/csv"}},
+	{"foo", nil, []string{"text/x-psv; charset=utf-8"}},
+	{"foo", nil, []string{"/"}},
+}
+
+func TestRegisterErrors(t *testing.T) {
+	if _, err := registerTestFormat(); err != nil {
+		t.Fatalf("Register failed: %s", err)
+	}
+	for idx, test := range registerErrors {
+		_, err := Register(test.name, test.suffixes, test.mediaTypes, NewCSV)
+		if err == nil {
+			t.Errorf("test %d: Register(%s) succeeded", idx, test.name)
+		}
+	}
+	_, err := Register("foo", nil, nil, nil)
+	if err == nil {
+		t.Errorf("Register without constructor succeeded")
+	}
+	if _, err := ParseFormat("foo"); err == nil {
+		t.Errorf("failed registration registered format")
+	}
+
+	for idx, test := range []struct {
+		format Format
+		magic  string
+	}{
+		{FormatCSV, ""},
+		{Format(1000), "FOO1"},
+		{FormatCSV, "PAR"},
+		{FormatCSV, "PAR1\x00"},
+	} {
+		if RegisterMagic(test.format, []byte(test.magic)) == nil {
+			t.Errorf("test %d: RegisterMagic(%s, %q) succeeded",
+				idx, test.format, test.magic)
+		}
+	}
+}
@@ -0,0 +1,714 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markkurossi/iql/types"
+)
+
+// XLSX implements a data source from Office Open XML spreadsheet
+// (.xlsx) files.
+type XLSX struct {
+	columns []types.ColumnSelector
+	rows    []types.Row
+}
+
+// xlsxOptions define the XLSX filter options.
+type xlsxOptions struct {
+	sheet   string
+	rng     *cellRange
+	headers bool
+}
+
+// cellRange defines a rectangular cell range. The column and row
+// numbers start from 1. The zero end row specifies a range that
+// extends to the last row of the sheet.
+type cellRange struct {
+	col0, row0 int
+	col1, row1 int
+}
+
+// NewXLSX creates a new XLSX data source from the input. The filter
+// selects the sheet and the cell range of the input rows:
+//
+//	sheet=NAME	select the sheet by name, default is the first sheet
+//	range=A1:F200	select the cell range, default is the used range
+//	noheaders	the first row of the range is not a header row
+//
+// If the sheet name contains spaces, it must be quoted with double
+// quotes. Without headers, the columns are selected by their column
+// letters or by their 0-based indices in the range.
+func NewXLSX(input []io.ReadCloser, filter string,
+	columns []types.ColumnSelector) (types.Source, error) {
+
+	options, err := parseXLSXFilter(filter)
+	if err != nil {
+		for _, in := range input {
+			in.Close()
+		}
+		return nil, err
+	}
+	if len(input) == 0 {
+		return nil, errors.New("xlsx: no input")
+	}
+
+	var rows []types.Row
+
+	fail := func(idx int, err error) (types.Source, error) {
+		for _, in := range input[idx+1:] {
+			in.Close()
+		}
+		return nil, err
+	}
+
+	for idx, in := range input {
+		grid, c0, err := readXLSX(in, options)
+		if err != nil {
+			return fail(idx, err)
+		}
+		if options.headers {
+			if len(grid) == 0 {
+				return fail(idx, errors.New("xlsx: no records"))
+			}
+			var header []string
+			for i, val := range grid[0] {
+				name := strings.TrimSpace(val.String())
+				if val == types.Null || len(name) == 0 {
+					name = columnName(c0 + i)
+				}
+				header = append(header, name)
+			}
+			grid = grid[1:]
+			if idx == 0 {
+				// Collect all column names; unselected columns are
+				// appended to the source's columns array.
+				seen := make(map[string]bool)
+				for _, col := range columns {
+					seen[col.Name.Column] = true
+				}
+				for _, name := range header {
+					if !seen[name] {
+						seen[name] = true
+						columns = append(columns, types.ColumnSelector{
+							Name: types.Reference{
+								Column: name,
+							},
+						})
+					}
+				}
+			}
+			names := make(map[string]int)
+			for i, name := range header {
+				if _, ok := names[name]; !ok {
+					names[name] = i
+				}
+			}
+			var indices []int
+			for _, col := range columns {
+				i, ok := names[col.Name.Column]
+				if !ok {
+					return fail(idx, fmt.Errorf("xlsx: unknown column: %s",
+						col.Name.Column))
+				}
+				indices = append(indices, i)
+			}
+			rows = append(rows, xlsxRows(grid, indices, columns)...)
+		} else {
+			var width int
+			for _, row := range grid {
+				if len(row) > width {
+					width = len(row)
+				}
+			}
+			if idx == 0 && len(columns) == 0 {
+				for i := 0; i < width; i++ {
+					columns = append(columns, types.ColumnSelector{
+						Name: types.Reference{
+							Column: columnName(c0 + i),
+						},
+					})
+				}
+			}
+			var indices []int
+			for _, col := range columns {
+				i, err := columnIndex(col.Name.Column, c0)
+				if err != nil {
+					return fail(idx, err)
+				}
+				indices = append(indices, i)
+			}
+			rows = append(rows, xlsxRows(grid, indices, columns)...)
+		}
+	}
+
+	return &XLSX{
+		columns: columns,
+		rows:    rows,
+	}, nil
+}
+
+// xlsxRows selects the columns from the grid rows and resolves the
+// column types.
+func xlsxRows(grid [][]types.Value, indices []int,
+	columns []types.ColumnSelector) []types.Row {
+
+	var rows []types.Row
+	for _, r := range grid {
+		var values []types.Value
+		for i, idx := range indices {
+			val := types.Value(types.Null)
+			if idx < len(r) {
+				val = r[idx]
+			}
+			columns[i].ResolveValue(val)
+			values = append(values, val)
+		}
+		rows = append(rows, newRow(values))
+	}
+	return rows
+}
+
+// columnIndex resolves the column index of the column selector
+// without headers. The selector is a column letter or a 0-based index
+// in the range starting from the column c0.
+func columnIndex(sel string, c0 int) (int, error) {
+	i, err := strconv.Atoi(sel)
+	if err == nil {
+		if i < 0 {
+			return 0, fmt.Errorf("xlsx: invalid column: %s", sel)
+		}
+		return i, nil
+	}
+	col, row, err := parseCellRef(sel)
+	if err != nil || row != 0 || col < c0 {
+		return 0, fmt.Errorf("xlsx: invalid column: %s", sel)
+	}
+	return col - c0, nil
+}
+
+// parseXLSXFilter parses the XLSX filter options.
+func parseXLSXFilter(filter string) (*xlsxOptions, error) {
+	options := &xlsxOptions{
+		headers: true,
+	}
+	opts, err := splitFilterOptions(filter)
+	if err != nil {
+		return nil, fmt.Errorf("xlsx: %s", err)
+	}
+	for _, option := range opts {
+		parts := strings.SplitN(option, "=", 2)
+		switch len(parts) {
+		case 1:
+			switch parts[0] {
+			case "noheaders":
+				options.headers = false
+
+			default:
+				return nil, fmt.Errorf("xlsx: invalid filter flag: %s",
+					parts[0])
+			}
+
+		case 2:
+			switch parts[0] {
+			case "sheet":
+				options.sheet = parts[1]
+
+			case "range":
+				options.rng, err = parseCellRange(parts[1])
+				if err != nil {
+					return nil, err
+				}
+
+			default:
+				return nil, fmt.Errorf("xlsx: unknown option: %s", parts[0])
+			}
+		}
+	}
+	return options, nil
+}
+
+// splitFilterOptions splits the filter into space-separated
+// options. The option values can be quoted with double quotes.
+func splitFilterOptions(filter string) ([]string, error) {
+	var result []string
+	var option strings.Builder
+	var quoted, started bool
+
+	for _, r := range filter {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+
+		case r == ' ' && !quoted:
+			if started {
+				result = append(result, option.String())
+				option.Reset()
+				started = false
+			}
+
+		default:
+			option.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote: %s", filter)
+	}
+	if started {
+		result = append(result, option.String())
+	}
+	return result, nil
+}
+
+// parseCellRange parses the cell range, for example A1:F200. The end
+// row can be omitted to select all rows of the sheet.
+func parseCellRange(val string) (*cellRange, error) {
+	parts := strings.Split(val, ":")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("xlsx: invalid range: %s", val)
+	}
+	col0, row0, err := parseCellRef(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("xlsx: invalid range: %s", val)
+	}
+	col1, row1, err := parseCellRef(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("xlsx: invalid range: %s", val)
+	}
+	if row0 == 0 {
+		row0 = 1
+	}
+	if col1 < col0 || (row1 != 0 && row1 < row0) {
+		return nil, fmt.Errorf("xlsx: invalid range: %s", val)
+	}
+	return &cellRange{
+		col0: col0,
+		row0: row0,
+		col1: col1,
+		row1: row1,
+	}, nil
+}
+
+// parseCellRef parses the cell reference, for example B12. The
+// function returns the column and row numbers. The row number is 0 if
+// the reference does not have the row.
+func parseCellRef(ref string) (int, int, error) {
+	var col, row int
+	var i int
+
+	ref = strings.ReplaceAll(strings.ToUpper(ref), "$", "")
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		col = col*26 + int(ref[i]-'A') + 1
+		if col > 16384 {
+			return 0, 0, fmt.Errorf("invalid cell reference: %s", ref)
+		}
+	}
+	if col == 0 {
+		return 0, 0, fmt.Errorf("invalid cell reference: %s", ref)
+	}
+	if i < len(ref) {
+		var err error
+		row, err = strconv.Atoi(ref[i:])
+		if err != nil || row <= 0 {
+			return 0, 0, fmt.Errorf("invalid cell reference: %s", ref)
+		}
+	}
+	return col, row, nil
+}
+
+// columnName returns the column letters of the column number.
+func columnName(col int) string {
+	var name []byte
+	for col > 0 {
+		col--
+		name = append([]byte{byte('A' + col%26)}, name...)
+		col /= 26
+	}
+	return string(name)
+}
+
+// XLSX document parts.
+
+type xlsxWorkbook struct {
+	Properties struct {
+		Date1904 string `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		ID   string `xml:"id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var sb strings.Builder
+	sb.WriteString(t.T)
+	for _, r := range t.Runs {
+		sb.WriteString(r.T)
+	}
+	return sb.String()
+}
+
+type xlsxStyles struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R  string   `xml:"r,attr"`
+			S  int      `xml:"s,attr"`
+			T  string   `xml:"t,attr"`
+			V  string   `xml:"v"`
+			Is xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// xlsxCell holds a cell value and its position.
+type xlsxCell struct {
+	col, row int
+	val      types.Value
+}
+
+// readXLSX reads the selected sheet and range from the input. The
+// function returns the range rows and the column number of the first
+// column of the range. The empty rows are skipped.
+func readXLSX(in io.ReadCloser, options *xlsxOptions) (
+	[][]types.Value, int, error) {
+
+	archive, closer, err := readZip(in)
+	if err != nil {
+		return nil, 0, fmt.Errorf("xlsx: %s", err)
+	}
+	defer closer.Close()
+
+	files := make(map[string]*zip.File)
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	var workbook xlsxWorkbook
+	if err := readXLSXPart(files, "xl/workbook.xml", true,
+		&workbook); err != nil {
+		return nil, 0, err
+	}
+	var rels xlsxRelationships
+	if err := readXLSXPart(files, "xl/_rels/workbook.xml.rels", true,
+		&rels); err != nil {
+		return nil, 0, err
+	}
+	var sst xlsxSharedStrings
+	if err := readXLSXPart(files, "xl/sharedStrings.xml", false,
+		&sst); err != nil {
+		return nil, 0, err
+	}
+	var styles xlsxStyles
+	if err := readXLSXPart(files, "xl/styles.xml", false,
+		&styles); err != nil {
+		return nil, 0, err
+	}
+
+	// Resolve the sheet.
+	if len(workbook.Sheets) == 0 {
+		return nil, 0, errors.New("xlsx: no sheets")
+	}
+	sheetIdx := -1
+	if len(options.sheet) == 0 {
+		sheetIdx = 0
+	} else {
+		for idx, s := range workbook.Sheets {
+			if strings.EqualFold(s.Name, options.sheet) {
+				sheetIdx = idx
+				break
+			}
+		}
+		if sheetIdx < 0 {
+			return nil, 0, fmt.Errorf("xlsx: unknown sheet: %s", options.sheet)
+		}
+	}
+	sheet := workbook.Sheets[sheetIdx]
+	var target string
+	for _, rel := range rels.Relationships {
+		if rel.ID == sheet.ID {
+			target = rel.Target
+			break
+		}
+	}
+	if len(target) == 0 {
+		return nil, 0, fmt.Errorf("xlsx: sheet '%s' not found", sheet.Name)
+	}
+	if strings.HasPrefix(target, "/") {
+		target = target[1:]
+	} else {
+		target = path.Join("xl", target)
+	}
+	var worksheet xlsxWorksheet
+	if err := readXLSXPart(files, target, true, &worksheet); err != nil {
+		return nil, 0, err
+	}
+
+	// Resolve the date styles.
+	customFormats := make(map[int]string)
+	for _, f := range styles.NumFmts {
+		customFormats[f.ID] = f.Code
+	}
+	var dateStyles []bool
+	for _, xf := range styles.CellXfs {
+		dateStyles = append(dateStyles,
+			isDateFormat(xf.NumFmtID, customFormats[xf.NumFmtID]))
+	}
+
+	// Decode the cells.
+	date1904 := workbook.Properties.Date1904 == "1" ||
+		workbook.Properties.Date1904 == "true"
+	var cells []xlsxCell
+	var row int
+	for _, r := range worksheet.Rows {
+		if r.R > 0 {
+			row = r.R
+		} else {
+			row++
+		}
+		var col int
+		for _, c := range r.Cells {
+			if len(c.R) > 0 {
+				col, _, err = parseCellRef(c.R)
+				if err != nil {
+					return nil, 0, fmt.Errorf("xlsx: %s", err)
+				}
+			} else {
+				col++
+			}
+			val, err := xlsxValue(c.T, c.V, c.Is, sst,
+				c.S < len(dateStyles) && dateStyles[c.S], date1904)
+			if err != nil {
+				return nil, 0, fmt.Errorf("xlsx: cell %s%d: %s",
+					columnName(col), row, err)
+			}
+			if val == types.Null {
+				continue
+			}
+			cells = append(cells, xlsxCell{
+				col: col,
+				row: row,
+				val: val,
+			})
+		}
+	}
+
+	// Resolve the range.
+	rng := options.rng
+	if rng == nil {
+		if len(cells) == 0 {
+			return nil, 0, nil
+		}
+		rng = &cellRange{
+			col0: cells[0].col,
+			row0: cells[0].row,
+		}
+		for _, c := range cells {
+			if c.col < rng.col0 {
+				rng.col0 = c.col
+			}
+			if c.col > rng.col1 {
+				rng.col1 = c.col
+			}
+		}
+	}
+
+	var result [][]types.Value
+	var current []types.Value
+	var currentRow int
+	for _, c := range cells {
+		if c.row < rng.row0 || (rng.row1 > 0 && c.row > rng.row1) ||
+			c.col < rng.col0 || c.col > rng.col1 {
+			continue
+		}
+		if current == nil || c.row != currentRow {
+			current = make([]types.Value, rng.col1-rng.col0+1)
+			for i := range current {
+				current[i] = types.Null
+			}
+			currentRow = c.row
+			result = append(result, current)
+		}
+		current[c.col-rng.col0] = c.val
+	}
+	return result, rng.col0, nil
+}
+
+// readXLSXPart reads and decodes the XML part of the archive. If the
+// part is not required, missing parts are ignored.
+func readXLSXPart(files map[string]*zip.File, name string, required bool,
+	v interface{}) error {
+
+	f, ok := files[name]
+	if !ok {
+		if required {
+			return fmt.Errorf("xlsx: part %s not found", name)
+		}
+		return nil
+	}
+	r, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("xlsx: %s: %s", name, err)
+	}
+	defer r.Close()
+
+	if err := xml.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("xlsx: %s: %s", name, err)
+	}
+	return nil
+}
+
+// xlsxValue decodes the cell value. The t specifies the cell type, v
+// its value, and is the inline string value. The date specifies if
+// the cell has a date format.
+func xlsxValue(t, v string, is xlsxText, sst xlsxSharedStrings,
+	date, date1904 bool) (types.Value, error) {
+
+	switch t {
+	case "s":
+		idx, err := strconv.Atoi(v)
+		if err != nil || idx < 0 || idx >= len(sst.Items) {
+			return nil, fmt.Errorf("invalid shared string: %s", v)
+		}
+		return types.StringValue(sst.Items[idx].String()), nil
+
+	case "inlineStr":
+		return types.StringValue(is.String()), nil
+
+	case "str", "e":
+		return types.StringValue(v), nil
+
+	case "b":
+		return types.BoolValue(v == "1"), nil
+
+	case "d":
+		d, err := types.ParseDate(v)
+		if err != nil {
+			return types.StringValue(v), nil
+		}
+		return types.DateValue(d), nil
+
+	case "", "n":
+		if len(v) == 0 {
+			return types.Null, nil
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number: %s", v)
+		}
+		if date {
+			return types.DateValue(excelDate(f, date1904)), nil
+		}
+		if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return types.IntValue(int64(f)), nil
+		}
+		return types.FloatValue(f), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported cell type: %s", t)
+	}
+}
+
+// isDateFormat tests if the number format is a date or time format.
+// The built-in formats are identified by their IDs and custom formats
+// by the date and time characters in their format codes.
+func isDateFormat(id int, code string) bool {
+	if (id >= 14 && id <= 22) || (id >= 45 && id <= 47) {
+		return true
+	}
+	var quoted, bracket, escaped bool
+	for _, r := range code {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == '[':
+			bracket = true
+		case r == ']':
+			bracket = false
+		case bracket:
+		case strings.ContainsRune("dmyhsDMYHS", r):
+			return true
+		}
+	}
+	return false
+}
+
+// excelDate converts the Excel serial date to time. The date1904
+// specifies if the workbook uses the 1904 date system.
+func excelDate(serial float64, date1904 bool) time.Time {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	days := math.Floor(serial)
+	ms := math.Round((serial - days) * 24 * 60 * 60 * 1000)
+	return epoch.AddDate(0, 0, int(days)).
+		Add(time.Duration(ms) * time.Millisecond)
+}
+
+// Columns implements the Source.Columns().
+func (src *XLSX) Columns() []types.ColumnSelector {
+	return src.columns
+}
+
+// Get implements the Source.Get().
+func (src *XLSX) Get() ([]types.Row, error) {
+	return src.rows, nil
+}
+
+// Rows implements the Source.Rows().
+func (src *XLSX) Rows() (types.RowIterator, error) {
+	return types.NewRowsIterator(src.rows), nil
+}
@@ -0,0 +1,219 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/markkurossi/iql/types"
+)
+
+var xlsxParts = map[string]string{
+	"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+  xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+  <sheets>
+    <sheet name="Summary" sheetId="1" r:id="rId1"/>
+    <sheet name="Q1 Orders" sheetId="2" r:id="rId2"/>
+  </sheets>
+</workbook>`,
+	"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rId1" Target="worksheets/sheet1.xml"/>
+  <Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/>
+</Relationships>`,
+	"xl/sharedStrings.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+  <si><t>Name</t></si>
+  <si><t>Count</t></si>
+  <si><t>Price</t></si>
+  <si><t>Date</t></si>
+  <si><r><t>Wid</t></r><r><t>get</t></r></si>
+</sst>`,
+	"xl/styles.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+  <numFmts><numFmt numFmtId="164" formatCode="yyyy\-mm\-dd&quot;Z&quot;"/></numFmts>
+  <cellXfs>
+    <xf numFmtId="0"/>
+    <xf numFmtId="14"/>
+    <xf numFmtId="164"/>
+  </cellXfs>
+</styleSheet>`,
+	"xl/worksheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+  <sheetData>
+    <row r="1"><c r="A1" t="inlineStr"><is><t>Total</t></is></c><c r="B1"><v>3</v></c></row>
+  </sheetData>
+</worksheet>`,
+	"xl/worksheets/sheet2.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+  <sheetData>
+    <row r="2">
+      <c r="B2" t="s"><v>0</v></c><c r="C2" t="s"><v>1</v></c>
+      <c r="D2" t="s"><v>2</v></c><c r="E2" t="s"><v>3</v></c>
+    </row>
+    <row r="3">
+      <c r="B3" t="s"><v>4</v></c><c r="C3"><v>2</v></c>
+      <c r="D3"><v>9.5</v></c><c r="E3" s="1"><v>44197</v></c>
+    </row>
+    <row r="4">
+      <c r="B4" t="str"><v>Gadget</v></c><c r="C4"><v>1</v></c>
+      <c r="D4"><v>12</v></c><c r="E4" s="2"><v>44228.5</v></c>
+    </row>
+    <row r="6">
+      <c r="B6" t="inlineStr"><is><t>Notes</t></is></c>
+      <c r="C6" t="b"><v>1</v></c>
+    </row>
+  </sheetData>
+</worksheet>`,
+}
+
+func xlsxData(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range xlsxParts {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("zip failed: %s", err)
+		}
+		f.Write([]byte(data))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("zip failed: %s", err)
+	}
+	return buf.Bytes()
+}
+
+var xlsxTests = []struct {
+	filter  string
+	columns []string
+	types   []types.Type
+	rows    [][]string
+}{
+	{
+		filter:  "",
+		columns: []string{"Total", "3"},
+		types:   []types.Type{types.Bool, types.Bool},
+	},
+	{
+		filter:  `sheet="Q1 Orders" range=B2:E4`,
+		columns: []string{"Name", "Count", "Price", "Date"},
+		types: []types.Type{
+			types.String, types.Int, types.Float, types.Date,
+		},
+		rows: [][]string{
+			{"Widget", "2", "9.5", "2021-01-01 00:00:00"},
+			{"Gadget", "1", "12", "2021-02-01 12:00:00"},
+		},
+	},
+	{
+		filter:  `sheet="q1 orders" range=B3:C noheaders`,
+		columns: []string{"B", "1"},
+		types:   []types.Type{types.String, types.Int},
+		rows: [][]string{
+			{"Widget", "2"},
+			{"Gadget", "1"},
+			{"Notes", "true"},
+		},
+	},
+}
+
+func TestXLSX(t *testing.T) {
+	file := writeTestFile(t, "test.xlsx", xlsxData(t))
+
+	for idx, test := range xlsxTests {
+		var columns []types.ColumnSelector
+		if idx > 0 {
+			columns = xmlColumns(test.columns...)
+		}
+		source, err := New([]string{file}, test.filter, columns)
+		if err != nil {
+			t.Fatalf("test %d: New failed: %s", idx, err)
+		}
+		cols := source.Columns()
+		if len(cols) != len(test.columns) {
+			t.Fatalf("test %d: got %d columns, expected %d",
+				idx, len(cols), len(test.columns))
+		}
+		for i, col := range cols {
+			if col.Name.Column != test.columns[i] {
+				t.Errorf("test %d: column %d: got %s, expected %s",
+					idx, i, col.Name.Column, test.columns[i])
+			}
+			if col.Type != test.types[i] {
+				t.Errorf("test %d: column %s: got type %s, expected %s",
+					idx, col.Name.Column, col.Type, test.types[i])
+			}
+		}
+		rows, err := source.Get()
+		if err != nil {
+			t.Fatalf("test %d: Get failed: %s", idx, err)
+		}
+		if len(rows) != len(test.rows) {
+			t.Fatalf("test %d: got %d rows, expected %d",
+				idx, len(rows), len(test.rows))
+		}
+		for i, row := range rows {
+			for j, col := range test.rows[i] {
+				if row[j].String() != col {
+					t.Errorf("test %d: row %d: column %d: got %s, "+
+						"expected %s", idx, i, j, row[j], col)
+				}
+			}
+		}
+	}
+}
+
+func TestXLSXErrors(t *testing.T) {
+	file := writeTestFile(t, "test.xlsx", xlsxData(t))
+
+	for _, filter := range []string{
+		"sheet=Missing",
+		"range=B2",
+		"range=E2:B4",
+		"range=1:2",
+		`sheet="Q1`,
+		"unknown",
+		"unknown=1",
+	} {
+		_, err := New([]string{file}, filter, nil)
+		if err == nil {
+			t.Errorf("filter '%s' accepted", filter)
+		}
+	}
+	_, err := New([]string{file}, "noheaders", xmlColumns("A1"))
+	if err == nil {
+		t.Errorf("invalid column accepted")
+	}
+
+	bad := writeTestFile(t, "bad.xlsx", []byte("Name,Value\na,1\n"))
+	_, err = New([]string{bad}, "", nil)
+	if err == nil {
+		t.Errorf("invalid archive accepted")
+	}
+}
+
+func TestExcelDate(t *testing.T) {
+	tests := []struct {
+		serial   float64
+		date1904 bool
+		expected string
+	}{
+		{61, false, "1900-03-01 00:00:00"},
+		{44197.25, false, "2021-01-01 06:00:00"},
+		{0, true, "1904-01-01 00:00:00"},
+	}
+	for _, test := range tests {
+		d := excelDate(test.serial, test.date1904).Format("2006-01-02 15:04:05")
+		if d != test.expected {
+			t.Errorf("excelDate(%v, %v): got %s, expected %s",
+				test.serial, test.date1904, d, test.expected)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"errors"
+	"io"
+
+	"github.com/markkurossi/iql/types"
+)
+
+// defaultXMLFilter selects the child elements of the root element.
+const defaultXMLFilter = "/*/*"
+
+// XMLFilter filters the input with the XPath selector string and
+// returns the text of the matching nodes.
+func XMLFilter(input io.ReadCloser, filter string) ([]string, error) {
+	defer input.Close()
+
+	selector, err := parseXPath(filter)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseXML(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, n := range selector.Select(doc) {
+		result = append(result, n.Text())
+	}
+	return result, nil
+}
+
+// XML implements a data source from XML data.
+type XML struct {
+	columns []types.ColumnSelector
+	rows    []types.Row
+}
+
+// NewXML creates a new XML data source from the input. The filter is
+// an XPath selector that selects the input rows. If the filter is
+// empty, the child elements of the root element are selected.
+func NewXML(input []io.ReadCloser, filter string,
+	columns []types.ColumnSelector) (types.Source, error) {
+
+	for _, in := range input {
+		defer in.Close()
+	}
+
+	if len(columns) == 0 {
+		return nil, errors.New("xml: 'SELECT *' not supported")
+	}
+	if len(filter) == 0 {
+		filter = defaultXMLFilter
+	}
+	rowSelector, err := parseXPath(filter)
+	if err != nil {
+		return nil, err
+	}
+	var selectors []*xpath
+	for _, col := range columns {
+		selector, err := parseXPath(col.Name.Column)
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
+	}
+
+	var rows []types.Row
+
+	for _, in := range input {
+		doc, err := parseXML(in)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range rowSelector.Select(doc) {
+			var row types.Row
+			for i, selector := range selectors {
+				sel := selector.Select(n)
+				switch len(sel) {
+				case 0:
+					row = append(row, types.NullColumn{})
+					continue
+
+				case 1:
+					row = append(row, types.StringColumn(sel[0].Text()))
+
+				default:
+					var strings []string
+					for _, s := range sel {
+						strings = append(strings, s.Text())
+					}
+					row = append(row, types.StringsColumn(strings))
+				}
+				columns[i].ResolveString(row[i].String())
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return &XML{
+		columns: columns,
+		rows:    rows,
+	}, nil
+}
+
+// Columns implements the Source.Columns().
+func (src *XML) Columns() []types.ColumnSelector {
+	return src.columns
+}
+
+// Get implements the Source.Get().
+func (src *XML) Get() ([]types.Row, error) {
+	return src.rows, nil
+}
+
+// Rows implements the Source.Rows().
+func (src *XML) Rows() (types.RowIterator, error) {
+	return types.NewRowsIterator(src.rows), nil
+}
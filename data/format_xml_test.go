@@ -0,0 +1,129 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"testing"
+
+	"github.com/markkurossi/iql/types"
+)
+
+func xmlColumns(names ...string) []types.ColumnSelector {
+	var columns []types.ColumnSelector
+	for _, name := range names {
+		columns = append(columns, types.ColumnSelector{
+			Name: types.Reference{
+				Column: name,
+			},
+		})
+	}
+	return columns
+}
+
+var xmlTests = []struct {
+	filter  string
+	columns []string
+	rows    [][]string
+}{
+	{
+		filter:  "//item",
+		columns: []string{"title", "enclosure/@url", "enclosure/@length"},
+		rows: [][]string{
+			{"First", "https://example.com/1.mp3", "100"},
+			{"Second", "https://example.com/2.mp3", "200"},
+		},
+	},
+	{
+		filter:  "/rss/channel/item[2]",
+		columns: []string{"title", "dc:creator", "category"},
+		rows: [][]string{
+			{"Second", "NULL", "[news tech]"},
+		},
+	},
+	{
+		filter:  "//item[creator='alice']",
+		columns: []string{"./title", "../title", "category[1]/text()"},
+		rows: [][]string{
+			{"First", "Test Feed", "news"},
+		},
+	},
+	{
+		filter:  "//enclosure[@length='200']",
+		columns: []string{"@url", "@missing"},
+		rows: [][]string{
+			{"https://example.com/2.mp3", "NULL"},
+		},
+	},
+	{
+		filter:  "",
+		columns: []string{"title"},
+		rows: [][]string{
+			{"Test Feed"},
+		},
+	},
+}
+
+func TestXML(t *testing.T) {
+	for _, test := range xmlTests {
+		source, err := New([]string{"test.xml"}, test.filter,
+			xmlColumns(test.columns...))
+		if err != nil {
+			t.Fatalf("%s: New failed: %s", test.filter, err)
+		}
+		rows, err := source.Get()
+		if err != nil {
+			t.Fatalf("%s: Get failed: %s", test.filter, err)
+		}
+		if len(rows) != len(test.rows) {
+			t.Errorf("%s: got %d rows, expected %d", test.filter,
+				len(rows), len(test.rows))
+			continue
+		}
+		for rowIdx, row := range rows {
+			for colIdx, col := range row {
+				if col.String() != test.rows[rowIdx][colIdx] {
+					t.Errorf("%s: %d.%d: got '%s', expected '%s'",
+						test.filter, rowIdx, colIdx, col.String(),
+						test.rows[rowIdx][colIdx])
+				}
+			}
+		}
+	}
+}
+
+func TestXMLColumnTypes(t *testing.T) {
+	source, err := New([]string{
+		"data:application/rss+xml,<r><i n='1' s='a'/><i n='2' s='b'/></r>",
+	}, "", xmlColumns("@n", "@s"))
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+	columns := source.Columns()
+	if columns[0].Type != types.Int || columns[1].Type != types.String {
+		t.Errorf("got column types %s, %s", columns[0].Type, columns[1].Type)
+	}
+}
+
+var xpathErrorTests = []string{
+	"",
+	"/",
+	"//",
+	"a/@b/c",
+	"a[",
+	"a[0]",
+	"a[@b=c]",
+	"@a[1]",
+}
+
+func TestXPathErrors(t *testing.T) {
+	for _, test := range xpathErrorTests {
+		_, err := parseXPath(test)
+		if err == nil {
+			t.Errorf("parseXPath(%q) succeeded", test)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultUserAgent specifies the default User-Agent of the HTTP
+// requests.
+const DefaultUserAgent = "iql"
+
+// retryDelay specifies the delay before the first retry of a failed
+// HTTP request. The delay is doubled for each subsequent retry.
+var retryDelay = 500 * time.Millisecond
+
+// Options define the options for creating data sources.
+type Options struct {
+	// Format specifies the data format. If the format is unknown, it
+	// is resolved from the URLs, media types, and input data.
+	Format Format
+	HTTP   HTTPOptions
+	Cache  CacheOptions
+	Page   PageOptions
+}
+
+// HTTPOptions define the request options for the HTTP URL sources.
+type HTTPOptions struct {
+	// Method specifies the request method. The default method is GET.
+	Method string
+	// Header specifies the request headers.
+	Header http.Header
+	// Body specifies the request body.
+	Body string
+	// Auth specifies the request authorization as "Basic
+	// user:password" or "Bearer token".
+	Auth string
+	// Hosts specifies the hosts that the Header and Auth are sent
+	// to. The hosts without ports match all ports. If Hosts is nil,
+	// the Header and Auth are sent to all hosts.
+	Hosts []string
+	// Timeout specifies the time limit for the request, including
+	// reading the response body. The zero value means no timeout.
+	Timeout time.Duration
+	// Retry specifies how many times a failed GET or HEAD request is
+	// retried.
+	Retry int
+	// Proxy specifies the proxy URL. If empty, the proxy is taken
+	// from the environment.
+	Proxy string
+}
+
+// ParseHeaders parses the newline-separated "Name: value" header
+// lines.
+func ParseHeaders(lines string) (http.Header, error) {
+	header := make(http.Header)
+	for _, line := range strings.Split(lines, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		idx := strings.IndexByte(line, ':')
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid HTTP header: %s", line)
+		}
+		header.Add(strings.TrimSpace(line[:idx]),
+			strings.TrimSpace(line[idx+1:]))
+	}
+	return header, nil
+}
+
+// ParseHosts parses the comma-separated host list. The function
+// returns an empty non-nil list for an empty input.
+func ParseHosts(list string) []string {
+	hosts := []string{}
+	for _, host := range strings.Split(list, ",") {
+		host = strings.TrimSpace(host)
+		if len(host) > 0 {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// ParseAuth parses the authorization option. The function returns
+// the authorization scheme and its credentials.
+func ParseAuth(auth string) (string, string, error) {
+	parts := strings.SplitN(strings.TrimSpace(auth), " ", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid HTTP authorization: %s", auth)
+	}
+	scheme := strings.ToLower(parts[0])
+	switch scheme {
+	case "basic":
+		if strings.IndexByte(parts[1], ':') < 0 {
+			return "", "", errors.New(
+				"invalid basic authorization: expected user:password")
+		}
+	case "bearer":
+	default:
+		return "", "", fmt.Errorf("unsupported HTTP authorization: %s",
+			parts[0])
+	}
+	return scheme, strings.TrimSpace(parts[1]), nil
+}
+
+// client creates an HTTP client for the options.
+func (o *HTTPOptions) client() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(o.Proxy) > 0 {
+		proxy, err := url.Parse(o.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HTTP proxy: %s", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   o.Timeout,
+	}, nil
+}
+
+// request creates an HTTP request for the URL. The header specifies
+// additional request headers.
+func (o *HTTPOptions) request(input string, header http.Header) (
+	*http.Request, error) {
+
+	method := o.Method
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if len(o.Body) > 0 {
+		body = strings.NewReader(o.Body)
+	}
+	req, err := http.NewRequest(strings.ToUpper(method), input, body)
+	if err != nil {
+		return nil, err
+	}
+	headers := []http.Header{header}
+	if o.sendCredentials(input) {
+		headers = append(headers, o.Header)
+	}
+	for _, h := range headers {
+		for key, values := range h {
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+	}
+	if len(req.Header.Get("User-Agent")) == 0 {
+		req.Header.Set("User-Agent", DefaultUserAgent)
+	}
+	if len(o.Auth) > 0 && o.sendCredentials(input) {
+		scheme, credentials, err := ParseAuth(o.Auth)
+		if err != nil {
+			return nil, err
+		}
+		switch scheme {
+		case "basic":
+			idx := strings.IndexByte(credentials, ':')
+			req.SetBasicAuth(credentials[:idx], credentials[idx+1:])
+		case "bearer":
+			req.Header.Set("Authorization", "Bearer "+credentials)
+		}
+	}
+	return req, nil
+}
+
+// sendCredentials tests if the Header and Auth are sent to the URL.
+func (o *HTTPOptions) sendCredentials(input string) bool {
+	if o.Hosts == nil {
+		return true
+	}
+	u, err := url.Parse(input)
+	if err != nil {
+		return false
+	}
+	for _, host := range o.Hosts {
+		if strings.EqualFold(host, u.Host) ||
+			strings.EqualFold(host, u.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
+
+// httpGet fetches the URL. The header specifies additional request
+// headers. The failed GET and HEAD requests are retried if the
+// request fails with a network error or with a server error status
+// code. The function
+// returns an error if the response status is not 200 OK, or 304 Not
+// Modified for conditional requests.
+func httpGet(input string, options *HTTPOptions, header http.Header) (
+	*http.Response, error) {
+
+	client, err := options.client()
+	if err != nil {
+		return nil, err
+	}
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		req, err := options.request(input, header)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err == nil && (resp.StatusCode == http.StatusOK ||
+			resp.StatusCode == http.StatusNotModified && len(header) > 0) {
+			return resp, nil
+		}
+		if err == nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			err = fmt.Errorf("HTTP URL '%s': %s", input, resp.Status)
+			if (resp.StatusCode == http.StatusUnauthorized ||
+				resp.StatusCode == http.StatusForbidden) &&
+				(len(options.Auth) > 0 || len(options.Header) > 0) &&
+				!options.sendCredentials(input) {
+				err = fmt.Errorf("%s: authorization and headers not sent "+
+					"to host %s", err, req.URL.Host)
+			}
+			if !retryStatus(resp.StatusCode) {
+				return nil, err
+			}
+		}
+		if attempt >= options.Retry || !retryMethod(req.Method) {
+			return nil, err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// retryMethod tests if the failed requests of the method can be
+// retried. The other methods can have side effects that would be
+// repeated if the server processed the failed request.
+func retryMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// retryStatus tests if the request can be retried after the response
+// status code.
+func retryStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
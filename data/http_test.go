@@ -0,0 +1,254 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func csvHandler(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Write([]byte("Name,Value\na,1\nb,2\n"))
+}
+
+func TestHTTPOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				t.Errorf("got method %s, expected POST", r.Method)
+			}
+			if r.Header.Get("X-Test") != "value" {
+				t.Errorf("got X-Test '%s'", r.Header.Get("X-Test"))
+			}
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				t.Errorf("got Authorization '%s'",
+					r.Header.Get("Authorization"))
+			}
+			if r.Header.Get("User-Agent") != DefaultUserAgent {
+				t.Errorf("got User-Agent '%s'", r.Header.Get("User-Agent"))
+			}
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Errorf("failed to read body: %s", err)
+			}
+			if string(body) != `{"q":1}` {
+				t.Errorf("got body '%s'", body)
+			}
+			csvHandler(w)
+		}))
+	defer server.Close()
+
+	header, err := ParseHeaders("X-Test: value\n")
+	if err != nil {
+		t.Fatalf("ParseHeaders failed: %s", err)
+	}
+	source, err := NewWithOptions([]string{server.URL + "/data.csv"}, "",
+		nil, &Options{
+			HTTP: HTTPOptions{
+				Method: "post",
+				Header: header,
+				Body:   `{"q":1}`,
+				Auth:   "Bearer secret",
+			},
+		})
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %s", err)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	if len(rows) != 2 {
+		t.Errorf("got %d rows, expected 2", len(rows))
+	}
+}
+
+func TestHTTPBasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			user, password, ok := r.BasicAuth()
+			if !ok || user != "user" || password != "pass:word" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			csvHandler(w)
+		}))
+	defer server.Close()
+
+	_, err := NewWithOptions([]string{server.URL}, "", nil, &Options{
+		HTTP: HTTPOptions{
+			Auth: "Basic user:pass:word",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %s", err)
+	}
+	_, err = New([]string{server.URL}, "", nil)
+	if err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("expected status code in error, got: %v", err)
+	}
+
+	// Credentials are sent only to the listed hosts.
+	for _, hosts := range [][]string{
+		{"127.0.0.1"},
+		{"example.com", strings.TrimPrefix(server.URL, "http://")},
+	} {
+		_, err = NewWithOptions([]string{server.URL}, "", nil, &Options{
+			HTTP: HTTPOptions{
+				Auth:  "Basic user:pass:word",
+				Hosts: hosts,
+			},
+		})
+		if err != nil {
+			t.Errorf("hosts %v: NewWithOptions failed: %s", hosts, err)
+		}
+	}
+	for _, hosts := range [][]string{
+		{},
+		{"example.com", "127.0.0.1:1"},
+	} {
+		_, err = NewWithOptions([]string{server.URL}, "", nil, &Options{
+			HTTP: HTTPOptions{
+				Auth:  "Basic user:pass:word",
+				Hosts: hosts,
+			},
+		})
+		if err == nil || !strings.Contains(err.Error(), "not sent to host") {
+			t.Errorf("hosts %v: expected credentials error, got: %v",
+				hosts, err)
+		}
+	}
+}
+
+func TestParseHosts(t *testing.T) {
+	hosts := ParseHosts(" example.com, ,127.0.0.1:8080,")
+	if len(hosts) != 2 || hosts[0] != "example.com" ||
+		hosts[1] != "127.0.0.1:8080" {
+		t.Errorf("ParseHosts: got %q", hosts)
+	}
+	hosts = ParseHosts("")
+	if hosts == nil || len(hosts) != 0 {
+		t.Errorf("ParseHosts: got %#v, expected empty list", hosts)
+	}
+}
+
+func TestHTTPRetry(t *testing.T) {
+	saved := retryDelay
+	retryDelay = time.Millisecond
+	defer func() {
+		retryDelay = saved
+	}()
+
+	var count int
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			count++
+			switch {
+			case r.URL.Path == "/missing":
+				w.WriteHeader(http.StatusNotFound)
+			case count <= 2:
+				w.WriteHeader(http.StatusServiceUnavailable)
+			default:
+				csvHandler(w)
+			}
+		}))
+	defer server.Close()
+
+	options := &Options{
+		HTTP: HTTPOptions{
+			Retry: 1,
+		},
+	}
+	_, err := NewWithOptions([]string{server.URL}, "", nil, options)
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("expected 503 error, got: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("got %d requests, expected 2", count)
+	}
+	_, err = NewWithOptions([]string{server.URL}, "", nil, options)
+	if err != nil {
+		t.Fatalf("retry failed: %s", err)
+	}
+
+	// Client errors are not retried.
+	count = 0
+	_, err = NewWithOptions([]string{server.URL + "/missing"}, "", nil,
+		options)
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Errorf("expected 404 error, got: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("got %d requests, expected 1", count)
+	}
+
+	// POST requests are not retried.
+	count = 0
+	_, err = NewWithOptions([]string{server.URL}, "", nil, &Options{
+		HTTP: HTTPOptions{
+			Method: "POST",
+			Retry:  1,
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("expected 503 error, got: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("got %d requests, expected 1", count)
+	}
+}
+
+func TestHTTPTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+			}
+			csvHandler(w)
+		}))
+	defer server.Close()
+	defer close(done)
+
+	_, err := NewWithOptions([]string{server.URL}, "", nil, &Options{
+		HTTP: HTTPOptions{
+			Timeout: 10 * time.Millisecond,
+		},
+	})
+	if err == nil {
+		t.Errorf("request did not time out")
+	}
+}
+
+func TestHTTPProxy(t *testing.T) {
+	var proxied bool
+	proxy := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			proxied = r.URL.Host == "example.invalid"
+			csvHandler(w)
+		}))
+	defer proxy.Close()
+
+	_, err := NewWithOptions([]string{"http://example.invalid/data.csv"}, "",
+		nil, &Options{
+			HTTP: HTTPOptions{
+				Proxy: proxy.URL,
+			},
+		})
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %s", err)
+	}
+	if !proxied {
+		t.Errorf("request was not sent through proxy")
+	}
+}
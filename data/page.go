@@ -0,0 +1,363 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/markkurossi/jsonq"
+)
+
+// PageMode specifies how the paginated HTTP responses are followed.
+type PageMode int
+
+// Pagination modes.
+const (
+	// PageNone fetches only the requested URL.
+	PageNone PageMode = iota
+	// PageLink follows the RFC 5988 Link headers with the relation
+	// type next.
+	PageLink
+	// PageNext follows the next page URL or cursor that the Next
+	// selector selects from the JSON response.
+	PageNext
+	// PageOffset increments the Param query parameter by the number
+	// of items in the page.
+	PageOffset
+	// PagePage increments the Param query parameter by one.
+	PagePage
+)
+
+var pageModes = map[PageMode]string{
+	PageNone:   "none",
+	PageLink:   "link",
+	PageNext:   "next",
+	PageOffset: "offset",
+	PagePage:   "page",
+}
+
+func (m PageMode) String() string {
+	name, ok := pageModes[m]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{PageMode %d}", m)
+}
+
+// ParsePageMode parses the pagination mode name.
+func ParsePageMode(name string) (PageMode, error) {
+	for mode, n := range pageModes {
+		if strings.EqualFold(name, n) {
+			return mode, nil
+		}
+	}
+	return PageNone, fmt.Errorf("invalid pagination mode: %s", name)
+}
+
+// PageOptions define how the paginated HTTP URL sources are
+// fetched. The pages are concatenated into one data source.
+type PageOptions struct {
+	Mode PageMode
+	// Next specifies the JSONQ selector of the next page URL or
+	// cursor for PageNext.
+	Next string
+	// Param specifies the query parameter of the cursor, offset, or
+	// page number. If Param is empty with PageNext, the Next selector
+	// selects the next page URL.
+	Param string
+	// Max specifies the maximum number of pages to fetch. The zero
+	// value means no limit.
+	Max int
+}
+
+// openPages fetches the pages of the paginated HTTP URL. The filter
+// selects the items of the JSON pages. The function returns the page
+// bodies and the headers of the first page. The next page URLs of the
+// Link headers and the JSON responses are fetched with GET requests.
+// If their origin is not the origin of the URL, they are fetched
+// without the authorization and request headers of the options. The
+// function returns an error if the URL has more pages than the
+// maximum number of pages.
+func openPages(input, filter string, options *Options) (
+	[]io.ReadCloser, http.Header, error) {
+
+	pages := &options.Page
+	switch pages.Mode {
+	case PageNext:
+		if len(pages.Next) == 0 {
+			return nil, nil, fmt.Errorf("pagination mode %s: no selector",
+				pages.Mode)
+		}
+	case PageOffset, PagePage:
+		if len(pages.Param) == 0 {
+			return nil, nil, fmt.Errorf("pagination mode %s: no parameter",
+				pages.Mode)
+		}
+	}
+
+	var result []io.ReadCloser
+	var first http.Header
+	var counter int64
+	var err error
+
+	origin := input
+	pageOptions := *options
+
+	// Start from the offset or page number of the URL.
+	if pages.Mode == PageOffset || pages.Mode == PagePage {
+		counter, err = pageCounter(input, pages)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for page := 1; ; page++ {
+		body, header, err := fetch(input, &pageOptions)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := ioutil.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		if page == 1 {
+			first = header
+		}
+		result = append(result, &memory{
+			in: bytes.NewReader(data),
+		})
+
+		var next string
+		switch pages.Mode {
+		case PageLink:
+			next = linkNext(header.Values("Link"))
+
+		case PageNext:
+			next, err = pageSelect(data, pages.Next)
+			if err != nil {
+				return nil, nil, fmt.Errorf("HTTP URL '%s': %s", input, err)
+			}
+			if len(next) > 0 && len(pages.Param) > 0 {
+				next, err = setParam(input, pages.Param, next)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+
+		case PageOffset, PagePage:
+			items, err := pageItems(data, filter)
+			if err != nil {
+				return nil, nil, fmt.Errorf("HTTP URL '%s': %s", input, err)
+			}
+			if items == 0 {
+				break
+			}
+			if pages.Mode == PageOffset {
+				counter += int64(items)
+			} else {
+				counter++
+			}
+			next, err = setParam(input, pages.Param,
+				strconv.FormatInt(counter, 10))
+			if err != nil {
+				return nil, nil, err
+			}
+
+		default:
+			return result, first, nil
+		}
+		if len(next) == 0 {
+			break
+		}
+		next, err = resolveURL(input, next)
+		if err != nil {
+			return nil, nil, err
+		}
+		if next == input {
+			break
+		}
+		if pages.Max > 0 && page >= pages.Max {
+			return nil, nil, fmt.Errorf(
+				"HTTP URL '%s': page limit %d reached", origin, pages.Max)
+		}
+		if pages.Mode == PageLink || pages.Mode == PageNext {
+			pageOptions.HTTP = nextPageOptions(options.HTTP, origin, next)
+		}
+		input = next
+	}
+	return result, first, nil
+}
+
+// nextPageOptions returns the HTTP options for fetching the next page
+// URL that the response of the origin URL specified. The next pages
+// are fetched with GET requests. If the next page URL has a different
+// origin, the authorization and request headers are not sent to it.
+func nextPageOptions(options HTTPOptions, origin, next string) HTTPOptions {
+	options.Method = http.MethodGet
+	options.Body = ""
+	if !sameOrigin(origin, next) {
+		options.Auth = ""
+		options.Header = nil
+	}
+	return options
+}
+
+// sameOrigin tests if the URLs have the same scheme, host, and port.
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Scheme, ub.Scheme) &&
+		strings.EqualFold(ua.Host, ub.Host)
+}
+
+// pageCounter returns the initial offset or page number from the
+// URL. If the URL does not have the query parameter, the offset
+// starts from 0 and the page number from 1.
+func pageCounter(input string, pages *PageOptions) (int64, error) {
+	u, err := url.Parse(input)
+	if err != nil {
+		return 0, err
+	}
+	value := u.Query().Get(pages.Param)
+	if len(value) == 0 {
+		if pages.Mode == PagePage {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	counter, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s parameter: %s", pages.Param, value)
+	}
+	return counter, nil
+}
+
+// decodeJSON decodes the JSON page.
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	err := decoder.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// pageSelect selects the next page URL or cursor from the JSON
+// page. The function returns an empty string if the page does not
+// have the next page.
+func pageSelect(data []byte, selector string) (string, error) {
+	v, err := decodeJSON(data)
+	if err != nil {
+		return "", err
+	}
+	selected, err := jsonq.Ctx(v).Select(selector).Get()
+	if err != nil {
+		return "", err
+	}
+	if len(selected) == 0 {
+		return "", nil
+	}
+	switch val := selected[0].(type) {
+	case nil, bool:
+		return "", nil
+	case string:
+		return strings.TrimSpace(val), nil
+	case json.Number:
+		return val.String(), nil
+	default:
+		return "", fmt.Errorf("invalid next page value: %v", val)
+	}
+}
+
+// pageItems returns the number of items that the filter selects
+// from the JSON page.
+func pageItems(data []byte, filter string) (int, error) {
+	v, err := decodeJSON(data)
+	if err != nil {
+		return 0, err
+	}
+	selected, err := jsonq.Ctx(v).Select(filter).Get()
+	if err != nil {
+		return 0, err
+	}
+	return len(selected), nil
+}
+
+// setParam sets the query parameter of the URL.
+func setParam(input, param, value string) (string, error) {
+	u, err := url.Parse(input)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set(param, value)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// resolveURL resolves the reference URL relative to the base URL.
+func resolveURL(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return b.ResolveReference(r).String(), nil
+}
+
+// linkNext returns the target of the RFC 5988 Link header with the
+// relation type next. The function returns an empty string if the
+// headers do not have the next link.
+func linkNext(values []string) string {
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			link = strings.TrimSpace(link)
+			if len(link) == 0 || link[0] != '<' {
+				continue
+			}
+			end := strings.IndexByte(link, '>')
+			if end < 0 {
+				continue
+			}
+			target := link[1:end]
+			for _, param := range strings.Split(link[end+1:], ";") {
+				parts := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				if len(parts) != 2 ||
+					!strings.EqualFold(strings.TrimSpace(parts[0]), "rel") {
+					continue
+				}
+				rels := strings.Trim(strings.TrimSpace(parts[1]), `"`)
+				for _, rel := range strings.Fields(rels) {
+					if strings.EqualFold(rel, "next") {
+						return target
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
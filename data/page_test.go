@@ -0,0 +1,297 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/iql/types"
+)
+
+var pageColumns = []types.ColumnSelector{
+	{
+		Name: types.Reference{
+			Column: "id",
+		},
+	},
+}
+
+// pageItemsJSON returns the JSON items with IDs [from, to).
+func pageItemsJSON(from, to int) string {
+	var items string
+	for id := from; id < to; id++ {
+		if len(items) > 0 {
+			items += ","
+		}
+		items += fmt.Sprintf(`{"id":%d}`, id)
+	}
+	return "[" + items + "]"
+}
+
+func fetchPages(t *testing.T, url, filter string, pages PageOptions) []int {
+	columns := make([]types.ColumnSelector, len(pageColumns))
+	copy(columns, pageColumns)
+
+	source, err := NewWithOptions([]string{url}, filter, columns, &Options{
+		Cache: CacheOptions{
+			Mode: CacheOff,
+		},
+		Page: pages,
+	})
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %s", err)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	var result []int
+	for _, row := range rows {
+		id, err := strconv.Atoi(row[0].String())
+		if err != nil {
+			t.Fatalf("invalid id: %s", err)
+		}
+		result = append(result, id)
+	}
+	return result
+}
+
+func verifyPages(t *testing.T, name string, got []int, count int) {
+	if len(got) != count {
+		t.Errorf("%s: got %d rows, expected %d: %v", name, len(got), count,
+			got)
+		return
+	}
+	for idx, id := range got {
+		if id != idx {
+			t.Errorf("%s: row %d: got id %d", name, idx, id)
+		}
+	}
+}
+
+func TestPageLink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			page, _ := strconv.Atoi(r.URL.Query().Get("p"))
+			if page < 2 {
+				w.Header().Add("Link", fmt.Sprintf(
+					`</items?p=%d>; rel="next", </items?p=2>; rel="last"`,
+					page+1))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, pageItemsJSON(page*2, page*2+2))
+		}))
+	defer server.Close()
+
+	got := fetchPages(t, server.URL+"/items", "", PageOptions{
+		Mode: PageLink,
+	})
+	verifyPages(t, "link", got, 6)
+
+	got = fetchPages(t, server.URL+"/items", "", PageOptions{
+		Mode: PageLink,
+		Max:  3,
+	})
+	verifyPages(t, "link max", got, 6)
+
+	_, err := NewWithOptions([]string{server.URL + "/items"}, "", pageColumns,
+		&Options{
+			Page: PageOptions{
+				Mode: PageLink,
+				Max:  2,
+			},
+		})
+	if err == nil || !strings.Contains(err.Error(), "page limit 2 reached") {
+		t.Errorf("page limit not reported: %v", err)
+	}
+}
+
+func TestPageOrigin(t *testing.T) {
+	var requests []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, fmt.Sprintf("%s %s auth=%q x=%q",
+			r.Method, r.URL.Path, r.Header.Get("Authorization"),
+			r.Header.Get("X-Api-Key")))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, pageItemsJSON(len(requests)-1, len(requests)))
+	}
+	other := httptest.NewServer(http.HandlerFunc(handler))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/first" {
+				w.Header().Add("Link", `</second>; rel="next"`)
+			} else {
+				w.Header().Add("Link", `<`+other.URL+`/third>; rel="next"`)
+			}
+			handler(w, r)
+		}))
+	defer server.Close()
+
+	source, err := NewWithOptions([]string{server.URL + "/first"}, "",
+		pageColumns, &Options{
+			HTTP: HTTPOptions{
+				Method: "POST",
+				Body:   "{}",
+				Auth:   "Bearer secret",
+				Header: http.Header{
+					"X-Api-Key": []string{"key"},
+				},
+			},
+			Page: PageOptions{
+				Mode: PageLink,
+			},
+		})
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %s", err)
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	if len(rows) != 3 {
+		t.Errorf("got %d rows, expected 3", len(rows))
+	}
+	expected := []string{
+		`POST /first auth="Bearer secret" x="key"`,
+		`GET /second auth="Bearer secret" x="key"`,
+		`GET /third auth="" x=""`,
+	}
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got requests:\n%s\nexpected:\n%s",
+			strings.Join(requests, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestPageNext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			cursor, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+			var next string
+			switch {
+			case cursor >= 2:
+				next = "null"
+			case r.URL.Path == "/url":
+				next = fmt.Sprintf(`"/url?cursor=%d"`, cursor+1)
+			default:
+				next = fmt.Sprintf(`"%d"`, cursor+1)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"data":%s,"next":%s}`,
+				pageItemsJSON(cursor*2, cursor*2+2), next)
+		}))
+	defer server.Close()
+
+	got := fetchPages(t, server.URL+"/url", "data", PageOptions{
+		Mode: PageNext,
+		Next: "next",
+	})
+	verifyPages(t, "next url", got, 6)
+
+	got = fetchPages(t, server.URL+"/cursor", "data", PageOptions{
+		Mode:  PageNext,
+		Next:  "next",
+		Param: "cursor",
+	})
+	verifyPages(t, "next cursor", got, 6)
+}
+
+func TestPageOffset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			offset, _ := strconv.Atoi(query.Get("offset"))
+			page, _ := strconv.Atoi(query.Get("page"))
+			if page == 0 {
+				page = 1
+			}
+			if r.URL.Path == "/page" {
+				offset = (page - 1) * 3
+			}
+			end := offset + 3
+			if end > 7 {
+				end = 7
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if offset >= end {
+				fmt.Fprint(w, "[]")
+			} else {
+				fmt.Fprint(w, pageItemsJSON(offset, end))
+			}
+		}))
+	defer server.Close()
+
+	got := fetchPages(t, server.URL+"/offset", "", PageOptions{
+		Mode:  PageOffset,
+		Param: "offset",
+	})
+	verifyPages(t, "offset", got, 7)
+
+	got = fetchPages(t, server.URL+"/page", "", PageOptions{
+		Mode:  PagePage,
+		Param: "page",
+	})
+	verifyPages(t, "page", got, 7)
+
+	_, err := NewWithOptions([]string{server.URL + "/page"}, "", pageColumns,
+		&Options{
+			Page: PageOptions{
+				Mode:  PagePage,
+				Param: "page",
+				Max:   2,
+			},
+		})
+	if err == nil || !strings.Contains(err.Error(), "page limit 2 reached") {
+		t.Errorf("page limit not reported: %v", err)
+	}
+
+	_, err = NewWithOptions([]string{server.URL}, "", pageColumns, &Options{
+		Page: PageOptions{
+			Mode: PageOffset,
+		},
+	})
+	if err == nil {
+		t.Errorf("offset pagination without parameter accepted")
+	}
+}
+
+var linkNextTests = []struct {
+	header string
+	next   string
+}{
+	{
+		header: `<https://example.com/a?page=2>; rel="next"`,
+		next:   "https://example.com/a?page=2",
+	},
+	{
+		header: `<a?page=1>; rel="prev", <a?page=3>; rel=next`,
+		next:   "a?page=3",
+	},
+	{
+		header: `<a?page=3>; title="x"; rel="last next"`,
+		next:   "a?page=3",
+	},
+	{
+		header: `<a?page=1>; rel="prev"`,
+	},
+}
+
+func TestLinkNext(t *testing.T) {
+	for _, test := range linkNextTests {
+		next := linkNext([]string{test.header})
+		if next != test.next {
+			t.Errorf("linkNext(%s): got '%s', expected '%s'",
+				test.header, next, test.next)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// sniffSize specifies how many bytes of the input are used for
+// sniffing the input format.
+const sniffSize = 4096
+
+// csvDelimiters lists the CSV delimiters in their preference order.
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+// htmlPrefixes list the beginnings of HTML documents.
+var htmlPrefixes = [][]byte{
+	[]byte("<!doctype html"),
+	[]byte("<html"),
+	[]byte("<head"),
+	[]byte("<body"),
+	[]byte("<table"),
+}
+
+// binaryMagic defines the beginning of a binary data format.
+type binaryMagic struct {
+	prefix []byte
+	format Format
+}
+
+// binaryMagics list the beginnings of binary data formats. The list is
+// modified by RegisterMagic.
+var binaryMagics = []binaryMagic{
+	{[]byte("PAR1"), FormatParquet},
+	{[]byte(arrowFileMagic), FormatArrow},
+	{[]byte("\xff\xff\xff\xff"), FormatArrow},
+}
+
+// sniff peeks the beginning of the input and guesses its format. The
+// function returns the input for reading, the format, and the column
+// delimiter for CSV data.
+func sniff(in io.ReadCloser) (io.ReadCloser, Format, rune, error) {
+	r := bufio.NewReaderSize(in, sniffSize)
+	data, err := r.Peek(sniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, FormatUnknown, 0, err
+	}
+	format, comma := sniffFormat(data, err == io.EOF)
+	return &readCloser{
+		Reader: r,
+		closer: in,
+	}, format, comma, nil
+}
+
+// sniffFormat guesses the data format from the beginning of the
+// data. The eof specifies if the data holds the whole input. For CSV
+// data, the function returns also the column delimiter.
+func sniffFormat(data []byte, eof bool) (Format, rune) {
+	registry.RLock()
+	for _, magic := range binaryMagics {
+		if bytes.HasPrefix(data, magic.prefix) {
+			registry.RUnlock()
+			return magic.format, 0
+		}
+	}
+	registry.RUnlock()
+
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	data = bytes.TrimLeft(data, " \t\r\n")
+	if len(data) == 0 {
+		return FormatUnknown, 0
+	}
+
+	switch data[0] {
+	case '{', '[':
+		// NDJSON has a complete JSON value on its first line and more
+		// values on the following lines.
+		idx := bytes.IndexByte(data, '\n')
+		if idx > 0 && json.Valid(data[:idx]) {
+			rest := bytes.TrimLeft(data[idx:], " \t\r\n")
+			if len(rest) > 0 && (rest[0] == '{' || rest[0] == '[') {
+				return FormatNDJSON, 0
+			}
+		}
+		return FormatJSON, 0
+
+	case '<':
+		lower := bytes.ToLower(data)
+		for _, prefix := range htmlPrefixes {
+			if bytes.HasPrefix(lower, prefix) {
+				return FormatHTML, 0
+			}
+		}
+		// XHTML documents start with the XML declaration.
+		if bytes.Contains(lower, []byte("<!doctype html")) ||
+			bytes.Contains(lower, []byte("<html")) {
+			return FormatHTML, 0
+		}
+		return FormatXML, 0
+	}
+
+	comma := sniffDelimiter(data, eof)
+	if comma == 0 {
+		return FormatUnknown, 0
+	}
+	return FormatCSV, comma
+}
+
+// sniffDelimiter finds the CSV delimiter that has the same non-zero
+// count on all complete lines of the data. The function returns 0 if
+// the data does not have a consistent delimiter.
+func sniffDelimiter(data []byte, eof bool) rune {
+	lines := bytes.Split(data, []byte{'\n'})
+	if !eof && len(lines) > 1 {
+		// Drop the last partial line.
+		lines = lines[:len(lines)-1]
+	}
+
+	var result rune
+	var resultCount int
+
+	for _, delimiter := range csvDelimiters {
+		count := -1
+		for _, line := range lines {
+			line = bytes.TrimRight(line, "\r")
+			if len(line) == 0 {
+				continue
+			}
+			c := countDelimiters(line, delimiter)
+			if count < 0 {
+				count = c
+			} else if c != count {
+				count = 0
+			}
+			if count == 0 {
+				break
+			}
+		}
+		if count > resultCount {
+			result = delimiter
+			resultCount = count
+		}
+	}
+	return result
+}
+
+// countDelimiters counts the delimiters outside quoted fields.
+func countDelimiters(line []byte, delimiter rune) int {
+	var count int
+	var quoted bool
+	for _, r := range string(line) {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == delimiter && !quoted:
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,59 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"testing"
+)
+
+var sniffTests = []struct {
+	data   string
+	eof    bool
+	format Format
+	comma  rune
+}{
+	{`{"a": 1}`, true, FormatJSON, 0},
+	{"\xef\xbb\xbf\n [1, 2]", true, FormatJSON, 0},
+	{"{\"a\": 1}\n{\"a\": 2}\n", true, FormatNDJSON, 0},
+	{"{\n\"a\": 1\n}\n", true, FormatJSON, 0},
+	{"<!DOCTYPE html>\n<html></html>", true, FormatHTML, 0},
+	{"<?xml version=\"1.0\"?>\n<html xmlns=\"x\"></html>", true,
+		FormatHTML, 0},
+	{"<?xml version=\"1.0\"?>\n<rss></rss>", true, FormatXML, 0},
+	{"<feed><entry/></feed>", true, FormatXML, 0},
+	{"a,b\n1,2\n", true, FormatCSV, ','},
+	{"a;b;c\n1;2;3\n4;5;6", true, FormatCSV, ';'},
+	{"a\tb\n\"1\t2\"\t3\n", true, FormatCSV, '\t'},
+	{"a,b;c\n1,2;3\n4,5;6,7", false, FormatCSV, ','},
+	{"a,b\n1,2,3\n", true, FormatUnknown, 0},
+	{"plain text\n", true, FormatUnknown, 0},
+	{"", true, FormatUnknown, 0},
+	{"PAR1\x15\x04", false, FormatParquet, 0},
+	{"ARROW1\x00\x00", false, FormatArrow, 0},
+	{"\xff\xff\xff\xff\x10\x01", false, FormatArrow, 0},
+}
+
+func TestSniff(t *testing.T) {
+	for _, test := range sniffTests {
+		format, comma := sniffFormat([]byte(test.data), test.eof)
+		if format != test.format || comma != test.comma {
+			t.Errorf("sniffFormat(%q): got %s %q, expected %s %q",
+				test.data, format, comma, test.format, test.comma)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("NDJSON")
+	if err != nil || f != FormatNDJSON {
+		t.Errorf("ParseFormat(NDJSON): got %s, %v", f, err)
+	}
+	_, err = ParseFormat("unknown")
+	if err == nil {
+		t.Errorf("ParseFormat(unknown) succeeded")
+	}
+}
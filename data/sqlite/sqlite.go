@@ -0,0 +1,359 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+// Package sqlite implements the SQLite data format. The format is
+// registered to the data package when this package is imported:
+//
+//	import _ "github.com/markkurossi/iql/data/sqlite"
+//
+// The SQLite driver is implemented with cgo.
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/markkurossi/iql/data"
+	"github.com/markkurossi/iql/types"
+	// Register the SQLite database driver.
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var _ types.Source = &Source{}
+
+// Format is the SQLite data format.
+var Format data.Format
+
+func init() {
+	var err error
+	Format, err = data.Register("sqlite",
+		[]string{".db", ".sqlite", ".sqlite3"},
+		[]string{"application/vnd.sqlite3", "application/x-sqlite3"}, New)
+	if err != nil {
+		panic(err)
+	}
+	err = data.RegisterMagic(Format, []byte("SQLite format 3\x00"))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Source implements a data source from SQLite database files.
+type Source struct {
+	columns []types.ColumnSelector
+	rows    []types.Row
+}
+
+// New creates a new SQLite data source from the input. The
+// filter names the database table or view, or specifies the SQL
+// query that returns the source rows. The filter can be omitted if
+// the database has only one table. The column types are resolved from
+// the declared types of the result columns. The columns without a
+// declared type are resolved from their values.
+func New(input []io.ReadCloser, filter string,
+	columns []types.ColumnSelector) (types.Source, error) {
+
+	if len(input) == 0 {
+		return nil, errors.New("sqlite: no input")
+	}
+
+	var rows []types.Row
+
+	for idx, in := range input {
+		result, err := query(in, filter, columns, idx == 0)
+		if err != nil {
+			for _, rest := range input[idx+1:] {
+				rest.Close()
+			}
+			return nil, err
+		}
+		if idx == 0 {
+			columns = result.columns
+		} else {
+			for i, col := range result.columns {
+				if col.Type > columns[i].Type {
+					columns[i].Type = col.Type
+				}
+			}
+		}
+		rows = append(rows, result.rows...)
+	}
+
+	return &Source{
+		columns: columns,
+		rows:    rows,
+	}, nil
+}
+
+// Columns implements the Source.Columns().
+func (src *Source) Columns() []types.ColumnSelector {
+	return src.columns
+}
+
+// Get implements the Source.Get().
+func (src *Source) Get() ([]types.Row, error) {
+	return src.rows, nil
+}
+
+// Rows implements the Source.Rows().
+func (src *Source) Rows() (types.RowIterator, error) {
+	return types.NewRowsIterator(src.rows), nil
+}
+
+// query runs the filter query against the database input and
+// returns the selected columns and their rows. If all is true, the
+// unselected result columns are appended to the returned columns.
+func query(in io.ReadCloser, filter string,
+	selected []types.ColumnSelector, all bool) (*Source, error) {
+
+	path, cleanup, err := dbPath(in)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	dsn := &url.URL{
+		Scheme:   "file",
+		Path:     path,
+		RawQuery: "mode=ro",
+	}
+	db, err := sql.Open("sqlite3", dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: %s", err)
+	}
+	defer db.Close()
+
+	q, err := filterQuery(db, filter)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(q)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: %s", err)
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: %s", err)
+	}
+
+	// Collect all column names; unselected columns are appended to
+	// the source's columns array.
+	columns := make([]types.ColumnSelector, len(selected))
+	copy(columns, selected)
+	if all {
+		seen := make(map[string]bool)
+		for _, col := range columns {
+			seen[col.Name.Column] = true
+		}
+		for _, ct := range columnTypes {
+			if !seen[ct.Name()] {
+				seen[ct.Name()] = true
+				columns = append(columns, types.ColumnSelector{
+					Name: types.Reference{
+						Column: ct.Name(),
+					},
+				})
+			}
+		}
+	}
+	names := make(map[string]int)
+	for i, ct := range columnTypes {
+		if _, ok := names[ct.Name()]; !ok {
+			names[ct.Name()] = i
+		}
+	}
+	var indices []int
+	for i, col := range columns {
+		idx, ok := names[col.Name.Column]
+		if !ok {
+			return nil, fmt.Errorf("sqlite: unknown column: %s",
+				col.Name.Column)
+		}
+		indices = append(indices, idx)
+		columns[i].Type = columnType(columnTypes[idx].DatabaseTypeName())
+	}
+
+	result := &Source{
+		columns: columns,
+	}
+	values := make([]interface{}, len(columnTypes))
+	ptrs := make([]interface{}, len(columnTypes))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("sqlite: %s", err)
+		}
+		var row []types.Value
+		for i, idx := range indices {
+			val := value(values[idx])
+			columns[i].ResolveValue(val)
+			row = append(row, val)
+		}
+		result.rows = append(result.rows, newRow(row))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite: %s", err)
+	}
+	return result, nil
+}
+
+// dbPath returns the file path of the database input. Inputs that
+// are not files are copied to temporary files. The cleanup function
+// closes the input and removes the temporary file.
+func dbPath(in io.ReadCloser) (string, func(), error) {
+	f, ok := in.(*os.File)
+	if ok && isRegular(f) {
+		return f.Name(), func() {
+			f.Close()
+		}, nil
+	}
+	defer in.Close()
+
+	tmp, err := ioutil.TempFile("", "iql-*.sqlite")
+	if err != nil {
+		return "", nil, fmt.Errorf("sqlite: %s", err)
+	}
+	cleanup := func() {
+		os.Remove(tmp.Name())
+	}
+	_, err = io.Copy(tmp, in)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("sqlite: %s", err)
+	}
+	return tmp.Name(), cleanup, nil
+}
+
+// filterQuery creates the SQL query from the filter. The filter is
+// either a SQL query or the name of a table or view. The empty filter
+// selects the only table of the database.
+func filterQuery(db *sql.DB, filter string) (string, error) {
+	filter = strings.TrimSpace(filter)
+	if len(filter) == 0 {
+		tables, err := listTables(db)
+		if err != nil {
+			return "", err
+		}
+		if len(tables) != 1 {
+			return "", fmt.Errorf("sqlite: filter must name a table: %s",
+				strings.Join(tables, ", "))
+		}
+		filter = tables[0]
+	}
+	word := strings.ToUpper(strings.Fields(filter)[0])
+	switch word {
+	case "SELECT", "WITH", "VALUES":
+		return filter, nil
+	}
+	return fmt.Sprintf(`SELECT * FROM "%s"`,
+		strings.ReplaceAll(filter, `"`, `""`)), nil
+}
+
+// listTables returns the names of the user tables and views of the
+// database.
+func listTables(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT name FROM sqlite_master
+WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'
+ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: %s", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("sqlite: %s", err)
+		}
+		tables = append(tables, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite: %s", err)
+	}
+	if len(tables) == 0 {
+		return nil, errors.New("sqlite: no tables")
+	}
+	return tables, nil
+}
+
+// columnType maps the declared column type to the IQL type. The
+// mapping follows the SQLite type affinity rules and recognizes the
+// boolean and date types. The columns without a declared type are
+// resolved from their values.
+func columnType(decl string) types.Type {
+	decl = strings.ToUpper(decl)
+	switch {
+	case len(decl) == 0:
+		return types.Bool
+	case strings.Contains(decl, "INT"):
+		return types.Int
+	case strings.Contains(decl, "CHAR"), strings.Contains(decl, "CLOB"),
+		strings.Contains(decl, "TEXT"), strings.Contains(decl, "BLOB"):
+		return types.String
+	case strings.Contains(decl, "BOOL"):
+		return types.Bool
+	case strings.Contains(decl, "DATE"), strings.Contains(decl, "TIME"):
+		return types.Date
+	default:
+		return types.Float
+	}
+}
+
+// value converts the database value to IQL value.
+func value(v interface{}) types.Value {
+	switch val := v.(type) {
+	case nil:
+		return types.Null
+	case int64:
+		return types.IntValue(val)
+	case float64:
+		return types.FloatValue(val)
+	case bool:
+		return types.BoolValue(val)
+	case []byte:
+		return types.StringValue(val)
+	case string:
+		return types.StringValue(val)
+	case time.Time:
+		return types.DateValue(val.UTC())
+	default:
+		return types.StringValue(fmt.Sprintf("%v", val))
+	}
+}
+
+// newRow creates a row from the values.
+func newRow(values []types.Value) types.Row {
+	var row types.Row
+	for _, val := range values {
+		if val == types.Null {
+			row = append(row, types.NullColumn{})
+		} else {
+			row = append(row, types.NewValueColumn(val))
+		}
+	}
+	return row
+}
+
+// isRegular tests if the file is a regular file.
+func isRegular(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode().IsRegular()
+}
@@ -0,0 +1,217 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package sqlite
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/markkurossi/iql/data"
+	"github.com/markkurossi/iql/types"
+)
+
+var sqliteSchema = []string{
+	`CREATE TABLE customers (
+	id INTEGER PRIMARY KEY,
+	name VARCHAR(32),
+	balance REAL,
+	since DATE,
+	vip BOOLEAN
+)`,
+	`CREATE TABLE orders (id INTEGER, customer INTEGER, amount NUMERIC)`,
+	`INSERT INTO customers VALUES
+	(1, 'alpha', 10.5, '2021-03-01', 1),
+	(2, 'beta', NULL, '2021-03-02', 0),
+	(3, 'gamma', 7, NULL, 0)`,
+	`INSERT INTO orders VALUES (1, 1, 5), (2, 1, 2.5), (3, 3, 1)`,
+}
+
+func createTestDB(t *testing.T) string {
+	file := filepath.Join(t.TempDir(), "test.sqlite")
+	db, err := sql.Open("sqlite3", file)
+	if err != nil {
+		t.Fatalf("failed to open %s: %s", file, err)
+	}
+	defer db.Close()
+	for _, stmt := range sqliteSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("failed to create database: %s", err)
+		}
+	}
+	return file
+}
+
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	file := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", file, err)
+	}
+	return file
+}
+
+func selectColumns(names ...string) []types.ColumnSelector {
+	var columns []types.ColumnSelector
+	for _, name := range names {
+		columns = append(columns, types.ColumnSelector{
+			Name: types.Reference{
+				Column: name,
+			},
+		})
+	}
+	return columns
+}
+
+func verifyRows(t *testing.T, rows []types.Row, expected [][]string) {
+	t.Helper()
+	if len(rows) != len(expected) {
+		t.Fatalf("got %d rows, expected %d", len(rows), len(expected))
+	}
+	for i, row := range rows {
+		if len(row) != len(expected[i]) {
+			t.Fatalf("row %d: got %d columns, expected %d",
+				i, len(row), len(expected[i]))
+		}
+		for j, col := range row {
+			if col.String() != expected[i][j] {
+				t.Errorf("row %d: column %d: got %s, expected %s",
+					i, j, col, expected[i][j])
+			}
+		}
+	}
+}
+
+func TestSQLite(t *testing.T) {
+	file := createTestDB(t)
+
+	source, err := data.New([]string{file}, "customers", nil)
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+	expected := []struct {
+		name string
+		t    types.Type
+	}{
+		{"id", types.Int},
+		{"name", types.String},
+		{"balance", types.Float},
+		{"since", types.Date},
+		{"vip", types.Bool},
+	}
+	columns := source.Columns()
+	if len(columns) != len(expected) {
+		t.Fatalf("got %d columns, expected %d", len(columns), len(expected))
+	}
+	for i, col := range columns {
+		if col.Name.Column != expected[i].name || col.Type != expected[i].t {
+			t.Errorf("column %d: got %s %s, expected %s %s", i,
+				col.Name.Column, col.Type, expected[i].name, expected[i].t)
+		}
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	verifyRows(t, rows, [][]string{
+		{"1", "alpha", "10.5", "2021-03-01 00:00:00", "true"},
+		{"2", "beta", "NULL", "2021-03-02 00:00:00", "false"},
+		{"3", "gamma", "7", "NULL", "false"},
+	})
+}
+
+func TestSQLiteQuery(t *testing.T) {
+	file := createTestDB(t)
+
+	source, err := data.New([]string{file}, `
+SELECT c.name, COUNT(*) AS count, SUM(o.amount) AS total
+FROM customers c JOIN orders o ON c.id = o.customer
+GROUP BY c.name ORDER BY c.name`, selectColumns("total", "name"))
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+	columns := source.Columns()
+	expected := []types.Type{types.Float, types.String, types.Int}
+	if len(columns) != len(expected) {
+		t.Fatalf("got %d columns, expected %d", len(columns), len(expected))
+	}
+	for i, col := range columns {
+		if col.Type != expected[i] {
+			t.Errorf("column %s: got %s, expected %s", col, col.Type,
+				expected[i])
+		}
+	}
+	rows, err := source.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	verifyRows(t, rows, [][]string{
+		{"7.5", "alpha", "2"},
+		{"1", "gamma", "1"},
+	})
+}
+
+func TestSQLiteCompressed(t *testing.T) {
+	db, err := ioutil.ReadFile(createTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(db)
+	w.Close()
+
+	// The format of the files without known suffixes is sniffed.
+	for _, file := range []string{
+		writeTestFile(t, "test.db.gz", buf.Bytes()),
+		writeTestFile(t, "test.gz", buf.Bytes()),
+		writeTestFile(t, "test.dat", db),
+	} {
+		source, err := data.New([]string{file}, "orders", nil)
+		if err != nil {
+			t.Fatalf("%s: New failed: %s", file, err)
+		}
+		rows, err := source.Get()
+		if err != nil {
+			t.Fatalf("%s: Get failed: %s", file, err)
+		}
+		verifyRows(t, rows, [][]string{
+			{"1", "1", "5"},
+			{"2", "1", "2.5"},
+			{"3", "3", "1"},
+		})
+	}
+}
+
+func TestSQLiteFormat(t *testing.T) {
+	f, err := data.ParseFormat("sqlite")
+	if err != nil || f != Format {
+		t.Errorf("ParseFormat(sqlite): got %s, %v", f, err)
+	}
+}
+
+func TestSQLiteErrors(t *testing.T) {
+	file := createTestDB(t)
+
+	for _, test := range []struct {
+		filter  string
+		columns []types.ColumnSelector
+	}{
+		{"", nil},
+		{"missing", nil},
+		{"SELECT * FROM missing", nil},
+		{"customers", selectColumns("missing")},
+		{"DELETE FROM customers", nil},
+	} {
+		_, err := data.New([]string{file}, test.filter, test.columns)
+		if err == nil {
+			t.Errorf("New(%q, %v) succeeded", test.filter, test.columns)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Thrift compact protocol types.
+const (
+	thriftStop      = 0
+	thriftTrue      = 1
+	thriftFalse     = 2
+	thriftByte      = 3
+	thriftI16       = 4
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftDouble    = 7
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftSet       = 10
+	thriftMap       = 11
+	thriftStruct    = 12
+	thriftMaxDepth  = 64
+	thriftMaxLength = 1 << 28
+)
+
+var errThriftTruncated = errors.New("thrift: truncated input")
+
+// thriftFields holds the fields of a decoded thrift struct. The
+// field values are bool, int64, float64, []byte, []interface{}, and
+// thriftFields values. The maps are decoded into lists of key and
+// value pairs.
+type thriftFields map[int16]interface{}
+
+// thriftDecoder decodes thrift compact protocol structs. The decoder
+// does not need the struct definitions; the typed accessors of
+// thriftFields interpret the decoded fields.
+type thriftDecoder struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+// decodeThrift decodes a thrift struct from the data. The function
+// returns the struct fields and the number of bytes decoded.
+func decodeThrift(data []byte) (thriftFields, int, error) {
+	d := &thriftDecoder{
+		data: data,
+	}
+	fields, err := d.readStruct()
+	if err != nil {
+		return nil, 0, err
+	}
+	return fields, d.pos, nil
+}
+
+func (d *thriftDecoder) readByte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, errThriftTruncated
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *thriftDecoder) readVarint() (uint64, error) {
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		return 0, errThriftTruncated
+	}
+	d.pos += n
+	return v, nil
+}
+
+func (d *thriftDecoder) readZigzag() (int64, error) {
+	v, err := d.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	return int64(v>>1) ^ -int64(v&1), nil
+}
+
+func (d *thriftDecoder) readLength() (int, error) {
+	v, err := d.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	if v > thriftMaxLength || int(v) > len(d.data)-d.pos {
+		return 0, errThriftTruncated
+	}
+	return int(v), nil
+}
+
+// nest enters a nested struct or container value. The function
+// returns an error if the values are nested too deep. The caller must
+// call unnest when it leaves the nested value.
+func (d *thriftDecoder) nest() error {
+	d.depth++
+	if d.depth > thriftMaxDepth {
+		return errors.New("thrift: too deep nesting")
+	}
+	return nil
+}
+
+func (d *thriftDecoder) unnest() {
+	d.depth--
+}
+
+func (d *thriftDecoder) readStruct() (thriftFields, error) {
+	defer d.unnest()
+	if err := d.nest(); err != nil {
+		return nil, err
+	}
+
+	fields := make(thriftFields)
+	var id int16
+	for {
+		b, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		t := b & 0x0f
+		if t == thriftStop {
+			return fields, nil
+		}
+		delta := b >> 4
+		if delta == 0 {
+			v, err := d.readZigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		} else {
+			id += int16(delta)
+		}
+		var v interface{}
+		switch t {
+		case thriftTrue:
+			v = true
+		case thriftFalse:
+			v = false
+		default:
+			v, err = d.readValue(t)
+			if err != nil {
+				return nil, err
+			}
+		}
+		fields[id] = v
+	}
+}
+
+func (d *thriftDecoder) readValue(t byte) (interface{}, error) {
+	switch t {
+	case thriftTrue, thriftFalse:
+		// Boolean list elements.
+		b, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		return b == thriftTrue, nil
+
+	case thriftByte:
+		b, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		return int64(int8(b)), nil
+
+	case thriftI16, thriftI32, thriftI64:
+		return d.readZigzag()
+
+	case thriftDouble:
+		if d.pos+8 > len(d.data) {
+			return nil, errThriftTruncated
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(d.data[d.pos:]))
+		d.pos += 8
+		return v, nil
+
+	case thriftBinary:
+		n, err := d.readLength()
+		if err != nil {
+			return nil, err
+		}
+		v := d.data[d.pos : d.pos+n]
+		d.pos += n
+		return v, nil
+
+	case thriftList, thriftSet:
+		defer d.unnest()
+		if err := d.nest(); err != nil {
+			return nil, err
+		}
+		b, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		n := int(b >> 4)
+		if n == 15 {
+			n, err = d.readLength()
+			if err != nil {
+				return nil, err
+			}
+		}
+		var list []interface{}
+		for i := 0; i < n; i++ {
+			v, err := d.readValue(b & 0x0f)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+
+	case thriftMap:
+		defer d.unnest()
+		if err := d.nest(); err != nil {
+			return nil, err
+		}
+		n, err := d.readLength()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return []interface{}(nil), nil
+		}
+		b, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		var list []interface{}
+		for i := 0; i < n; i++ {
+			k, err := d.readValue(b >> 4)
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.readValue(b & 0x0f)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, k, v)
+		}
+		return list, nil
+
+	case thriftStruct:
+		return d.readStruct()
+
+	default:
+		return nil, fmt.Errorf("thrift: invalid type %d", t)
+	}
+}
+
+// Has tests if the struct has the field.
+func (f thriftFields) Has(id int16) bool {
+	_, ok := f[id]
+	return ok
+}
+
+// Int returns the integer field value or def if the field is not set.
+func (f thriftFields) Int(id int16, def int64) int64 {
+	v, ok := f[id].(int64)
+	if !ok {
+		return def
+	}
+	return v
+}
+
+// Bool returns the boolean field value or def if the field is not
+// set.
+func (f thriftFields) Bool(id int16, def bool) bool {
+	v, ok := f[id].(bool)
+	if !ok {
+		return def
+	}
+	return v
+}
+
+// Binary returns the binary field value.
+func (f thriftFields) Binary(id int16) []byte {
+	v, _ := f[id].([]byte)
+	return v
+}
+
+// String returns the string field value.
+func (f thriftFields) String(id int16) string {
+	return string(f.Binary(id))
+}
+
+// Struct returns the struct field value or nil if the field is not
+// set.
+func (f thriftFields) Struct(id int16) thriftFields {
+	v, _ := f[id].(thriftFields)
+	return v
+}
+
+// List returns the list field value.
+func (f thriftFields) List(id int16) []interface{} {
+	v, _ := f[id].([]interface{})
+	return v
+}
+
+// Structs returns the struct elements of the list field value.
+func (f thriftFields) Structs(id int16) []thriftFields {
+	var result []thriftFields
+	for _, el := range f.List(id) {
+		v, ok := el.(thriftFields)
+		if ok {
+			result = append(result, v)
+		}
+	}
+	return result
+}
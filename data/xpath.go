@@ -0,0 +1,429 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package data
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xmlNode implements an XML document node. The document node is the
+// parent of the root element. The attributes and text selections are
+// returned as nodes without children.
+type xmlNode struct {
+	name     string
+	attrs    []xml.Attr
+	parent   *xmlNode
+	children []*xmlNode
+	text     strings.Builder
+}
+
+// Text returns the text content of the node.
+func (n *xmlNode) Text() string {
+	return strings.TrimSpace(n.text.String())
+}
+
+func (n *xmlNode) attr(name string) (string, bool) {
+	for _, attr := range n.attrs {
+		if attr.Name.Local == name {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+func (n *xmlNode) document() *xmlNode {
+	for n.parent != nil {
+		n = n.parent
+	}
+	return n
+}
+
+// parseXML parses the XML document. Element and attribute names are
+// stored without their namespaces.
+func parseXML(in io.Reader) (*xmlNode, error) {
+	doc := new(xmlNode)
+	stack := []*xmlNode{doc}
+
+	decoder := xml.NewDecoder(in)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			parent := stack[len(stack)-1]
+			n := &xmlNode{
+				name:   t.Name.Local,
+				attrs:  t.Attr,
+				parent: parent,
+			}
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+
+		case xml.CharData:
+			// The text content of an element contains the text of
+			// all its descendants.
+			for _, n := range stack {
+				n.text.Write(t)
+			}
+		}
+	}
+	if len(doc.children) == 0 {
+		return nil, fmt.Errorf("xml: no root element")
+	}
+	return doc, nil
+}
+
+// xpathAxis specifies how an XPath step selects nodes.
+type xpathAxis int
+
+const (
+	axisChild xpathAxis = iota
+	axisDescendant
+	axisSelf
+	axisParent
+	axisAttribute
+	axisText
+)
+
+// xpathStep implements a location step of the XPath selector.
+type xpathStep struct {
+	axis  xpathAxis
+	name  string
+	preds []xpathPred
+}
+
+// xpathPred implements an XPath predicate. The predicate is either a
+// position, an attribute or child element existence test, or an
+// attribute or child element value comparison.
+type xpathPred struct {
+	position int
+	attr     bool
+	name     string
+	value    *string
+}
+
+// xpath implements a subset of the XPath location paths:
+//
+//	/      selects from the document
+//	//     selects from the descendants
+//	name   selects child elements, '*' selects all elements
+//	.      selects the current node
+//	..     selects the parent node
+//	@name  selects attributes, '@*' selects all attributes
+//	text() selects the text of the node
+//
+// The steps can have predicates: [n] selects the nth node, [@a] and
+// [a] test for the attribute and element existence, and [@a='v'] and
+// [a='v'] compare their values.
+type xpath struct {
+	absolute bool
+	steps    []xpathStep
+}
+
+// parseXPath parses the XPath selector.
+func parseXPath(selector string) (*xpath, error) {
+	result := new(xpath)
+	input := strings.TrimSpace(selector)
+	if strings.HasPrefix(input, "/") && !strings.HasPrefix(input, "//") {
+		result.absolute = true
+		input = input[1:]
+		if len(input) == 0 {
+			return nil, fmt.Errorf("xpath: empty selector: %s", selector)
+		}
+	} else if strings.HasPrefix(input, "//") {
+		result.absolute = true
+	}
+
+	for len(input) > 0 {
+		axis := axisChild
+		if strings.HasPrefix(input, "//") {
+			axis = axisDescendant
+			input = input[2:]
+		} else if len(result.steps) > 0 {
+			if input[0] != '/' {
+				return nil, fmt.Errorf("xpath: unexpected '%s': %s",
+					input, selector)
+			}
+			input = input[1:]
+		}
+		end := stepEnd(input)
+		step, err := parseStep(input[:end], axis)
+		if err != nil {
+			return nil, fmt.Errorf("xpath: %s: %s", err, selector)
+		}
+		result.steps = append(result.steps, *step)
+		input = input[end:]
+	}
+	if len(result.steps) == 0 {
+		return nil, fmt.Errorf("xpath: empty selector: %s", selector)
+	}
+	for idx, step := range result.steps {
+		if (step.axis == axisAttribute || step.axis == axisText) &&
+			idx+1 < len(result.steps) {
+			return nil, fmt.Errorf("xpath: %s must be the last step: %s",
+				step.name, selector)
+		}
+	}
+	return result, nil
+}
+
+// stepEnd returns the end index of the first step of the input.
+func stepEnd(input string) int {
+	var quote rune
+	var depth int
+	for idx, r := range input {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		case r == '/' && depth == 0:
+			return idx
+		}
+	}
+	return len(input)
+}
+
+func parseStep(input string, axis xpathAxis) (*xpathStep, error) {
+	step := &xpathStep{
+		axis: axis,
+	}
+	idx := strings.IndexByte(input, '[')
+	name := input
+	if idx >= 0 {
+		name = input[:idx]
+		preds := input[idx:]
+		for len(preds) > 0 {
+			if preds[0] != '[' {
+				return nil, fmt.Errorf("unexpected '%s'", preds)
+			}
+			end := predEnd(preds)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated predicate '%s'", preds)
+			}
+			pred, err := parsePred(preds[1:end])
+			if err != nil {
+				return nil, err
+			}
+			step.preds = append(step.preds, *pred)
+			preds = preds[end+1:]
+		}
+	}
+	name = strings.TrimSpace(name)
+
+	switch {
+	case name == ".":
+		if axis == axisDescendant {
+			return nil, fmt.Errorf("unexpected '//.'")
+		}
+		step.axis = axisSelf
+	case name == "..":
+		if axis == axisDescendant {
+			return nil, fmt.Errorf("unexpected '//..'")
+		}
+		step.axis = axisParent
+	case name == "text()":
+		step.axis = axisText
+		step.name = name
+	case strings.HasPrefix(name, "@"):
+		step.axis = axisAttribute
+		step.name = localName(name[1:])
+	default:
+		step.name = localName(name)
+	}
+	if len(step.name) == 0 && (step.axis == axisChild ||
+		step.axis == axisDescendant || step.axis == axisAttribute) {
+		return nil, fmt.Errorf("empty step")
+	}
+	if len(step.preds) > 0 &&
+		(step.axis == axisAttribute || step.axis == axisText) {
+		return nil, fmt.Errorf("predicates not supported for %s", name)
+	}
+	return step, nil
+}
+
+// predEnd returns the index of the closing bracket of the predicate
+// or -1 if the predicate is not terminated.
+func predEnd(input string) int {
+	var quote rune
+	for idx, r := range input {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ']':
+			return idx
+		}
+	}
+	return -1
+}
+
+func parsePred(input string) (*xpathPred, error) {
+	input = strings.TrimSpace(input)
+	position, err := strconv.Atoi(input)
+	if err == nil {
+		if position <= 0 {
+			return nil, fmt.Errorf("invalid position %d", position)
+		}
+		return &xpathPred{
+			position: position,
+		}, nil
+	}
+	pred := new(xpathPred)
+	name := input
+	idx := strings.IndexByte(input, '=')
+	if idx >= 0 {
+		name = strings.TrimSpace(input[:idx])
+		value := strings.TrimSpace(input[idx+1:])
+		if len(value) < 2 || (value[0] != '\'' && value[0] != '"') ||
+			value[len(value)-1] != value[0] {
+			return nil, fmt.Errorf("invalid predicate value '%s'", value)
+		}
+		value = value[1 : len(value)-1]
+		pred.value = &value
+	}
+	if strings.HasPrefix(name, "@") {
+		pred.attr = true
+		name = name[1:]
+	}
+	pred.name = localName(name)
+	if len(pred.name) == 0 {
+		return nil, fmt.Errorf("invalid predicate '%s'", input)
+	}
+	return pred, nil
+}
+
+// localName removes the namespace prefix from the name.
+func localName(name string) string {
+	idx := strings.IndexByte(name, ':')
+	if idx >= 0 {
+		return name[idx+1:]
+	}
+	return name
+}
+
+// Select selects the nodes that match the selector from the context
+// node.
+func (p *xpath) Select(context *xmlNode) []*xmlNode {
+	nodes := []*xmlNode{context}
+	if p.absolute {
+		nodes = []*xmlNode{context.document()}
+	}
+	for _, step := range p.steps {
+		var result []*xmlNode
+		seen := make(map[*xmlNode]bool)
+		add := func(matches []*xmlNode) {
+			for _, m := range matches {
+				if !seen[m] {
+					seen[m] = true
+					result = append(result, m)
+				}
+			}
+		}
+		for _, n := range nodes {
+			switch step.axis {
+			case axisChild:
+				add(step.filter(n.children))
+			case axisDescendant:
+				n.walk(func(d *xmlNode) {
+					add(step.filter(d.children))
+				})
+			case axisSelf:
+				add(step.filter([]*xmlNode{n}))
+			case axisParent:
+				if n.parent != nil {
+					add(step.filter([]*xmlNode{n.parent}))
+				}
+			case axisAttribute:
+				for _, attr := range n.attrs {
+					if step.name == "*" || attr.Name.Local == step.name {
+						v := &xmlNode{
+							name: attr.Name.Local,
+						}
+						v.text.WriteString(attr.Value)
+						result = append(result, v)
+					}
+				}
+			case axisText:
+				v := new(xmlNode)
+				v.text.WriteString(n.text.String())
+				result = append(result, v)
+			}
+		}
+		nodes = result
+	}
+	return nodes
+}
+
+// walk calls the function for the node and all its descendants in
+// the document order.
+func (n *xmlNode) walk(f func(n *xmlNode)) {
+	f(n)
+	for _, child := range n.children {
+		child.walk(f)
+	}
+}
+
+// filter returns the nodes that match the step name and predicates.
+func (step *xpathStep) filter(nodes []*xmlNode) []*xmlNode {
+	var result []*xmlNode
+	for _, n := range nodes {
+		if step.axis != axisSelf && step.axis != axisParent &&
+			step.name != "*" && n.name != step.name {
+			continue
+		}
+		result = append(result, n)
+	}
+	for _, pred := range step.preds {
+		var matches []*xmlNode
+		for idx, n := range result {
+			if pred.match(idx+1, n) {
+				matches = append(matches, n)
+			}
+		}
+		result = matches
+	}
+	return result
+}
+
+func (pred *xpathPred) match(position int, n *xmlNode) bool {
+	if pred.position > 0 {
+		return position == pred.position
+	}
+	if pred.attr {
+		value, ok := n.attr(pred.name)
+		return ok && (pred.value == nil || value == *pred.value)
+	}
+	for _, child := range n.children {
+		if child.name == pred.name &&
+			(pred.value == nil || child.Text() == *pred.value) {
+			return true
+		}
+	}
+	return false
+}
@@ -5,8 +5,11 @@ go 1.16
 require (
 	github.com/PuerkitoBio/goquery v1.6.1
 	github.com/andybalholm/cascadia v1.2.0 // indirect
+	github.com/golang/snappy v0.0.3
 	github.com/markkurossi/jsonq v0.0.0-20210109084605-ee95c910c453
 	github.com/markkurossi/tabulate v0.0.0-20210320081245-b720f0e5685a
 	github.com/markkurossi/vt100 v0.0.0-20210316192307-a09f3f88c5ec
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/ulikunitz/xz v0.5.10
 	golang.org/x/net v0.0.0-20210316092652-d523dce5a7f4 // indirect
 )
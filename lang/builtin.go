@@ -26,6 +26,7 @@ var builtIns = []Function{
 		MinArgs:      1,
 		MaxArgs:      1,
 		IsIdempotent: idempotentTrue,
+		Aggregate:    true,
 	},
 	{
 		Name:         "COUNT",
@@ -33,6 +34,7 @@ var builtIns = []Function{
 		MinArgs:      1,
 		MaxArgs:      1,
 		IsIdempotent: idempotentTrue,
+		Aggregate:    true,
 	},
 	{
 		Name:         "MAX",
@@ -40,6 +42,7 @@ var builtIns = []Function{
 		MinArgs:      1,
 		MaxArgs:      1,
 		IsIdempotent: idempotentTrue,
+		Aggregate:    true,
 	},
 	{
 		Name:         "MIN",
@@ -47,6 +50,7 @@ var builtIns = []Function{
 		MinArgs:      1,
 		MaxArgs:      1,
 		IsIdempotent: idempotentTrue,
+		Aggregate:    true,
 	},
 	{
 		Name:         "SUM",
@@ -54,6 +58,7 @@ var builtIns = []Function{
 		MinArgs:      1,
 		MaxArgs:      1,
 		IsIdempotent: idempotentTrue,
+		Aggregate:    true,
 	},
 	{
 		Name:         "NULLIF",
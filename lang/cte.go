@@ -0,0 +1,141 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"fmt"
+
+	"github.com/markkurossi/iql/types"
+)
+
+var (
+	_ types.Source = &CTE{}
+)
+
+// CTE implements common table expressions of the WITH clause. The
+// recursive table expressions evaluate the Query first and then the
+// Recursive query repeatedly. The Recursive query reads the rows that
+// the previous iteration produced from the work table. The
+// evaluation ends when the iteration does not produce new rows.
+type CTE struct {
+	Name        string
+	ColumnNames []string
+	Query       *Query
+	Recursive   *Query
+	All         bool
+	work        *Table
+	columns     []types.ColumnSelector
+	rows        []types.Row
+	evaluated   bool
+}
+
+// Columns implements the Source.Columns().
+func (cte *CTE) Columns() []types.ColumnSelector {
+	return cte.columns
+}
+
+// Get implements the Source.Get().
+func (cte *CTE) Get() ([]types.Row, error) {
+	if cte.evaluated {
+		return cte.rows, nil
+	}
+	rows, err := cte.Query.Get()
+	if err != nil {
+		return nil, err
+	}
+	cte.columns, err = cte.rename(cte.Query.Columns())
+	if err != nil {
+		return nil, err
+	}
+	if cte.Recursive == nil {
+		cte.rows = rows
+		cte.evaluated = true
+		return cte.rows, nil
+	}
+
+	seen := make(map[string]bool)
+	add := func(rows []types.Row) []types.Row {
+		if cte.All {
+			return rows
+		}
+		var result []types.Row
+		for _, row := range rows {
+			key := rowKey(cte.columns, row)
+			if !seen[key] {
+				seen[key] = true
+				result = append(result, row)
+			}
+		}
+		return result
+	}
+
+	// The recursive query is evaluated once for each iteration so
+	// its sources are materialized.
+	for _, from := range cte.Recursive.From {
+		if from.Source == cte.work {
+			continue
+		}
+		if _, err := from.Source.Get(); err != nil {
+			return nil, err
+		}
+	}
+
+	cte.work.columns = cte.columns
+	working := add(rows)
+	result := working
+	for len(working) > 0 {
+		cte.work.rows = working
+		cte.Recursive.reset()
+		rows, err := cte.Recursive.Get()
+		if err != nil {
+			return nil, err
+		}
+		columns := cte.Recursive.Columns()
+		if len(columns) != len(cte.columns) {
+			return nil, fmt.Errorf("%s: recursive query returns %d columns, "+
+				"expected %d", cte.Name, len(columns), len(cte.columns))
+		}
+		for i, col := range columns {
+			if col.Type > cte.columns[i].Type {
+				cte.columns[i].Type = col.Type
+			}
+		}
+		working = add(rows)
+		result = append(result, working...)
+	}
+	cte.work.rows = nil
+	cte.rows = result
+	cte.evaluated = true
+
+	return cte.rows, nil
+}
+
+// Rows implements the Source.Rows().
+func (cte *CTE) Rows() (types.RowIterator, error) {
+	rows, err := cte.Get()
+	if err != nil {
+		return nil, err
+	}
+	return types.NewRowsIterator(rows), nil
+}
+
+// rename returns a copy of the query columns, renamed with the column
+// names of the table expression.
+func (cte *CTE) rename(columns []types.ColumnSelector) (
+	[]types.ColumnSelector, error) {
+
+	if len(cte.ColumnNames) > 0 && len(cte.ColumnNames) != len(columns) {
+		return nil, fmt.Errorf("%s: query returns %d columns, expected %d",
+			cte.Name, len(columns), len(cte.ColumnNames))
+	}
+	result := make([]types.ColumnSelector, len(columns))
+	copy(result, columns)
+	for i, name := range cte.ColumnNames {
+		result[i].As = name
+	}
+	return result, nil
+}
@@ -0,0 +1,314 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/markkurossi/iql/types"
+)
+
+// Explain specifies if the query is explained instead of returning
+// its result rows.
+type Explain int
+
+// Explain modes.
+const (
+	ExplainNone Explain = iota
+	ExplainPlan
+	ExplainAnalyze
+)
+
+// stage holds the execution statistics of a query stage.
+type stage struct {
+	rows int
+	time time.Duration
+}
+
+func (s *stage) add(rows int, start time.Time) {
+	s.rows += rows
+	s.time += time.Since(start)
+}
+
+// stats holds the execution statistics of the EXPLAIN ANALYZE
+// queries. The sources, filters, and joined stages are indexed by the
+// query source index. The joined stages count the rows after the
+// source has been joined to its preceding sources and the join terms
+// of the WHERE expression have been evaluated. The sources are
+// streamed through the joins so the time of the where stage covers
+// the whole scan and join pipeline.
+type stats struct {
+	sources []stage
+	filters []stage
+	joined  []stage
+	where   stage
+	group   stage
+	having  stage
+	selects stage
+	order   stage
+	limit   stage
+}
+
+func newStats(sources int) *stats {
+	return &stats{
+		sources: make([]stage, sources),
+		filters: make([]stage, sources),
+		joined:  make([]stage, sources),
+	}
+}
+
+// statsIterator collects the row count and read time of a row
+// iterator.
+type statsIterator struct {
+	it    types.RowIterator
+	stage *stage
+}
+
+// Next implements the RowIterator.Next().
+func (it *statsIterator) Next() (types.Row, error) {
+	start := time.Now()
+	row, err := it.it.Next()
+	if err != nil {
+		it.stage.time += time.Since(start)
+		return nil, err
+	}
+	it.stage.add(1, start)
+	return row, nil
+}
+
+// Close implements the RowIterator.Close().
+func (it *statsIterator) Close() error {
+	return it.it.Close()
+}
+
+// explainStep describes one step of the query plan.
+type explainStep struct {
+	op     string
+	detail string
+	stage  *stage
+}
+
+// explain sets the query result to the query plan. The query must be
+// bound and its plan created before calling this function.
+func (iql *Query) explain() ([]types.Row, error) {
+	var steps []explainStep
+
+	add := func(op, detail string, s *stage) {
+		steps = append(steps, explainStep{
+			op:     op,
+			detail: detail,
+			stage:  s,
+		})
+	}
+	st := iql.stats
+	if st == nil {
+		st = newStats(len(iql.From))
+	}
+
+	for idx, from := range iql.From {
+		if idx == 0 {
+			add("SCAN", iql.describeSource(idx), &st.sources[idx])
+		} else {
+			add("SOURCE", iql.describeSource(idx), &st.sources[idx])
+		}
+		for columnIdx, col := range from.Source.Columns() {
+			index := ColumnIndex{
+				Source: idx,
+				Column: columnIdx,
+				Type:   col.Type,
+			}
+			add("COLUMN", fmt.Sprintf("%s %s %s",
+				iql.columnName(idx, columnIdx), index, col.Type), nil)
+		}
+		for _, pred := range iql.plan.pushed[idx] {
+			add("PUSHDOWN", fmt.Sprintf("%s %s %s",
+				iql.columnName(idx, pred.Column), pred.Op, pred.Value), nil)
+		}
+		if iql.plan.filters[idx] != nil {
+			var s *stage
+			if idx == 0 {
+				s = &st.joined[idx]
+			} else {
+				s = &st.filters[idx]
+			}
+			add("FILTER", iql.plan.filters[idx].String(), s)
+		}
+		if idx > 0 {
+			var parts []string
+			if from.On != nil {
+				parts = append(parts, fmt.Sprintf("ON %s", from.On))
+			}
+			if iql.plan.joins[idx] != nil {
+				parts = append(parts,
+					fmt.Sprintf("WHERE %s", iql.plan.joins[idx]))
+			}
+			add(from.Join.String(), strings.Join(parts, " "),
+				&st.joined[idx])
+		}
+	}
+
+	var where string
+	if iql.plan.residual != nil {
+		where = iql.plan.residual.String()
+	}
+	add("WHERE", where, &st.where)
+
+	if len(iql.GroupBy) > 0 {
+		add("GROUP BY", exprList(iql.GroupBy), &st.group)
+	}
+	if iql.Having != nil {
+		add("HAVING", iql.Having.String(), &st.having)
+	}
+
+	var selects []string
+	for _, sel := range iql.Select {
+		if !sel.IsPublic() {
+			continue
+		}
+		if len(sel.As) > 0 {
+			selects = append(selects,
+				fmt.Sprintf("%s AS %s", sel.Expr, sel.As))
+		} else {
+			selects = append(selects, sel.Expr.String())
+		}
+	}
+	add("SELECT", strings.Join(selects, ", "), &st.selects)
+
+	if len(iql.OrderBy) > 0 {
+		var orders []string
+		for _, order := range iql.OrderBy {
+			if order.Desc {
+				orders = append(orders, fmt.Sprintf("%s DESC", order.Expr))
+			} else {
+				orders = append(orders, order.Expr.String())
+			}
+		}
+		add("ORDER BY", strings.Join(orders, ", "), &st.order)
+	}
+	if iql.LimitFrom > 0 || iql.Limit != math.MaxUint32 {
+		add("LIMIT", fmt.Sprintf("%d, %d", iql.LimitFrom, iql.Limit),
+			&st.limit)
+	}
+
+	// Create result columns and rows.
+	iql.resultColumns = []types.ColumnSelector{
+		explainColumn("Step", types.Int),
+		explainColumn("Operation", types.String),
+		explainColumn("Detail", types.String),
+	}
+	if iql.stats != nil {
+		iql.resultColumns = append(iql.resultColumns,
+			explainColumn("Rows", types.Int),
+			explainColumn("Time", types.String))
+	}
+	iql.result = nil
+	for idx, step := range steps {
+		row := types.Row{
+			types.NewValueColumn(types.IntValue(idx + 1)),
+			types.StringColumn(step.op),
+			types.StringColumn(step.detail),
+		}
+		if iql.stats != nil {
+			if step.stage != nil {
+				row = append(row,
+					types.NewValueColumn(types.IntValue(step.stage.rows)),
+					types.StringColumn(step.stage.time.String()))
+			} else {
+				row = append(row, types.NullColumn{}, types.NullColumn{})
+			}
+		}
+		iql.result = append(iql.result, row)
+	}
+	iql.evaluated = true
+
+	return iql.result, nil
+}
+
+func explainColumn(name string, t types.Type) types.ColumnSelector {
+	return types.ColumnSelector{
+		Name: types.Reference{
+			Column: name,
+		},
+		Type: t,
+	}
+}
+
+// describeSource describes the source idx for EXPLAIN.
+func (iql *Query) describeSource(idx int) string {
+	from := iql.From[idx]
+
+	var parts []string
+	switch src := from.Source.(type) {
+	case *Query:
+		parts = append(parts, "query")
+	case *Table:
+		parts = append(parts, "table")
+	case *SetOperation:
+		parts = append(parts, "set", src.String())
+	case *TableFunction:
+		if iql.lateral(idx) {
+			parts = append(parts, "lateral")
+		}
+		parts = append(parts, "function", src.String())
+	default:
+		parts = append(parts, sourceType(from.Source))
+	}
+	var urls []string
+	for _, url := range from.url {
+		urls = append(urls, fmt.Sprintf("'%s'", url))
+	}
+	if len(urls) > 0 {
+		parts = append(parts, strings.Join(urls, ", "))
+	}
+	if len(from.filter) > 0 {
+		parts = append(parts, fmt.Sprintf("FILTER '%s'", from.filter))
+	}
+	if len(from.format) > 0 {
+		parts = append(parts, fmt.Sprintf("FORMAT '%s'", from.format))
+	}
+	if len(from.As) > 0 {
+		parts = append(parts, fmt.Sprintf("AS %s", from.As))
+	}
+	return strings.Join(parts, " ")
+}
+
+// sourceType returns the type name of the data source
+// implementation.
+func sourceType(source types.Source) string {
+	t := reflect.TypeOf(source)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return strings.ToLower(t.Name())
+}
+
+// columnName returns the qualified name of the column of the source
+// idx.
+func (iql *Query) columnName(idx, column int) string {
+	from := iql.From[idx]
+	col := from.Source.Columns()[column]
+	name := col.As
+	if len(name) == 0 {
+		name = col.Name.Column
+	}
+	if len(from.As) > 0 {
+		return fmt.Sprintf("%s.%s", from.As, name)
+	}
+	return name
+}
+
+func exprList(exprs []Expr) string {
+	var result []string
+	for _, expr := range exprs {
+		result = append(result, expr.String())
+	}
+	return strings.Join(result, ", ")
+}
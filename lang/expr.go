@@ -47,6 +47,7 @@ type Expr interface {
 type Call struct {
 	Name      string
 	Arguments []Expr
+	Distinct  bool
 	Function  *Function
 	Env       *Query
 }
@@ -105,15 +106,42 @@ func (call *Call) Eval(row *Row, rows []*Row) (types.Value, error) {
 		return call.Function.Ret.Eval(row, rows)
 	}
 
+	if call.Distinct {
+		var err error
+		rows, err = call.distinct(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return call.Function.Impl(call.Arguments, row, rows)
 }
 
+// distinct returns the rows that have distinct argument values. The
+// values are distinct if they are not the same by sameValue.
+func (call *Call) distinct(rows []*Row) ([]*Row, error) {
+	seen := make(distinctSet)
+	var result []*Row
+	for _, r := range rows {
+		val, err := call.Arguments[0].Eval(r, nil)
+		if err != nil {
+			return nil, err
+		}
+		if seen.add([]types.Value{val}) {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
 // IsIdempotent implements the Expr.IsIdempotent().
 func (call *Call) IsIdempotent() bool {
 	return call.Function.IsIdempotent(call.Arguments)
 }
 
 func (call *Call) String() string {
+	if call.Distinct {
+		return fmt.Sprintf("%s(DISTINCT %q)", call.Name, call.Arguments)
+	}
 	return fmt.Sprintf("%s(%q)", call.Name, call.Arguments)
 }
 
@@ -581,11 +609,13 @@ func NewReference(name string) (*Reference, error) {
 	}, nil
 }
 
-// ColumnIndex identifies a query Row index.
+// ColumnIndex identifies a query Row index. The ElemType specifies the
+// element type of the Array columns.
 type ColumnIndex struct {
-	Source int
-	Column int
-	Type   types.Type
+	Source   int
+	Column   int
+	Type     types.Type
+	ElemType types.Type
 }
 
 func (idx ColumnIndex) String() string {
@@ -616,17 +646,63 @@ func (ref *Reference) Eval(row *Row, rows []*Row) (types.Value, error) {
 	}
 
 	col := row.Data[ref.index.Source][ref.index.Column]
+	_, ok := col.(types.NullColumn)
+	if ok {
+		return types.Null, nil
+	}
 
 	switch ref.index.Type {
+	case types.Bool, types.Int, types.Float:
+		return widenColumn(col, ref.index.Type), nil
+	case types.Array, types.Record:
+		vc, ok := col.(*types.ValueColumn)
+		if ok && vc.Value().Type() == ref.index.Type {
+			return vc.Value(), nil
+		}
+		return types.StringValue(col.String()), nil
+	default:
+		return types.StringValue(col.String()), nil
+	}
+}
+
+// widenColumn returns the column value as the type t. The streaming
+// sources resolve their column types from the first rows of their
+// input and widen the types for the later rows that do not match
+// them. The values of such rows are widened in the same order as the
+// column types: from bool to int, from int to float, and from float
+// to string.
+func widenColumn(col types.Column, t types.Type) types.Value {
+	vc, ok := col.(*types.ValueColumn)
+	if ok && vc.Value().Type() > t {
+		switch vc.Value().Type() {
+		case types.Int, types.Float:
+			return vc.Value()
+		default:
+			return types.StringValue(col.String())
+		}
+	}
+	switch t {
 	case types.Bool:
-		return col.Bool()
+		val, err := col.Bool()
+		if err == nil {
+			return val
+		}
+		fallthrough
+
 	case types.Int:
-		return col.Int()
+		val, err := col.Int()
+		if err == nil {
+			return val
+		}
+		fallthrough
+
 	case types.Float:
-		return col.Float()
-	default:
-		return types.StringValue(col.String()), nil
+		val, err := col.Float()
+		if err == nil {
+			return val
+		}
 	}
+	return types.StringValue(col.String())
 }
 
 // IsIdempotent implements the Expr.IsIdempotent().
@@ -662,6 +738,21 @@ func (c *Cast) Eval(row *Row, rows []*Row) (types.Value, error) {
 		return nil, err
 	}
 	switch c.Type {
+	case types.Bool, types.Int, types.Float, types.String:
+		return castValue(val, c.Type)
+
+	default:
+		return nil, fmt.Errorf("CAST(%s AS %s) not supported", c.Expr, c.Type)
+	}
+}
+
+// castValue converts the value to the type t. Null values are
+// returned as-is.
+func castValue(val types.Value, t types.Type) (types.Value, error) {
+	if val == types.Null {
+		return val, nil
+	}
+	switch t {
 	case types.Bool:
 		v, err := val.Bool()
 		if err != nil {
@@ -683,11 +774,18 @@ func (c *Cast) Eval(row *Row, rows []*Row) (types.Value, error) {
 		}
 		return types.FloatValue(v), nil
 
+	case types.Date:
+		v, err := val.Date()
+		if err != nil {
+			return nil, err
+		}
+		return types.DateValue(v), nil
+
 	case types.String:
 		return types.StringValue(val.String()), nil
 
 	default:
-		return nil, fmt.Errorf("CAST(%s AS %s) not supported", c.Expr, c.Type)
+		return nil, fmt.Errorf("can't convert %s to %s", val, t)
 	}
 }
 
@@ -8,6 +8,7 @@ package lang
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/markkurossi/iql/types"
 )
@@ -23,6 +24,7 @@ type Function struct {
 	MaxArgs      int
 	FirstBound   int
 	IsIdempotent IsIdempotent
+	Aggregate    bool
 }
 
 func (f *Function) String() string {
@@ -64,6 +66,43 @@ func idempotentArgs(args []Expr) bool {
 	return true
 }
 
+// hasAggregate tests if the expression contains aggregate function
+// calls.
+func hasAggregate(expr Expr) bool {
+	switch e := expr.(type) {
+	case *Call:
+		if e.Function.Aggregate {
+			return true
+		}
+		for _, arg := range e.Arguments {
+			if hasAggregate(arg) {
+				return true
+			}
+		}
+		return e.Function.Ret != nil && hasAggregate(e.Function.Ret)
+	case *Binary:
+		return hasAggregate(e.Left) || hasAggregate(e.Right)
+	case *Unary:
+		return hasAggregate(e.Expr)
+	case *And:
+		return hasAggregate(e.Left) || hasAggregate(e.Right)
+	case *Cast:
+		return hasAggregate(e.Expr)
+	case *Case:
+		if e.Input != nil && hasAggregate(e.Input) {
+			return true
+		}
+		for _, b := range e.Branches {
+			if hasAggregate(b.When) || hasAggregate(b.Then) {
+				return true
+			}
+		}
+		return e.Else != nil && hasAggregate(e.Else)
+	default:
+		return false
+	}
+}
+
 func createFunction(f *Function) error {
 	_, ok := builtInsByName[f.Name]
 	if ok {
@@ -73,6 +112,19 @@ func createFunction(f *Function) error {
 	return nil
 }
 
+// Functions returns all built-in and user-defined functions sorted by
+// their names.
+func Functions() []*Function {
+	var result []*Function
+	for _, f := range builtInsByName {
+		result = append(result, f)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
 func dropFunction(name string, ifExists bool) error {
 	f, ok := builtInsByName[name]
 	if !ok {
@@ -0,0 +1,274 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/markkurossi/iql/types"
+)
+
+// JoinType specifies how a source is joined to the preceding sources
+// of the query.
+type JoinType int
+
+// Join types.
+const (
+	JoinCross JoinType = iota
+	JoinInner
+	JoinLeft
+	JoinRight
+	JoinFull
+)
+
+var joinTypes = map[JoinType]string{
+	JoinCross: "CROSS JOIN",
+	JoinInner: "INNER JOIN",
+	JoinLeft:  "LEFT JOIN",
+	JoinRight: "RIGHT JOIN",
+	JoinFull:  "FULL JOIN",
+}
+
+func (t JoinType) String() string {
+	name, ok := joinTypes[t]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{JoinType %d}", t)
+}
+
+// Outer tests if the join type preserves the rows of the joined
+// source that do not match any rows of the preceding sources.
+func (t JoinType) Outer() bool {
+	return t == JoinRight || t == JoinFull
+}
+
+// hashJoin implements hash join of a source into its preceding
+// sources. The equality conditions of the ON expression are used as
+// hash keys. The full ON expression is evaluated for all rows sharing
+// the hash key so the key computation can be lossy.
+type hashJoin struct {
+	idx     int
+	typ     JoinType
+	on      Expr
+	probe   []Expr
+	build   []Expr
+	columns int
+	rows    []types.Row
+	table   map[string][]int
+	matched []bool
+}
+
+// newHashJoin creates a hash join for the source idx of the
+// query. The rows are the source rows that are joined.
+func newHashJoin(iql *Query, idx int, rows []types.Row) (*hashJoin, error) {
+	from := iql.From[idx]
+	j := &hashJoin{
+		idx: idx,
+		typ: from.Join,
+		on:  from.On,
+	}
+
+	// Split ON into conjuncts and collect equality conditions between
+	// the preceding sources and the joined source.
+	for _, term := range conjuncts(j.on) {
+		bin, ok := term.(*Binary)
+		if !ok || bin.Type != BinEq {
+			continue
+		}
+		left, err := iql.sourcesOf(bin.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := iql.sourcesOf(bin.Right)
+		if err != nil {
+			return nil, err
+		}
+		if sourcesBefore(left, idx) && sourcesEqual(right, idx) {
+			j.probe = append(j.probe, bin.Left)
+			j.build = append(j.build, bin.Right)
+		} else if sourcesBefore(right, idx) && sourcesEqual(left, idx) {
+			j.probe = append(j.probe, bin.Right)
+			j.build = append(j.build, bin.Left)
+		}
+	}
+
+	j.rows = rows
+	j.columns = len(from.Source.Columns())
+	j.table = make(map[string][]int)
+	if j.typ.Outer() {
+		j.matched = make([]bool, len(rows))
+	}
+
+	row := &Row{
+		Data: make([]types.Row, idx+1),
+	}
+	for rowIdx, r := range rows {
+		row.Data[idx] = r
+		key, err := hashKey(j.build, row)
+		if err != nil {
+			return nil, err
+		}
+		j.table[key] = append(j.table[key], rowIdx)
+	}
+
+	return j, nil
+}
+
+// join joins the source rows to the argument data rows. The function
+// calls the callback for each joined row.
+func (j *hashJoin) join(data []types.Row,
+	cb func(data []types.Row) error) error {
+
+	row := &Row{
+		Data: append(data[:j.idx:j.idx], nil),
+	}
+	key, err := hashKey(j.probe, row)
+	if err != nil {
+		return err
+	}
+	var match bool
+	for _, rowIdx := range j.table[key] {
+		row.Data[j.idx] = j.rows[rowIdx]
+		val, err := j.on.Eval(row, nil)
+		if err != nil {
+			return err
+		}
+		ok, err := val.Bool()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		match = true
+		if j.matched != nil {
+			j.matched[rowIdx] = true
+		}
+		joined := make([]types.Row, j.idx+1)
+		copy(joined, row.Data)
+		if err := cb(joined); err != nil {
+			return err
+		}
+	}
+	if !match && (j.typ == JoinLeft || j.typ == JoinFull) {
+		return cb(append(data[:j.idx:j.idx], nullRow(j.columns)))
+	}
+	return nil
+}
+
+// unmatched calls the callback for all source rows that did not
+// match any rows of the preceding sources. The preceding sources are
+// null-extended.
+func (j *hashJoin) unmatched(iql *Query,
+	cb func(data []types.Row) error) error {
+
+	for rowIdx, r := range j.rows {
+		if j.matched[rowIdx] {
+			continue
+		}
+		var data []types.Row
+		for i := 0; i < j.idx; i++ {
+			data = append(data,
+				nullRow(len(iql.From[i].Source.Columns())))
+		}
+		if err := cb(append(data, r)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nullRow creates a row with count null columns.
+func nullRow(count int) types.Row {
+	row := make(types.Row, count)
+	for i := range row {
+		row[i] = types.NullColumn{}
+	}
+	return row
+}
+
+// hashKey computes the hash key for the key expressions.
+func hashKey(keys []Expr, row *Row) (string, error) {
+	var sb strings.Builder
+	for _, key := range keys {
+		val, err := key.Eval(row, nil)
+		if err != nil {
+			return "", err
+		}
+		writeKey(&sb, val)
+	}
+	return sb.String(), nil
+}
+
+// writeKey writes the key of the value to the builder. The values
+// are normalized so that values that are equal by the '=' operator
+// have the same keys.
+func writeKey(sb *strings.Builder, val types.Value) {
+	if _, ok := val.(types.NullValue); ok {
+		sb.WriteString("n:")
+		return
+	}
+	str := val.String()
+	if val.Type() == types.Float {
+		// The formatted values are keyed by their unformatted values.
+		f, err := val.Float()
+		if err == nil {
+			if f == float64(int64(f)) {
+				str = types.IntValue(int64(f)).String()
+			} else {
+				str = types.FloatValue(f).String()
+			}
+		}
+	}
+	sb.WriteString(fmt.Sprintf("%d:%s", len(str), str))
+}
+
+// conjuncts splits the expression into its AND terms.
+func conjuncts(expr Expr) []Expr {
+	if expr == nil {
+		return nil
+	}
+	and, ok := expr.(*And)
+	if !ok {
+		return []Expr{expr}
+	}
+	return append(conjuncts(and.Left), conjuncts(and.Right)...)
+}
+
+// sourcesOf returns the indices of the sources that the expression
+// references.
+func (iql *Query) sourcesOf(expr Expr) (map[int]bool, error) {
+	result := make(map[int]bool)
+	for _, ref := range expr.References() {
+		r, err := iql.resolveName(ref)
+		if err != nil {
+			return nil, err
+		}
+		if r.binding == nil {
+			result[r.index.Source] = true
+		}
+	}
+	return result, nil
+}
+
+func sourcesBefore(sources map[int]bool, idx int) bool {
+	if len(sources) == 0 {
+		return false
+	}
+	for source := range sources {
+		if source >= idx {
+			return false
+		}
+	}
+	return true
+}
+
+func sourcesEqual(sources map[int]bool, idx int) bool {
+	return len(sources) == 1 && sources[idx]
+}
@@ -11,6 +11,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode"
@@ -46,6 +47,7 @@ const (
 	TSymFrom
 	TSymWhere
 	TSymGroup
+	TSymHaving
 	TSymOrder
 	TSymAs
 	TSymBy
@@ -75,6 +77,38 @@ const (
 	TSymIf
 	TSymExists
 	TSymLimit
+	TSymJoin
+	TSymInner
+	TSymLeft
+	TSymRight
+	TSymFull
+	TSymOuter
+	TSymCross
+	TSymOn
+	TSymTable
+	TSymInsert
+	TSymValues
+	TSymUpdate
+	TSymDelete
+	TSymExplain
+	TSymAnalyze
+	TSymFormat
+	TSymOver
+	TSymPartition
+	TSymRows
+	TSymBetween
+	TSymUnbounded
+	TSymPreceding
+	TSymFollowing
+	TSymCurrent
+	TSymRow
+	TSymWith
+	TSymRecursive
+	TSymUnion
+	TSymAll
+	TSymIntersect
+	TSymExcept
+	TSymDistinct
 	TAnd
 	TOr
 	TNEq
@@ -94,6 +128,7 @@ var tokenTypes = map[TokenType]string{
 	TSymFrom:     "FROM",
 	TSymWhere:    "WHERE",
 	TSymGroup:    "GROUP",
+	TSymHaving:   "HAVING",
 	TSymOrder:    "ORDER",
 	TSymAs:       "AS",
 	TSymBy:       "BY",
@@ -123,12 +158,50 @@ var tokenTypes = map[TokenType]string{
 	TSymIf:       "IF",
 	TSymExists:   "EXISTS",
 	TSymLimit:    "LIMIT",
+	TSymJoin:     "JOIN",
+	TSymInner:    "INNER",
+	TSymLeft:     "LEFT",
+	TSymRight:    "RIGHT",
+	TSymFull:     "FULL",
+	TSymOuter:    "OUTER",
+	TSymCross:    "CROSS",
+	TSymOn:       "ON",
+	TSymTable:    "TABLE",
+	TSymInsert:   "INSERT",
+	TSymValues:   "VALUES",
+	TSymUpdate:   "UPDATE",
+	TSymDelete:   "DELETE",
+	TSymExplain:  "EXPLAIN",
+	TSymAnalyze:  "ANALYZE",
+	TSymFormat:   "FORMAT",
 	TAnd:         "AND",
 	TOr:          "OR",
 	TNEq:         "<>",
 	TNMatch:      "!~",
 	TLe:          "<=",
 	TGe:          ">=",
+
+	// Window clauses.
+	TSymOver:      "OVER",
+	TSymPartition: "PARTITION",
+	TSymRows:      "ROWS",
+	TSymBetween:   "BETWEEN",
+	TSymUnbounded: "UNBOUNDED",
+	TSymPreceding: "PRECEDING",
+	TSymFollowing: "FOLLOWING",
+	TSymCurrent:   "CURRENT",
+	TSymRow:       "ROW",
+
+	// Common table expressions and compound queries.
+	TSymWith:      "WITH",
+	TSymRecursive: "RECURSIVE",
+	TSymUnion:     "UNION",
+	TSymAll:       "ALL",
+	TSymIntersect: "INTERSECT",
+	TSymExcept:    "EXCEPT",
+
+	// Duplicate elimination.
+	TSymDistinct: "DISTINCT",
 }
 
 func (t TokenType) String() string {
@@ -149,6 +222,7 @@ var symbols = map[string]TokenType{
 	"FROM":     TSymFrom,
 	"WHERE":    TSymWhere,
 	"GROUP":    TSymGroup,
+	"HAVING":   TSymHaving,
 	"ORDER":    TSymOrder,
 	"AS":       TSymAs,
 	"BY":       TSymBy,
@@ -178,8 +252,56 @@ var symbols = map[string]TokenType{
 	"IF":       TSymIf,
 	"EXISTS":   TSymExists,
 	"LIMIT":    TSymLimit,
+	"JOIN":     TSymJoin,
+	"INNER":    TSymInner,
+	"LEFT":     TSymLeft,
+	"RIGHT":    TSymRight,
+	"FULL":     TSymFull,
+	"OUTER":    TSymOuter,
+	"CROSS":    TSymCross,
+	"ON":       TSymOn,
+	"TABLE":    TSymTable,
+	"INSERT":   TSymInsert,
+	"VALUES":   TSymValues,
+	"UPDATE":   TSymUpdate,
+	"DELETE":   TSymDelete,
+	"EXPLAIN":  TSymExplain,
+	"ANALYZE":  TSymAnalyze,
+	"FORMAT":   TSymFormat,
 	"AND":      TAnd,
 	"OR":       TOr,
+
+	// Window clauses.
+	"OVER":      TSymOver,
+	"PARTITION": TSymPartition,
+	"ROWS":      TSymRows,
+	"BETWEEN":   TSymBetween,
+	"UNBOUNDED": TSymUnbounded,
+	"PRECEDING": TSymPreceding,
+	"FOLLOWING": TSymFollowing,
+	"CURRENT":   TSymCurrent,
+	"ROW":       TSymRow,
+
+	// Common table expressions and compound queries.
+	"WITH":      TSymWith,
+	"RECURSIVE": TSymRecursive,
+	"UNION":     TSymUnion,
+	"ALL":       TSymAll,
+	"INTERSECT": TSymIntersect,
+	"EXCEPT":    TSymExcept,
+
+	// Duplicate elimination.
+	"DISTINCT": TSymDistinct,
+}
+
+// Keywords returns the language keywords in sorted order.
+func Keywords() []string {
+	var result []string
+	for keyword := range symbols {
+		result = append(result, keyword)
+	}
+	sort.Strings(result)
+	return result
 }
 
 // Token implements an input token.
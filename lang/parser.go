@@ -35,6 +35,7 @@ type Parser struct {
 	lexer   *lexer
 	nesting int
 	global  *Scope
+	with    *Scope
 	output  io.Writer
 }
 
@@ -126,6 +127,12 @@ func (p *Parser) Parse() (*Query, error) {
 		case TSymSelect:
 			return p.parseSelect()
 
+		case TSymWith:
+			return p.parseWith()
+
+		case TSymExplain:
+			return p.parseExplain()
+
 		case TSymCreate:
 			err = p.parseCreate()
 			if err != nil {
@@ -138,6 +145,24 @@ func (p *Parser) Parse() (*Query, error) {
 				return nil, err
 			}
 
+		case TSymInsert:
+			err = p.parseInsert()
+			if err != nil {
+				return nil, err
+			}
+
+		case TSymUpdate:
+			err = p.parseUpdate()
+			if err != nil {
+				return nil, err
+			}
+
+		case TSymDelete:
+			err = p.parseDelete()
+			if err != nil {
+				return nil, err
+			}
+
 		default:
 			return nil, p.errUnexpected(t)
 		}
@@ -250,9 +275,168 @@ func (p *Parser) parsePrint() error {
 	return nil
 }
 
+func (p *Parser) parseExplain() (*Query, error) {
+	explain := ExplainPlan
+	t, err := p.optional(TSymAnalyze)
+	if err != nil {
+		return nil, err
+	}
+	if t != nil {
+		explain = ExplainAnalyze
+	}
+	_, err = p.need(TSymSelect)
+	if err != nil {
+		return nil, err
+	}
+	q, err := p.parseSelect()
+	if err != nil {
+		return nil, err
+	}
+	q.Explain = explain
+	return q, nil
+}
+
 func (p *Parser) parseSelect() (*Query, error) {
+	q, err := p.parseCompound()
+	if err != nil {
+		return nil, err
+	}
+
+	// Terminator.
+	if p.nesting == 1 {
+		_, err = p.optional(';')
+	} else {
+		_, err = p.need(')')
+	}
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// parseCompound parses the SELECT query and the set operations that
+// combine it with other SELECT queries. The INTERSECT operation binds
+// more tightly than UNION and EXCEPT. The ORDER BY and LIMIT clauses
+// of the last SELECT query apply to the result of the set operations.
+func (p *Parser) parseCompound() (*Query, error) {
+	var queries []*Query
+
+	// parseOperand parses the right operand of the set operation t.
+	parseOperand := func(t *Token) (bool, *Query, error) {
+		last := queries[len(queries)-1]
+		if len(last.OrderBy) > 0 || last.LimitFrom > 0 ||
+			last.Limit != math.MaxUint32 {
+			return false, nil, p.errf(t.From, "ORDER BY and LIMIT are "+
+				"allowed only after the last SELECT of %s", t.Type)
+		}
+		all, err := p.optional(TSymAll)
+		if err != nil {
+			return false, nil, err
+		}
+		_, err = p.need(TSymSelect)
+		if err != nil {
+			return false, nil, err
+		}
+		q, err := p.parseSelectQuery()
+		if err != nil {
+			return false, nil, err
+		}
+		queries = append(queries, q)
+		return all != nil, q, nil
+	}
+	parseIntersect := func(q *Query) (types.Source, error) {
+		var source types.Source = q
+		for {
+			t, err := p.optional(TSymIntersect)
+			if err != nil {
+				return nil, err
+			}
+			if t == nil {
+				return source, nil
+			}
+			all, right, err := parseOperand(t)
+			if err != nil {
+				return nil, err
+			}
+			source = &SetOperation{
+				Op:    SetIntersect,
+				All:   all,
+				Left:  source,
+				Right: right,
+			}
+		}
+	}
+
+	q, err := p.parseSelectQuery()
+	if err != nil {
+		return nil, err
+	}
+	queries = append(queries, q)
+	source, err := parseIntersect(q)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, err := p.get()
+		if err != nil {
+			return nil, err
+		}
+		if t.Type != TSymUnion && t.Type != TSymExcept {
+			p.lexer.unget(t)
+			break
+		}
+		op := SetUnion
+		if t.Type == TSymExcept {
+			op = SetExcept
+		}
+		all, q, err := parseOperand(t)
+		if err != nil {
+			return nil, err
+		}
+		right, err := parseIntersect(q)
+		if err != nil {
+			return nil, err
+		}
+		source = &SetOperation{
+			Op:    op,
+			All:   all,
+			Left:  source,
+			Right: right,
+		}
+	}
+	if len(queries) == 1 {
+		return queries[0], nil
+	}
+
+	// Select the result of the set operations with the ORDER BY and
+	// LIMIT clauses of the last query.
+	last := queries[len(queries)-1]
+	q = NewQuery(p.global)
+	q.From = []SourceSelector{
+		{
+			Source: source,
+		},
+	}
+	q.OrderBy = last.OrderBy
+	q.LimitFrom = last.LimitFrom
+	q.Limit = last.Limit
+	last.OrderBy = nil
+	last.LimitFrom = 0
+	last.Limit = math.MaxUint32
+
+	return q, nil
+}
+
+// parseSelectQuery parses the SELECT query without its terminator.
+func (p *Parser) parseSelectQuery() (*Query, error) {
 	q := NewQuery(p.global)
 
+	distinct, err := p.optional(TSymDistinct)
+	if err != nil {
+		return nil, err
+	}
+	q.Distinct = distinct != nil
+
 	// Columns. The columns list is empty for "SELECT *" queries.
 	t, err := p.get()
 	if err != nil {
@@ -311,21 +495,35 @@ func (p *Parser) parseSelect() (*Query, error) {
 		return nil, err
 	}
 	if t.Type == TSymFrom {
+		source, err := p.parseSource(q)
+		if err != nil {
+			return nil, err
+		}
+		q.From = append(q.From, *source)
 		for {
-			source, err := p.parseSource(q)
+			join, ok, err := p.parseJoinType()
 			if err != nil {
 				return nil, err
 			}
-			q.From = append(q.From, *source)
-
-			t, err := p.get()
+			if !ok {
+				break
+			}
+			source, err := p.parseSource(q)
 			if err != nil {
 				return nil, err
 			}
-			if t.Type != ',' {
-				p.lexer.unget(t)
-				break
+			source.Join = join
+			if join != JoinCross {
+				_, err = p.need(TSymOn)
+				if err != nil {
+					return nil, err
+				}
+				source.On, err = p.parseExpr()
+				if err != nil {
+					return nil, err
+				}
 			}
+			q.From = append(q.From, *source)
 		}
 	} else {
 		p.lexer.unget(t)
@@ -359,6 +557,20 @@ func (p *Parser) parseSelect() (*Query, error) {
 		p.lexer.unget(t)
 	}
 
+	// HAVING
+	t, err = p.get()
+	if err != nil {
+		return nil, err
+	}
+	if t.Type == TSymHaving {
+		q.Having, err = p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		p.lexer.unget(t)
+	}
+
 	// ORDER BY
 	t, err = p.get()
 	if err != nil {
@@ -387,16 +599,170 @@ func (p *Parser) parseSelect() (*Query, error) {
 		p.lexer.unget(t)
 	}
 
-	// Terminator.
-	if p.nesting == 1 {
-		_, err = p.optional(';')
-	} else {
-		_, err = p.need(')')
+	// Create data sources. This is done after the whole query is
+	// parsed so that the sources know all columns the query uses.
+	for idx, from := range q.From {
+		if from.Source != nil {
+			continue
+		}
+		options, err := DataOptions(q.Global)
+		if err != nil {
+			return nil, err
+		}
+		if len(from.format) > 0 {
+			options.Format, err = data.ParseFormat(from.format)
+			if err != nil {
+				return nil, err
+			}
+		}
+		q.From[idx].Source, err = data.NewWithOptions(from.url, from.filter,
+			columnsFor(q, from.As), options)
+		if err != nil {
+			return nil, err
+		}
 	}
+	return q, nil
+}
+
+// parseWith parses the common table expressions of the WITH clause
+// and the query that uses them. The table expressions are visible
+// only within the query.
+func (p *Parser) parseWith() (*Query, error) {
+	recursive, err := p.optional(TSymRecursive)
 	if err != nil {
 		return nil, err
 	}
-	return q, nil
+	outer := p.with
+	p.with = NewScope(outer)
+	defer func() {
+		p.with = outer
+	}()
+
+	for {
+		err = p.parseCTE(recursive != nil)
+		if err != nil {
+			return nil, err
+		}
+		t, err := p.get()
+		if err != nil {
+			return nil, err
+		}
+		if t.Type != ',' {
+			p.lexer.unget(t)
+			break
+		}
+	}
+
+	_, err = p.need(TSymSelect)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseSelect()
+}
+
+// parseCTE parses a common table expression and declares it in the
+// WITH scope. If recursive is true, the table expression can be a
+// UNION of a query and a recursive query that references the table
+// expression.
+func (p *Parser) parseCTE(recursive bool) error {
+	t, err := p.need(TIdentifier)
+	if err != nil {
+		return err
+	}
+	cte := &CTE{
+		Name: t.StrVal,
+	}
+	paren, err := p.optional('(')
+	if err != nil {
+		return err
+	}
+	if paren != nil {
+		for {
+			n, err := p.need(TIdentifier)
+			if err != nil {
+				return err
+			}
+			cte.ColumnNames = append(cte.ColumnNames, n.StrVal)
+			n, err = p.get()
+			if err != nil {
+				return err
+			}
+			if n.Type == ')' {
+				break
+			}
+			if n.Type != ',' {
+				return p.errUnexpected(n)
+			}
+		}
+	}
+	err = p.with.Declare(cte.Name, types.Table, nil)
+	if err != nil {
+		return p.errf(t.From, "%s", err)
+	}
+
+	_, err = p.need(TSymAs)
+	if err != nil {
+		return err
+	}
+	_, err = p.need('(')
+	if err != nil {
+		return err
+	}
+	_, err = p.need(TSymSelect)
+	if err != nil {
+		return err
+	}
+
+	// The recursive query references the work table of the table
+	// expression.
+	if recursive {
+		cte.work = &Table{
+			Name: cte.Name,
+		}
+		err = p.with.Set(cte.Name, types.TableValue{
+			Source: cte.work,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if recursive {
+		cte.Query, err = p.parseSelectQuery()
+	} else {
+		cte.Query, err = p.parseCompound()
+	}
+	if err != nil {
+		return err
+	}
+	if recursive {
+		union, err := p.optional(TSymUnion)
+		if err != nil {
+			return err
+		}
+		if union != nil {
+			all, err := p.optional(TSymAll)
+			if err != nil {
+				return err
+			}
+			cte.All = all != nil
+			_, err = p.need(TSymSelect)
+			if err != nil {
+				return err
+			}
+			cte.Recursive, err = p.parseSelectQuery()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err = p.need(')')
+	if err != nil {
+		return err
+	}
+
+	return p.with.Set(cte.Name, types.TableValue{
+		Source: cte,
+	})
 }
 
 func (p *Parser) parseColumn() (*ColumnSelector, error) {
@@ -436,6 +802,14 @@ func (p *Parser) parseSource(q *Query) (*SourceSelector, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Identifiers followed by '(' are table function calls.
+	var call *Token
+	if t.Type == TIdentifier {
+		call, err = p.optional('(')
+		if err != nil {
+			return nil, err
+		}
+	}
 	if t.Type == '(' {
 		source, err = p.Parse()
 		if err != nil {
@@ -445,12 +819,21 @@ func (p *Parser) parseSource(q *Query) (*SourceSelector, error) {
 		if err != nil {
 			return nil, err
 		}
+	} else if call != nil {
+		source, err = p.parseTableFunction(t)
+		if err != nil {
+			return nil, err
+		}
+		as, err = p.parseKeyword(TSymAs)
+		if err != nil {
+			return nil, err
+		}
 	} else {
 		var url []string
 
 		switch t.Type {
 		case TIdentifier:
-			b := q.Global.Get(t.StrVal)
+			b := p.lookupSource(q, t.StrVal)
 			if b == nil {
 				return nil, p.errf(t.From, "unknown identifier '%s'", t.StrVal)
 			}
@@ -495,6 +878,16 @@ func (p *Parser) parseSource(q *Query) (*SourceSelector, error) {
 		if err != nil {
 			return nil, err
 		}
+		format, err := p.parseKeyword(TSymFormat)
+		if err != nil {
+			return nil, err
+		}
+		if len(format) > 0 {
+			_, err = data.ParseFormat(format)
+			if err != nil {
+				return nil, p.errf(t.From, "%s", err)
+			}
+		}
 		alias, err := p.parseKeyword(TSymAs)
 		if err != nil {
 			return nil, err
@@ -504,10 +897,12 @@ func (p *Parser) parseSource(q *Query) (*SourceSelector, error) {
 		}
 
 		if source == nil {
-			source, err = data.New(url, filter, columnsFor(q.Select, as))
-			if err != nil {
-				return nil, err
-			}
+			return &SourceSelector{
+				As:     as,
+				url:    url,
+				filter: filter,
+				format: format,
+			}, nil
 		}
 	}
 
@@ -517,26 +912,128 @@ func (p *Parser) parseSource(q *Query) (*SourceSelector, error) {
 	}, nil
 }
 
-func columnsFor(columns []ColumnSelector,
-	source string) []types.ColumnSelector {
+// parseTableFunction parses the arguments of the table-valued
+// function name.
+func (p *Parser) parseTableFunction(name *Token) (*TableFunction, error) {
+	args, err := p.parseArguments()
+	if err != nil {
+		return nil, err
+	}
+	tf := &TableFunction{
+		Name:      strings.ToUpper(name.StrVal),
+		Arguments: args,
+	}
+	tf.Function = tableFunction(tf.Name)
+	if tf.Function == nil {
+		return nil, p.errf(name.From, "undefined table function: %s", tf.Name)
+	}
+	return tf, nil
+}
+
+// lookupSource returns the binding of the source name. The common
+// table expressions of the WITH clauses shadow the global variables.
+func (p *Parser) lookupSource(q *Query, name string) *Binding {
+	if p.with != nil {
+		b := p.with.Get(name)
+		if b != nil {
+			return b
+		}
+	}
+	return q.Global.Get(name)
+}
+
+// parseJoinType parses the separator between two sources of the FROM
+// clause. The function returns false if the input does not start
+// with a join.
+func (p *Parser) parseJoinType() (JoinType, bool, error) {
+	t, err := p.get()
+	if err != nil {
+		return JoinCross, false, err
+	}
+	var join JoinType
+	switch t.Type {
+	case ',':
+		return JoinCross, true, nil
+
+	case TSymJoin:
+		return JoinInner, true, nil
+
+	case TSymCross:
+		join = JoinCross
+
+	case TSymInner:
+		join = JoinInner
+
+	case TSymLeft, TSymRight, TSymFull:
+		switch t.Type {
+		case TSymLeft:
+			join = JoinLeft
+		case TSymRight:
+			join = JoinRight
+		default:
+			join = JoinFull
+		}
+		_, err = p.optional(TSymOuter)
+		if err != nil {
+			return JoinCross, false, err
+		}
+
+	default:
+		p.lexer.unget(t)
+		return JoinCross, false, nil
+	}
+	_, err = p.need(TSymJoin)
+	if err != nil {
+		return JoinCross, false, err
+	}
+	return join, true, nil
+}
 
+// columnsFor returns the columns the query references from the named
+// source. The function returns an empty list for SELECT * queries so
+// that the source returns all its columns.
+func columnsFor(q *Query, source string) []types.ColumnSelector {
 	var result []types.ColumnSelector
 
+	if len(q.Select) == 0 {
+		return result
+	}
+
+	var exprs []Expr
+	for _, col := range q.Select {
+		exprs = append(exprs, col.Expr)
+	}
+	for _, from := range q.From {
+		if from.On != nil {
+			exprs = append(exprs, from.On)
+		}
+		if tf, ok := from.Source.(*TableFunction); ok {
+			exprs = append(exprs, tf.Arguments...)
+		}
+	}
+	if q.Where != nil {
+		exprs = append(exprs, q.Where)
+	}
+	exprs = append(exprs, q.GroupBy...)
+	if q.Having != nil {
+		exprs = append(exprs, q.Having)
+	}
+	for _, order := range q.OrderBy {
+		exprs = append(exprs, order.Expr)
+	}
+
 	// Collect all referenced columns for the source.
 	seen := make(map[string]bool)
-	for _, col := range columns {
-		var filtered []types.Reference
-
-		for _, ref := range col.Expr.References() {
-			if ref.Source == source {
-				if !seen[ref.Column] {
-					filtered = append(filtered, ref)
-					seen[ref.Column] = true
-				}
+	for _, expr := range exprs {
+		for _, ref := range expr.References() {
+			if ref.Source != source || seen[ref.Column] {
+				continue
 			}
-		}
-
-		for _, ref := range filtered {
+			// Unqualified variable references are not columns.
+			if len(ref.Source) == 0 && q.Global.Get(ref.Column) != nil {
+				continue
+			}
+			seen[ref.Column] = true
 			result = append(result, types.ColumnSelector{
 				Name: ref,
 			})
@@ -562,115 +1059,413 @@ func (p *Parser) parseKeyword(keyword TokenType) (string, error) {
 	switch t.Type {
 	case TIdentifier, TString:
 	default:
-		return "", p.errUnexpected(t)
+		return "", p.errUnexpected(t)
+	}
+	return t.StrVal, nil
+}
+
+func (p *Parser) parseGroupBy() ([]Expr, error) {
+	_, err := p.need(TSymBy)
+	if err != nil {
+		return nil, err
+	}
+	var result []Expr
+	for {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, expr)
+
+		t, err := p.get()
+		if err != nil {
+			return nil, err
+		}
+		if t.Type != ',' {
+			p.lexer.unget(t)
+			return result, nil
+		}
+	}
+}
+
+func (p *Parser) parseOrderBy() ([]Order, error) {
+	_, err := p.need(TSymBy)
+	if err != nil {
+		return nil, err
+	}
+	var result []Order
+	for {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		t, err := p.get()
+		if err != nil {
+			return nil, err
+		}
+		var desc bool
+		if t.Type == TSymAsc {
+			desc = false
+		} else if t.Type == TSymDesc {
+			desc = true
+		} else {
+			p.lexer.unget(t)
+		}
+		result = append(result, Order{
+			Expr: expr,
+			Desc: desc,
+		})
+
+		t, err = p.get()
+		if err != nil {
+			return nil, err
+		}
+		if t.Type != ',' {
+			p.lexer.unget(t)
+			return result, nil
+		}
+	}
+}
+
+func (p *Parser) parseLimit() (uint32, uint32, error) {
+	// LIMIT from [, to]
+	lim1, err := p.need(TInt)
+	if err != nil {
+		return 0, 0, err
+	}
+	i1 := Int64ToInt(lim1.IntVal)
+	if i1 < 0 {
+		return 0, 0, fmt.Errorf("negative limit: %d", i1)
+	}
+	t, err := p.get()
+	if err != nil {
+		return 0, 0, err
+	}
+	if t.Type != ',' {
+		p.lexer.unget(t)
+		return 0, uint32(i1), nil
+	}
+	lim2, err := p.need(TInt)
+	if err != nil {
+		return 0, 0, err
+	}
+	i2 := Int64ToInt(lim2.IntVal)
+	if i2 < 0 {
+		return 0, 0, fmt.Errorf("negative limit: %d", i2)
+	}
+	return uint32(i1), uint32(i2), nil
+}
+
+func (p *Parser) parseCreate() error {
+	t, err := p.get()
+	if err != nil {
+		return err
+	}
+	switch t.Type {
+	case TSymFunction:
+		return p.parseCreateFunction()
+
+	case TSymTable:
+		return p.parseCreateTable()
+
+	default:
+		return p.errUnexpected(t)
+	}
+}
+
+func (p *Parser) parseCreateTable() error {
+	t, err := p.need(TIdentifier)
+	if err != nil {
+		return err
+	}
+	name := t.StrVal
+	var columns []types.ColumnSelector
+
+	_, err = p.need('(')
+	if err != nil {
+		return err
+	}
+	for {
+		t, err = p.need(TIdentifier)
+		if err != nil {
+			return err
+		}
+		colName := t.StrVal
+
+		colType, err := p.parseType()
+		if err != nil {
+			return err
+		}
+		columns = append(columns, types.ColumnSelector{
+			Name: types.Reference{
+				Column: colName,
+			},
+			Type: colType,
+		})
+
+		t, err = p.get()
+		if err != nil {
+			return err
+		}
+		if t.Type == ')' {
+			break
+		} else if t.Type != ',' {
+			return p.errUnexpected(t)
+		}
+	}
+	_, err = p.optional(';')
+	if err != nil {
+		return err
+	}
+
+	table, err := NewTable(name, columns)
+	if err != nil {
+		return err
+	}
+	err = p.global.Declare(name, types.Table, nil)
+	if err != nil {
+		return err
+	}
+	return p.global.Set(name, types.TableValue{
+		Source: table,
+	})
+}
+
+// parseTable parses a table name and returns the table.
+func (p *Parser) parseTable() (*Table, error) {
+	t, err := p.need(TIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	b := p.global.Get(t.StrVal)
+	if b == nil {
+		return nil, p.errf(t.From, "unknown identifier '%s'", t.StrVal)
+	}
+	tv, ok := b.Value.(types.TableValue)
+	if ok {
+		table, ok := tv.Source.(*Table)
+		if ok {
+			return table, nil
+		}
+	}
+	return nil, p.errf(t.From, "identifier '%s' is not a table", t.StrVal)
+}
+
+func (p *Parser) parseInsert() error {
+	_, err := p.need(TSymInto)
+	if err != nil {
+		return err
+	}
+	table, err := p.parseTable()
+	if err != nil {
+		return err
+	}
+
+	// Optional column list.
+	var columns []string
+	t, err := p.get()
+	if err != nil {
+		return err
+	}
+	if t.Type == '(' {
+		for {
+			t, err = p.need(TIdentifier)
+			if err != nil {
+				return err
+			}
+			columns = append(columns, t.StrVal)
+
+			t, err = p.get()
+			if err != nil {
+				return err
+			}
+			if t.Type == ')' {
+				break
+			} else if t.Type != ',' {
+				return p.errUnexpected(t)
+			}
+		}
+	} else {
+		p.lexer.unget(t)
+	}
+
+	t, err = p.get()
+	if err != nil {
+		return err
+	}
+	switch t.Type {
+	case TSymValues:
+		return p.parseInsertValues(table, columns)
+
+	case TSymSelect:
+		q, err := p.parseSelect()
+		if err != nil {
+			return err
+		}
+		rows, err := q.Get()
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			var values []types.Value
+			for _, col := range row {
+				values = append(values, columnValue(col))
+			}
+			if err := table.Insert(columns, values); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return p.errUnexpected(t)
 	}
-	return t.StrVal, nil
 }
 
-func (p *Parser) parseGroupBy() ([]Expr, error) {
-	_, err := p.need(TSymBy)
-	if err != nil {
-		return nil, err
-	}
-	var result []Expr
+func (p *Parser) parseInsertValues(table *Table, columns []string) error {
+	q := NewQuery(p.global)
 	for {
-		expr, err := p.parseExpr()
+		_, err := p.need('(')
 		if err != nil {
-			return nil, err
+			return err
+		}
+		var values []types.Value
+		for {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return err
+			}
+			if err := expr.Bind(q); err != nil {
+				return err
+			}
+			val, err := expr.Eval(nil, nil)
+			if err != nil {
+				return err
+			}
+			values = append(values, val)
+
+			t, err := p.get()
+			if err != nil {
+				return err
+			}
+			if t.Type == ')' {
+				break
+			} else if t.Type != ',' {
+				return p.errUnexpected(t)
+			}
+		}
+		if err := table.Insert(columns, values); err != nil {
+			return err
 		}
-		result = append(result, expr)
 
 		t, err := p.get()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if t.Type != ',' {
 			p.lexer.unget(t)
-			return result, nil
+			break
 		}
 	}
+	_, err := p.optional(';')
+	return err
 }
 
-func (p *Parser) parseOrderBy() ([]Order, error) {
-	_, err := p.need(TSymBy)
+// columnValue returns the value of the query result column.
+func columnValue(col types.Column) types.Value {
+	switch c := col.(type) {
+	case types.NullColumn:
+		return types.Null
+	case *types.ValueColumn:
+		return c.Value()
+	default:
+		return types.StringValue(col.String())
+	}
+}
+
+func (p *Parser) parseUpdate() error {
+	table, err := p.parseTable()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var result []Order
+	_, err = p.need(TSymSet)
+	if err != nil {
+		return err
+	}
+	var set []Assignment
 	for {
-		expr, err := p.parseExpr()
+		t, err := p.need(TIdentifier)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		t, err := p.get()
+		_, err = p.need('=')
 		if err != nil {
-			return nil, err
+			return err
 		}
-		var desc bool
-		if t.Type == TSymAsc {
-			desc = false
-		} else if t.Type == TSymDesc {
-			desc = true
-		} else {
-			p.lexer.unget(t)
+		expr, err := p.parseExpr()
+		if err != nil {
+			return err
 		}
-		result = append(result, Order{
-			Expr: expr,
-			Desc: desc,
+		set = append(set, Assignment{
+			Column: t.StrVal,
+			Expr:   expr,
 		})
 
 		t, err = p.get()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if t.Type != ',' {
 			p.lexer.unget(t)
-			return result, nil
+			break
 		}
 	}
+	where, err := p.parseWhere()
+	if err != nil {
+		return err
+	}
+	_, err = table.Update(p.global, set, where)
+	return err
 }
 
-func (p *Parser) parseLimit() (uint32, uint32, error) {
-	// LIMIT from [, to]
-	lim1, err := p.need(TInt)
+func (p *Parser) parseDelete() error {
+	_, err := p.need(TSymFrom)
 	if err != nil {
-		return 0, 0, err
-	}
-	i1 := Int64ToInt(lim1.IntVal)
-	if i1 < 0 {
-		return 0, 0, fmt.Errorf("negative limit: %d", i1)
+		return err
 	}
-	t, err := p.get()
+	table, err := p.parseTable()
 	if err != nil {
-		return 0, 0, err
-	}
-	if t.Type != ',' {
-		p.lexer.unget(t)
-		return 0, uint32(i1), nil
+		return err
 	}
-	lim2, err := p.need(TInt)
+	where, err := p.parseWhere()
 	if err != nil {
-		return 0, 0, err
-	}
-	i2 := Int64ToInt(lim2.IntVal)
-	if i2 < 0 {
-		return 0, 0, fmt.Errorf("negative limit: %d", i2)
+		return err
 	}
-	return uint32(i1), uint32(i2), nil
+	_, err = table.Delete(p.global, where)
+	return err
 }
 
-func (p *Parser) parseCreate() error {
+// parseWhere parses an optional WHERE clause and the statement
+// terminator.
+func (p *Parser) parseWhere() (Expr, error) {
+	var where Expr
 	t, err := p.get()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	switch t.Type {
-	case TSymFunction:
-		return p.parseCreateFunction()
-
-	default:
-		return p.errUnexpected(t)
+	if t.Type == TSymWhere {
+		where, err = p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		p.lexer.unget(t)
+	}
+	_, err = p.optional(';')
+	if err != nil {
+		return nil, err
 	}
+	return where, nil
 }
 
 func (p *Parser) parseCreateFunction() error {
@@ -1097,6 +1892,19 @@ func (p *Parser) parseExprPostfix() (Expr, error) {
 	case TSymCase:
 		return p.parseCase()
 
+	case TSymLeft, TSymRight:
+		// The LEFT and RIGHT join keywords are also function names.
+		_, err = p.need('(')
+		if err != nil {
+			return nil, err
+		}
+		t.StrVal = t.Type.String()
+		return p.parseFunc(t)
+
+	case TSymOn:
+		// ON is also a boolean value.
+		val = types.BoolValue(true)
+
 	case TString:
 		val = types.StringValue(t.StrVal)
 	case TInt:
@@ -1118,6 +1926,205 @@ func (p *Parser) parseExprPostfix() (Expr, error) {
 }
 
 func (p *Parser) parseFunc(name *Token) (Expr, error) {
+	distinct, err := p.optional(TSymDistinct)
+	if err != nil {
+		return nil, err
+	}
+	args, err := p.parseArguments()
+	if err != nil {
+		return nil, err
+	}
+	over, err := p.optional(TSymOver)
+	if err != nil {
+		return nil, err
+	}
+	if over != nil {
+		if distinct != nil {
+			return nil, p.errf(distinct.From,
+				"DISTINCT is not allowed in window functions")
+		}
+		return p.parseWindow(name, args)
+	}
+	call := &Call{
+		Name:      strings.ToUpper(name.StrVal),
+		Arguments: args,
+		Distinct:  distinct != nil,
+	}
+
+	// Resolve function.
+	call.Function = builtIn(call.Name)
+	if call.Function == nil {
+		return nil, fmt.Errorf("undefined function: %s", call.Name)
+	}
+	if call.Distinct && !call.Function.Aggregate {
+		return nil, p.errf(distinct.From,
+			"DISTINCT is allowed only in aggregate functions")
+	}
+
+	return call, nil
+}
+
+// parseWindow parses the OVER clause of the window function call.
+func (p *Parser) parseWindow(name *Token, args []Expr) (Expr, error) {
+	w := &Window{
+		Name:      strings.ToUpper(name.StrVal),
+		Arguments: args,
+	}
+	w.Function = windowFunction(w.Name)
+	if w.Function == nil {
+		return nil, p.errf(name.From, "undefined window function: %s", w.Name)
+	}
+	if len(args) < w.Function.MinArgs {
+		return nil, p.errf(name.From,
+			"%s: too few arguments: got %d, expected %d",
+			w.Name, len(args), w.Function.MinArgs)
+	}
+	if len(args) > w.Function.MaxArgs {
+		return nil, p.errf(name.From,
+			"%s: too many arguments: got %d, expected %d",
+			w.Name, len(args), w.Function.MaxArgs)
+	}
+
+	_, err := p.need('(')
+	if err != nil {
+		return nil, err
+	}
+	t, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	if t.Type == TSymPartition {
+		w.PartitionBy, err = p.parseGroupBy()
+		if err != nil {
+			return nil, err
+		}
+		t, err = p.get()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if t.Type == TSymOrder {
+		w.OrderBy, err = p.parseOrderBy()
+		if err != nil {
+			return nil, err
+		}
+		t, err = p.get()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if t.Type == TSymRows {
+		w.Frame, err = p.parseFrame()
+		if err != nil {
+			return nil, err
+		}
+		t, err = p.get()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if t.Type != ')' {
+		return nil, p.errUnexpected(t)
+	}
+	return w, nil
+}
+
+// parseFrame parses the ROWS window frame. The frame without the
+// BETWEEN clause ends at the current row.
+func (p *Parser) parseFrame() (*Frame, error) {
+	between, err := p.optional(TSymBetween)
+	if err != nil {
+		return nil, err
+	}
+	start, err := p.parseFrameBound()
+	if err != nil {
+		return nil, err
+	}
+	frame := &Frame{
+		Start: start,
+		End: FrameBound{
+			Type: BoundCurrentRow,
+		},
+	}
+	if between != nil {
+		_, err = p.need(TAnd)
+		if err != nil {
+			return nil, err
+		}
+		frame.End, err = p.parseFrameBound()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if frame.Start.Type == BoundUnboundedFollowing {
+		return nil, fmt.Errorf("frame start can't be %s", frame.Start)
+	}
+	if frame.End.Type == BoundUnboundedPreceding {
+		return nil, fmt.Errorf("frame end can't be %s", frame.End)
+	}
+	if frame.Start.Type > frame.End.Type {
+		return nil, fmt.Errorf("frame start %s is after frame end %s",
+			frame.Start, frame.End)
+	}
+	return frame, nil
+}
+
+func (p *Parser) parseFrameBound() (FrameBound, error) {
+	var bound FrameBound
+
+	t, err := p.get()
+	if err != nil {
+		return bound, err
+	}
+	switch t.Type {
+	case TSymUnbounded:
+		t, err = p.get()
+		if err != nil {
+			return bound, err
+		}
+		switch t.Type {
+		case TSymPreceding:
+			bound.Type = BoundUnboundedPreceding
+		case TSymFollowing:
+			bound.Type = BoundUnboundedFollowing
+		default:
+			return bound, p.errUnexpected(t)
+		}
+
+	case TSymCurrent:
+		_, err = p.need(TSymRow)
+		if err != nil {
+			return bound, err
+		}
+		bound.Type = BoundCurrentRow
+
+	case TInt:
+		bound.Offset = Int64ToInt(t.IntVal)
+		if bound.Offset < 0 {
+			return bound, fmt.Errorf("negative frame offset: %d", bound.Offset)
+		}
+		t, err = p.get()
+		if err != nil {
+			return bound, err
+		}
+		switch t.Type {
+		case TSymPreceding:
+			bound.Type = BoundPreceding
+		case TSymFollowing:
+			bound.Type = BoundFollowing
+		default:
+			return bound, p.errUnexpected(t)
+		}
+
+	default:
+		return bound, p.errUnexpected(t)
+	}
+	return bound, nil
+}
+
+// parseArguments parses the function call arguments up to the closing
+// ')'.
+func (p *Parser) parseArguments() ([]Expr, error) {
 	var args []Expr
 
 	for {
@@ -1144,18 +2151,7 @@ func (p *Parser) parseFunc(name *Token) (Expr, error) {
 			p.lexer.unget(t)
 		}
 	}
-	call := &Call{
-		Name:      strings.ToUpper(name.StrVal),
-		Arguments: args,
-	}
-
-	// Resolve function.
-	call.Function = builtIn(call.Name)
-	if call.Function == nil {
-		return nil, fmt.Errorf("undefined function: %s", call.Name)
-	}
-
-	return call, nil
+	return args, nil
 }
 
 func (p *Parser) parseCase() (Expr, error) {
@@ -159,6 +159,28 @@ where Data.0 < 2009;`,
 			{"2008", "100"},
 		},
 	},
+
+	// Format sniffing and override.
+	{
+		q: `SELECT a, b FROM 'data:text/plain;base64,YTtiCjE7Mgo=';`,
+		v: [][]string{
+			{"1", "2"},
+		},
+	},
+	{
+		q: `SELECT v FROM 'data:text/plain;base64,W3sidiI6MX0seyJ2IjoyfV0=';`,
+		v: [][]string{
+			{"1"},
+			{"2"},
+		},
+	},
+	{
+		q: `SELECT d.x, d.y
+FROM 'data:application/json;base64,eCx5CjEsMgo=' FORMAT 'csv' AS d;`,
+		v: [][]string{
+			{"1", "2"},
+		},
+	},
 	{
 		q: `SELECT Data.0 AS Year, Data.1 AS Value
 FROM 'data:text/csv;base64,MjAwOCwxMDAKMjAwOSwxMDEKMjAxMCwyMDAK'
@@ -603,6 +625,42 @@ GROUP BY Name, Unit;`,
 		},
 	},
 
+	{
+		q: `
+SELECT Name,
+       COUNT(Unit) AS Count
+FROM (
+	  SELECT "0" AS Name,
+	         "1" AS Unit,
+	         "2" AS Count
+	  FROM 'data:text/csv;base64,YSwxLDIwMAphLDIsMTAwCmEsMiw1MApiLDEsNTAKYiwyLDUwCmIsMywxMDAKYywxLDEwCmMsMSw3Cg=='
+      FILTER 'noheaders'
+     )
+GROUP BY Name
+HAVING COUNT(Unit) > 2;`,
+		v: [][]string{
+			{"a", "3"},
+			{"b", "3"},
+		},
+	},
+	{
+		q: `
+SELECT Name,
+       SUM(Count) AS Sum
+FROM (
+	  SELECT "0" AS Name,
+	         "1" AS Unit,
+	         "2" AS Count
+	  FROM 'data:text/csv;base64,YSwxLDIwMAphLDIsMTAwCmEsMiw1MApiLDEsNTAKYiwyLDUwCmIsMywxMDAKYywxLDEwCmMsMSw3Cg=='
+      FILTER 'noheaders'
+     )
+GROUP BY Name
+HAVING AVG(Count) < 100 AND Name <> 'c';`,
+		v: [][]string{
+			{"b", "200"},
+		},
+	},
+
 	// Ints,Floats,Strings
 	// 1,42.0,foo
 	// 2,3.14,bar
@@ -662,6 +720,627 @@ LIMIT 4, 100;`,
 			{"12"},
 		},
 	},
+	{
+		q: `
+SELECT Strings, COUNT(Ints)
+FROM 'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo='
+LIMIT 1;`,
+		v: [][]string{
+			{"foo", "5"},
+		},
+	},
+	{
+		q: `
+SELECT Strings, COUNT(Ints) + 1
+FROM 'data:text/csv;base64,SW50cyxGbG9hdHMsU3RyaW5ncwoxLDQuMixmb28KMTIsNDIuNyxiYXIKNywzLjE0MTUsemFwcGEKLDIuNzUseAo4LCx5CjEyLDEuMjM0LAo='
+LIMIT 1, 1;`,
+		v: [][]string{
+			{"bar", "6"},
+		},
+	},
+
+	// Joins:
+	//
+	// id,name    cid,amount
+	// 1,alice    1,10
+	// 2,bob      1,20
+	// 3,carol    3,30
+	//            4,40
+	{
+		q: `
+SELECT c.name, o.amount
+FROM 'data:text/csv;base64,aWQsbmFtZQoxLGFsaWNlCjIsYm9iCjMsY2Fyb2wK' AS c
+JOIN 'data:text/csv;base64,Y2lkLGFtb3VudAoxLDEwCjEsMjAKMywzMAo0LDQwCg==' AS o
+ON c.id = o.cid;`,
+		v: [][]string{
+			{"alice", "10"},
+			{"alice", "20"},
+			{"carol", "30"},
+		},
+	},
+	{
+		q: `
+SELECT c.name, o.amount
+FROM 'data:text/csv;base64,aWQsbmFtZQoxLGFsaWNlCjIsYm9iCjMsY2Fyb2wK' AS c
+INNER JOIN 'data:text/csv;base64,Y2lkLGFtb3VudAoxLDEwCjEsMjAKMywzMAo0LDQwCg==' AS o
+ON c.id = o.cid;`,
+		v: [][]string{
+			{"alice", "10"},
+			{"alice", "20"},
+			{"carol", "30"},
+		},
+	},
+	{
+		q: `
+SELECT c.name, o.amount
+FROM 'data:text/csv;base64,aWQsbmFtZQoxLGFsaWNlCjIsYm9iCjMsY2Fyb2wK' AS c
+LEFT OUTER JOIN 'data:text/csv;base64,Y2lkLGFtb3VudAoxLDEwCjEsMjAKMywzMAo0LDQwCg==' AS o
+ON c.id = o.cid;`,
+		v: [][]string{
+			{"alice", "10"},
+			{"alice", "20"},
+			{"bob", "NULL"},
+			{"carol", "30"},
+		},
+	},
+	{
+		q: `
+SELECT c.name, o.amount
+FROM 'data:text/csv;base64,aWQsbmFtZQoxLGFsaWNlCjIsYm9iCjMsY2Fyb2wK' AS c
+RIGHT JOIN 'data:text/csv;base64,Y2lkLGFtb3VudAoxLDEwCjEsMjAKMywzMAo0LDQwCg==' AS o
+ON c.id = o.cid;`,
+		v: [][]string{
+			{"alice", "10"},
+			{"alice", "20"},
+			{"carol", "30"},
+			{"NULL", "40"},
+		},
+	},
+	{
+		q: `
+SELECT c.name, o.amount
+FROM 'data:text/csv;base64,aWQsbmFtZQoxLGFsaWNlCjIsYm9iCjMsY2Fyb2wK' AS c
+FULL JOIN 'data:text/csv;base64,Y2lkLGFtb3VudAoxLDEwCjEsMjAKMywzMAo0LDQwCg==' AS o
+ON c.id = o.cid;`,
+		v: [][]string{
+			{"alice", "10"},
+			{"alice", "20"},
+			{"bob", "NULL"},
+			{"carol", "30"},
+			{"NULL", "40"},
+		},
+	},
+	{
+		q: `
+SELECT c.name, o.amount
+FROM 'data:text/csv;base64,aWQsbmFtZQoxLGFsaWNlCjIsYm9iCjMsY2Fyb2wK' AS c
+JOIN 'data:text/csv;base64,Y2lkLGFtb3VudAoxLDEwCjEsMjAKMywzMAo0LDQwCg==' AS o
+ON c.id = o.cid AND o.amount > 10;`,
+		v: [][]string{
+			{"alice", "20"},
+			{"carol", "30"},
+		},
+	},
+	{
+		q: `
+SELECT c.name, o.amount, c2.name
+FROM 'data:text/csv;base64,aWQsbmFtZQoxLGFsaWNlCjIsYm9iCjMsY2Fyb2wK' AS c
+JOIN 'data:text/csv;base64,Y2lkLGFtb3VudAoxLDEwCjEsMjAKMywzMAo0LDQwCg==' AS o ON c.id = o.cid
+LEFT JOIN 'data:text/csv;base64,aWQsbmFtZQoxLGFsaWNlCjIsYm9iCjMsY2Fyb2wK' AS c2 ON o.amount / 10 = c2.id
+WHERE c.id = 1;`,
+		v: [][]string{
+			{"alice", "10", "alice"},
+			{"alice", "20", "bob"},
+		},
+	},
+	{
+		q: `
+SELECT COUNT(c.id)
+FROM 'data:text/csv;base64,aWQsbmFtZQoxLGFsaWNlCjIsYm9iCjMsY2Fyb2wK' AS c
+CROSS JOIN 'data:text/csv;base64,Y2lkLGFtb3VudAoxLDEwCjEsMjAKMywzMAo0LDQwCg==' AS o;`,
+		v: [][]string{
+			{"12"},
+		},
+	},
+	{
+		q: `
+SELECT c.name, o.amount
+FROM 'data:text/csv;base64,aWQsbmFtZQoxLGFsaWNlCjIsYm9iCjMsY2Fyb2wK' AS c,
+     'data:text/csv;base64,Y2lkLGFtb3VudAoxLDEwCjEsMjAKMywzMAo0LDQwCg==' AS o
+WHERE c.id = o.cid AND 30 > o.amount AND c.name <> 'bob';`,
+		v: [][]string{
+			{"alice", "10"},
+			{"alice", "20"},
+		},
+	},
+	{
+		q: `
+SELECT c.name, o.amount
+FROM 'data:text/csv;base64,aWQsbmFtZQoxLGFsaWNlCjIsYm9iCjMsY2Fyb2wK' AS c
+LEFT JOIN 'data:text/csv;base64,Y2lkLGFtb3VudAoxLDEwCjEsMjAKMywzMAo0LDQwCg==' AS o
+ON c.id = o.cid
+WHERE o.amount = NULL;`,
+		v: [][]string{
+			{"bob", "NULL"},
+		},
+	},
+	{
+		q: `
+SELECT c.name, o.amount
+FROM 'data:text/csv;base64,aWQsbmFtZQoxLGFsaWNlCjIsYm9iCjMsY2Fyb2wK' AS c
+RIGHT JOIN 'data:text/csv;base64,Y2lkLGFtb3VudAoxLDEwCjEsMjAKMywzMAo0LDQwCg==' AS o
+ON c.id = o.cid
+WHERE c.name <> 'alice';`,
+		v: [][]string{
+			{"carol", "30"},
+			{"NULL", "40"},
+		},
+	},
+	{
+		q: `
+EXPLAIN SELECT c.name
+FROM 'data:text/csv;base64,aWQsbmFtZQoxLGFsaWNlCjIsYm9iCjMsY2Fyb2wK' AS c
+WHERE c.id > 1 ORDER BY c.name LIMIT 1;`,
+		v: [][]string{
+			{"1", "SCAN", "csv 'data:text/csv;base64,aWQsbmFtZQoxLGFsaWNlCjIsYm9iCjMsY2Fyb2wK' AS c"},
+			{"2", "COLUMN", "c.name 0.0 varchar"},
+			{"3", "COLUMN", "c.id 0.1 integer"},
+			{"4", "PUSHDOWN", "c.id > 1"},
+			{"5", "FILTER", "c.id > 1"},
+			{"6", "WHERE", ""},
+			{"7", "SELECT", "c.name"},
+			{"8", "ORDER BY", "c.name"},
+			{"9", "LIMIT", "0, 1"},
+		},
+	},
+	{
+		q: `SELECT LEFT('foobar', 3), RIGHT('foobar', 3);`,
+		v: [][]string{
+			{"foo", "bar"},
+		},
+	},
+
+	// Table functions.
+	{
+		q: `SELECT value FROM GENERATE_SERIES(1, 6, 2);`,
+		v: [][]string{
+			{"1"},
+			{"3"},
+			{"5"},
+		},
+	},
+	{
+		q: `
+SELECT s.value
+FROM GENERATE_SERIES('2021-01-31', '2021-04-30', '1 month') AS s;`,
+		v: [][]string{
+			{"2021-01-31 00:00:00"},
+			{"2021-02-28 00:00:00"},
+			{"2021-03-31 00:00:00"},
+			{"2021-04-30 00:00:00"},
+		},
+	},
+	{
+		q: `SELECT index, value FROM SPLIT_TO_TABLE('a,b,,c', ',');`,
+		v: [][]string{
+			{"1", "a"},
+			{"2", "b"},
+			{"3", ""},
+			{"4", "c"},
+		},
+	},
+	// id,tags
+	// 1,a;b
+	// 2,
+	// 3,c
+	{
+		q: `
+SELECT t.id, s.value, s.index
+FROM 'data:text/csv;base64,aWQsdGFncwoxLGE7YgoyLAozLGMK' AS t
+LEFT JOIN SPLIT_TO_TABLE(t.tags, ';') AS s ON s.value <> '';`,
+		v: [][]string{
+			{"1", "a", "1"},
+			{"1", "b", "2"},
+			{"2", "NULL", "NULL"},
+			{"3", "c", "1"},
+		},
+	},
+	{
+		q: `
+SELECT r.".n", l.value
+FROM 'data:text/html;base64,PHRhYmxlPjx0cj48dGQgY2xhc3M9Im4iPng8L3RkPjx0ZD48YT5wPC9hPjxhPnE8L2E+PC90ZD48L3RyPjx0cj48dGQgY2xhc3M9Im4iPnk8L3RkPjx0ZD48YT5yPC9hPjwvdGQ+PC90cj48L3RhYmxlPg==' FILTER 'tr' AS r,
+     UNNEST(r.a) AS l;`,
+		v: [][]string{
+			{"x", "p"},
+			{"x", "q"},
+			{"y", "r"},
+		},
+	},
+	// [{"id":1,"v":[1,10,2]},{"id":2,"v":[30]},{"id":3,"v":[]}]
+	{
+		q: `
+SELECT r.id, SUM(l.value) AS sum, MAX(l.value) AS max
+FROM 'data:application/json;base64,W3siaWQiOjEsInYiOlsxLDEwLDJdfSx7ImlkIjoyLCJ2IjpbMzBdfSx7ImlkIjozLCJ2IjpbXX1d' AS r,
+     UNNEST(r.v) AS l
+WHERE l.value > 5
+GROUP BY r.id;`,
+		v: [][]string{
+			{"1", "10", "10"},
+			{"2", "30", "30"},
+		},
+	},
+
+	// Window functions:
+	//
+	// region,day,amount
+	// east,1,10
+	// east,2,30
+	// east,3,20
+	// west,1,5
+	// west,2,5
+	// west,3,40
+	{
+		q: `
+SELECT region, day,
+       ROW_NUMBER() OVER (PARTITION BY region ORDER BY amount DESC) AS n,
+       RANK() OVER (PARTITION BY region ORDER BY amount) AS r,
+       DENSE_RANK() OVER (ORDER BY region) AS d
+FROM 'data:text/csv;base64,cmVnaW9uLGRheSxhbW91bnQKZWFzdCwxLDEwCmVhc3QsMiwzMAplYXN0LDMsMjAKd2VzdCwxLDUKd2VzdCwyLDUKd2VzdCwzLDQwCg==';`,
+		v: [][]string{
+			{"east", "1", "3", "1", "1"},
+			{"east", "2", "1", "3", "1"},
+			{"east", "3", "2", "2", "1"},
+			{"west", "1", "2", "1", "2"},
+			{"west", "2", "3", "1", "2"},
+			{"west", "3", "1", "3", "2"},
+		},
+	},
+	{
+		q: `
+SELECT day,
+       LAG(amount) OVER (PARTITION BY region ORDER BY day) AS prev,
+       amount - LAG(amount, 1, 0) OVER (PARTITION BY region ORDER BY day),
+       LEAD(amount, 2) OVER (ORDER BY region, day) AS next
+FROM 'data:text/csv;base64,cmVnaW9uLGRheSxhbW91bnQKZWFzdCwxLDEwCmVhc3QsMiwzMAplYXN0LDMsMjAKd2VzdCwxLDUKd2VzdCwyLDUKd2VzdCwzLDQwCg=='
+WHERE region = 'east';`,
+		v: [][]string{
+			{"1", "NULL", "10", "20"},
+			{"2", "10", "20", "NULL"},
+			{"3", "30", "-10", "NULL"},
+		},
+	},
+	{
+		q: `
+SELECT day,
+       SUM(amount) OVER (PARTITION BY region ORDER BY day) AS total,
+       SUM(amount) OVER (ORDER BY day
+                         ROWS BETWEEN 1 PRECEDING AND 1 FOLLOWING) AS sum3,
+       COUNT(amount) OVER () AS count,
+       FIRST_VALUE(amount) OVER (ORDER BY day) AS first,
+       LAST_VALUE(amount) OVER (ORDER BY day
+                                ROWS BETWEEN CURRENT ROW
+                                AND UNBOUNDED FOLLOWING) AS last
+FROM 'data:text/csv;base64,cmVnaW9uLGRheSxhbW91bnQKZWFzdCwxLDEwCmVhc3QsMiwzMAplYXN0LDMsMjAKd2VzdCwxLDUKd2VzdCwyLDUKd2VzdCwzLDQwCg=='
+WHERE region = 'west';`,
+		v: [][]string{
+			{"1", "5", "10", "3", "5", "40"},
+			{"2", "10", "50", "3", "5", "40"},
+			{"3", "50", "45", "3", "5", "40"},
+		},
+	},
+	{
+		q: `
+SELECT MIN(amount) OVER (ORDER BY region, day
+                         ROWS BETWEEN 1 PRECEDING AND 1 FOLLOWING) AS min3,
+       MAX(amount) OVER (ORDER BY region, day
+                         ROWS BETWEEN 2 PRECEDING AND CURRENT ROW) AS max3,
+       AVG(amount) OVER (ORDER BY region, day
+                         ROWS BETWEEN 1 PRECEDING AND CURRENT ROW) AS avg2,
+       SUM(amount) OVER (ORDER BY region, day
+                         ROWS BETWEEN UNBOUNDED PRECEDING
+                         AND 1 PRECEDING) AS before,
+       DENSE_RANK() OVER (ORDER BY amount) AS d
+FROM 'data:text/csv;base64,cmVnaW9uLGRheSxhbW91bnQKZWFzdCwxLDEwCmVhc3QsMiwzMAplYXN0LDMsMjAKd2VzdCwxLDUKd2VzdCwyLDUKd2VzdCwzLDQwCg==';`,
+		v: [][]string{
+			{"10", "10", "10", "NULL", "2"},
+			{"10", "30", "20", "10", "4"},
+			{"5", "30", "25", "40", "3"},
+			{"5", "30", "12", "60", "1"},
+			{"5", "20", "5", "65", "1"},
+			{"5", "40", "22", "70", "5"},
+		},
+	},
+	{
+		q: `
+SELECT a,
+       MIN(b) OVER (ORDER BY a
+                    ROWS BETWEEN 1 PRECEDING AND CURRENT ROW) AS min2,
+       MAX(b) OVER (ORDER BY a
+                    ROWS BETWEEN 1 PRECEDING AND CURRENT ROW) AS max2
+FROM 'data:text/csv;base64,YSxiCjEsCjIsCjMsNAo=';`,
+		v: [][]string{
+			{"1", "NULL", "NULL"},
+			{"2", "NULL", "NULL"},
+			{"3", "4", "4"},
+		},
+	},
+	{
+		q: `
+SELECT region, SUM(amount) AS total,
+       RANK() OVER (ORDER BY SUM(amount) DESC) AS rank
+FROM 'data:text/csv;base64,cmVnaW9uLGRheSxhbW91bnQKZWFzdCwxLDEwCmVhc3QsMiwzMAplYXN0LDMsMjAKd2VzdCwxLDUKd2VzdCwyLDUKd2VzdCwzLDQwCg=='
+GROUP BY region
+ORDER BY region;`,
+		v: [][]string{
+			{"east", "60", "1"},
+			{"west", "50", "2"},
+		},
+	},
+
+	// Common table expressions.
+	{
+		q: `
+WITH big AS (SELECT region, amount FROM 'data:text/csv;base64,cmVnaW9uLGRheSxhbW91bnQKZWFzdCwxLDEwCmVhc3QsMiwzMAplYXN0LDMsMjAKd2VzdCwxLDUKd2VzdCwyLDUKd2VzdCwzLDQwCg==' WHERE amount > 10),
+     totals (r, total) AS (
+         SELECT region, SUM(amount) FROM big GROUP BY region
+     )
+SELECT b.region, b.amount, t.total
+FROM big AS b
+JOIN totals AS t ON b.region = t.r;`,
+		v: [][]string{
+			{"east", "30", "50"},
+			{"east", "20", "50"},
+			{"west", "40", "40"},
+		},
+	},
+	{
+		q: `
+WITH RECURSIVE n AS (
+    SELECT 1 AS x
+    UNION ALL
+    SELECT x + 1 FROM n WHERE x < 4
+)
+SELECT x FROM n;`,
+		v: [][]string{
+			{"1"},
+			{"2"},
+			{"3"},
+			{"4"},
+		},
+	},
+	// id,parent
+	// 1,0
+	// 2,1
+	// 3,1
+	// 4,2
+	// 5,0
+	{
+		q: `
+WITH RECURSIVE tree (id, depth) AS (
+    SELECT id, 0 FROM 'data:text/csv;base64,aWQscGFyZW50CjEsMAoyLDEKMywxCjQsMgo1LDAK' WHERE parent = 0
+    UNION ALL
+    SELECT c.id, t.depth + 1
+    FROM tree AS t
+    JOIN 'data:text/csv;base64,aWQscGFyZW50CjEsMAoyLDEKMywxCjQsMgo1LDAK' AS c ON c.parent = t.id
+)
+SELECT id, depth FROM tree ORDER BY id;`,
+		v: [][]string{
+			{"1", "0"},
+			{"2", "1"},
+			{"3", "1"},
+			{"4", "2"},
+			{"5", "0"},
+		},
+	},
+	{
+		q: `
+WITH RECURSIVE c (a) AS (SELECT 1 UNION SELECT 3 - a FROM c)
+SELECT a FROM c;`,
+		v: [][]string{
+			{"1"},
+			{"2"},
+		},
+	},
+
+	// Set operations.
+	// name,n
+	// a,1
+	// b,2
+	// b,2
+	// c,3
+	//
+	// [{"name":"b","n":2},{"name":"c","n":3.0},{"name":"d","n":4}]
+	{
+		q: `
+SELECT name, n FROM 'data:text/csv;base64,bmFtZSxuCmEsMQpiLDIKYiwyCmMsMwo='
+UNION
+SELECT name, n FROM 'data:application/json;base64,W3sibmFtZSI6ImIiLCJuIjoyfSx7Im5hbWUiOiJjIiwibiI6My4wfSx7Im5hbWUiOiJkIiwibiI6NH1dCg=='
+ORDER BY name;`,
+		v: [][]string{
+			{"a", "1"},
+			{"b", "2"},
+			{"c", "3"},
+			{"d", "4"},
+		},
+	},
+	{
+		q: `
+SELECT name, n FROM 'data:text/csv;base64,bmFtZSxuCmEsMQpiLDIKYiwyCmMsMwo='
+UNION ALL
+SELECT name, n FROM 'data:application/json;base64,W3sibmFtZSI6ImIiLCJuIjoyfSx7Im5hbWUiOiJjIiwibiI6My4wfSx7Im5hbWUiOiJkIiwibiI6NH1dCg=='
+ORDER BY name DESC LIMIT 3;`,
+		v: [][]string{
+			{"d", "4"},
+			{"c", "3"},
+			{"c", "3"},
+		},
+	},
+	{
+		q: `
+SELECT name, n FROM 'data:text/csv;base64,bmFtZSxuCmEsMQpiLDIKYiwyCmMsMwo='
+INTERSECT
+SELECT name, n FROM 'data:application/json;base64,W3sibmFtZSI6ImIiLCJuIjoyfSx7Im5hbWUiOiJjIiwibiI6My4wfSx7Im5hbWUiOiJkIiwibiI6NH1dCg=='
+ORDER BY name;`,
+		v: [][]string{
+			{"b", "2"},
+			{"c", "3"},
+		},
+	},
+	{
+		q: `
+SELECT name, n FROM 'data:text/csv;base64,bmFtZSxuCmEsMQpiLDIKYiwyCmMsMwo='
+INTERSECT ALL
+SELECT name, n FROM 'data:application/json;base64,W3sibmFtZSI6ImIiLCJuIjoyfSx7Im5hbWUiOiJjIiwibiI6My4wfSx7Im5hbWUiOiJkIiwibiI6NH1dCg=='
+ORDER BY name;`,
+		v: [][]string{
+			{"b", "2"},
+			{"c", "3"},
+		},
+	},
+	{
+		q: `
+SELECT name, n FROM 'data:text/csv;base64,bmFtZSxuCmEsMQpiLDIKYiwyCmMsMwo='
+EXCEPT
+SELECT name, n FROM 'data:application/json;base64,W3sibmFtZSI6ImIiLCJuIjoyfSx7Im5hbWUiOiJjIiwibiI6My4wfSx7Im5hbWUiOiJkIiwibiI6NH1dCg=='
+ORDER BY name;`,
+		v: [][]string{
+			{"a", "1"},
+		},
+	},
+	{
+		q: `
+SELECT name, n FROM 'data:text/csv;base64,bmFtZSxuCmEsMQpiLDIKYiwyCmMsMwo='
+EXCEPT ALL
+SELECT name, n FROM 'data:application/json;base64,W3sibmFtZSI6ImIiLCJuIjoyfSx7Im5hbWUiOiJjIiwibiI6My4wfSx7Im5hbWUiOiJkIiwibiI6NH1dCg=='
+ORDER BY name;`,
+		v: [][]string{
+			{"a", "1"},
+			{"b", "2"},
+		},
+	},
+	{
+		q: `
+SELECT COUNT(u.name) AS count
+FROM (
+    SELECT name FROM 'data:text/csv;base64,bmFtZSxuCmEsMQpiLDIKYiwyCmMsMwo='
+    UNION
+    SELECT name FROM 'data:application/json;base64,W3sibmFtZSI6ImIiLCJuIjoyfSx7Im5hbWUiOiJjIiwibiI6My4wfSx7Im5hbWUiOiJkIiwibiI6NH1dCg=='
+    INTERSECT
+    SELECT name FROM 'data:text/csv;base64,bmFtZSxuCmEsMQpiLDIKYiwyCmMsMwo='
+) AS u;`,
+		v: [][]string{
+			{"3"},
+		},
+	},
+
+	// Distinct.
+	{
+		q: `
+SELECT DISTINCT name, n FROM 'data:text/csv;base64,bmFtZSxuCmEsMQpiLDIKYiwyCmMsMwo=';`,
+		v: [][]string{
+			{"a", "1"},
+			{"b", "2"},
+			{"c", "3"},
+		},
+	},
+	{
+		q: `
+SELECT DISTINCT n / 2 AS half FROM 'data:text/csv;base64,bmFtZSxuCmEsMQpiLDIKYiwyCmMsMwo=' ORDER BY n / 2 DESC;`,
+		v: [][]string{
+			{"1"},
+			{"0"},
+		},
+	},
+	{
+		q: `
+SELECT DISTINCT region FROM 'data:text/csv;base64,cmVnaW9uLGRheSxhbW91bnQKZWFzdCwxLDEwCmVhc3QsMiwzMAplYXN0LDMsMjAKd2VzdCwxLDUKd2VzdCwyLDUKd2VzdCwzLDQwCg==' LIMIT 1;`,
+		v: [][]string{
+			{"east"},
+		},
+	},
+	{
+		q: `
+SELECT COUNT(DISTINCT name), SUM(DISTINCT n), AVG(DISTINCT n), COUNT(n)
+FROM 'data:text/csv;base64,bmFtZSxuCmEsMQpiLDIKYiwyCmMsMwo=';`,
+		v: [][]string{
+			{"3", "6", "2", "4"},
+		},
+	},
+	{
+		q: `
+SELECT region, COUNT(DISTINCT amount), SUM(DISTINCT amount)
+FROM 'data:text/csv;base64,cmVnaW9uLGRheSxhbW91bnQKZWFzdCwxLDEwCmVhc3QsMiwzMAplYXN0LDMsMjAKd2VzdCwxLDUKd2VzdCwyLDUKd2VzdCwzLDQwCg=='
+GROUP BY region
+ORDER BY region;`,
+		v: [][]string{
+			{"east", "3", "60"},
+			{"west", "2", "45"},
+		},
+	},
+	{
+		q: `
+SELECT DISTINCT CASE WHEN name = 'a' THEN 1
+                     WHEN name = 'c' THEN 1.0
+                     ELSE NULL END AS x
+FROM 'data:text/csv;base64,bmFtZSxuCmEsMQpiLDIKYiwyCmMsMwo=';`,
+		v: [][]string{
+			{"1"},
+			{"NULL"},
+		},
+	},
+	{
+		q: `
+SELECT COUNT(DISTINCT CASE WHEN name = 'a' THEN 1
+                           WHEN name = 'c' THEN 1.0
+                           ELSE n END),
+       SUM(DISTINCT CASE WHEN name = 'a' THEN 1
+                         WHEN name = 'c' THEN 1.0
+                         ELSE n END),
+       COUNT(DISTINCT CASE WHEN name = 'a' THEN n END)
+FROM 'data:text/csv;base64,bmFtZSxuCmEsMQpiLDIKYiwyCmMsMwo=';`,
+		v: [][]string{
+			{"2", "3", "1"},
+		},
+	},
+
+	// Tables.
+	{
+		q: `
+CREATE TABLE users (id INTEGER, name VARCHAR, score REAL);
+INSERT INTO users VALUES (1, 'alice', 1.5), (2, 'bob', 2);
+INSERT INTO users (name, id) VALUES ('carol', 3);
+SELECT * FROM users;
+UPDATE users SET score = score * 2, name = name + '!' WHERE id <> 3;
+SELECT id, name, score FROM users;
+DELETE FROM users WHERE id = 1;
+SELECT u.id, u.name FROM users AS u;
+INSERT INTO users SELECT id + 10, name, score FROM users WHERE id = 2;
+SELECT id FROM users;
+DELETE FROM users;
+SELECT * FROM users;`,
+		v: [][]string{
+			{"1", "alice", "1.5"},
+			{"2", "bob", "2"},
+			{"3", "carol", "NULL"},
+		},
+		rest: [][][]string{
+			{
+				{"1", "alice!", "3"},
+				{"2", "bob!", "4"},
+				{"3", "carol", "NULL"},
+			},
+			{
+				{"2", "bob!"},
+				{"3", "carol"},
+			},
+			{
+				{"2"},
+				{"3"},
+				{"12"},
+			},
+			{},
+		},
+	},
 
 	// Functions.
 	{
@@ -0,0 +1,228 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"github.com/markkurossi/iql/types"
+)
+
+// plan implements the query execution plan. The plan splits the WHERE
+// expression into its AND terms and evaluates each term as early as
+// possible:
+//
+//   - filters hold the terms that reference only one source. They are
+//     evaluated for the source rows before the source is joined.
+//   - joins hold the terms that reference multiple sources. They are
+//     evaluated as soon as the last referenced source is joined.
+//   - residual holds the remaining terms. They are evaluated for the
+//     fully joined rows.
+//
+// The terms referencing sources that outer joins extend with null
+// rows are kept in the residual so that they see the null rows.
+type plan struct {
+	filters  []Expr
+	joins    []Expr
+	residual Expr
+	pushed   [][]types.Predicate
+}
+
+// newPlan creates the execution plan for the query. The WHERE and ON
+// expressions must be bound before calling this function.
+func newPlan(iql *Query) (*plan, error) {
+	p := &plan{
+		filters: make([]Expr, len(iql.From)),
+		joins:   make([]Expr, len(iql.From)),
+		pushed:  make([][]types.Predicate, len(iql.From)),
+	}
+	for _, term := range conjuncts(iql.Where) {
+		sources, err := iql.sourcesOf(term)
+		if err != nil {
+			return nil, err
+		}
+		// Terms without column references and aggregate terms are
+		// evaluated for the final rows.
+		if len(sources) == 0 || term.IsIdempotent() {
+			p.residual = conjoin(p.residual, term)
+			continue
+		}
+		var last int
+		var nullable bool
+		for source := range sources {
+			if source > last {
+				last = source
+			}
+			if iql.nullable(source) {
+				nullable = true
+			}
+		}
+		if nullable {
+			p.residual = conjoin(p.residual, term)
+		} else if len(sources) == 1 {
+			p.filters[last] = conjoin(p.filters[last], term)
+			p.push(iql, last, term)
+		} else {
+			p.joins[last] = conjoin(p.joins[last], term)
+		}
+	}
+	return p, nil
+}
+
+// nullable tests if outer joins extend the source idx with null rows.
+func (iql *Query) nullable(idx int) bool {
+	switch iql.From[idx].Join {
+	case JoinLeft, JoinFull:
+		return true
+	}
+	for i := idx + 1; i < len(iql.From); i++ {
+		if iql.From[i].Join.Outer() {
+			return true
+		}
+	}
+	return false
+}
+
+var predicateOps = map[BinaryType]types.Op{
+	BinEq:  types.OpEq,
+	BinNeq: types.OpNEq,
+	BinLt:  types.OpLt,
+	BinLe:  types.OpLe,
+	BinGt:  types.OpGt,
+	BinGe:  types.OpGe,
+}
+
+var swappedOps = map[types.Op]types.Op{
+	types.OpEq:  types.OpEq,
+	types.OpNEq: types.OpNEq,
+	types.OpLt:  types.OpGt,
+	types.OpLe:  types.OpGe,
+	types.OpGt:  types.OpLt,
+	types.OpGe:  types.OpLe,
+}
+
+// push pushes the term into the source idx if the source can filter
+// its rows natively. Only comparisons between a column and a constant
+// or a variable are pushed. The term is still evaluated by the query
+// so the pushed predicate only reduces the number of rows that the
+// query processes.
+func (p *plan) push(iql *Query, idx int, term Expr) {
+	filterable, ok := iql.From[idx].Source.(types.Filterable)
+	if !ok {
+		return
+	}
+	bin, ok := term.(*Binary)
+	if !ok {
+		return
+	}
+	op, ok := predicateOps[bin.Type]
+	if !ok {
+		return
+	}
+	ref, value := predicateOperands(bin.Left, bin.Right)
+	if ref == nil {
+		ref, value = predicateOperands(bin.Right, bin.Left)
+		if ref == nil {
+			return
+		}
+		op = swappedOps[op]
+	}
+	val, err := value.Eval(&Row{}, nil)
+	if err != nil || val == types.Null {
+		return
+	}
+	if !predicateTypes(ref.index.Type, op, val) {
+		return
+	}
+	pred := types.Predicate{
+		Column: ref.index.Column,
+		Op:     op,
+		Value:  val,
+	}
+	if filterable.Filter(pred) {
+		p.pushed[idx] = append(p.pushed[idx], pred)
+	}
+}
+
+// predicateOperands returns the column reference and the value
+// expression of a predicate. The function returns nil if the column
+// is not a source column or if the value is not a constant or a
+// variable.
+func predicateOperands(column, value Expr) (*Reference, Expr) {
+	ref, ok := column.(*Reference)
+	if !ok || ref.binding != nil {
+		return nil, nil
+	}
+	switch v := value.(type) {
+	case *Constant:
+	case *Reference:
+		if v.binding == nil {
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+	return ref, value
+}
+
+// predicateTypes tests if the column type and value can be compared
+// with types.Predicate so that the comparison gives the same result
+// as the corresponding binary expression. The binary expression fails
+// for some type combinations and they are not pushed so that the
+// query reports the errors.
+func predicateTypes(column types.Type, op types.Op, val types.Value) bool {
+	// The float and string expressions do not implement <= and >=.
+	supported := op != types.OpLe && op != types.OpGe
+
+	switch val.(type) {
+	case types.BoolValue:
+		return column == types.Bool && (op == types.OpEq || op == types.OpNEq)
+
+	case types.IntValue:
+		switch column {
+		case types.Int:
+			return true
+		case types.Float:
+			return supported
+		}
+		return false
+
+	case types.FloatValue:
+		return (column == types.Int || column == types.Float) && supported
+
+	case types.StringValue:
+		switch column {
+		case types.String, types.Date, types.Any:
+			return supported
+		}
+		return false
+
+	default:
+		return false
+	}
+}
+
+// evalFilter evaluates the filter expression for the row.
+func evalFilter(expr Expr, row *Row) (bool, error) {
+	if expr == nil {
+		return true, nil
+	}
+	val, err := expr.Eval(row, nil)
+	if err != nil {
+		return false, err
+	}
+	return val.Bool()
+}
+
+// conjoin combines the expressions with AND.
+func conjoin(left, right Expr) Expr {
+	if left == nil {
+		return right
+	}
+	return &And{
+		Left:  left,
+		Right: right,
+	}
+}
@@ -8,12 +8,13 @@ package lang
 
 import (
 	"fmt"
+	"io"
 	"math"
-	"os"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/markkurossi/iql/types"
-	"github.com/markkurossi/tabulate"
 )
 
 var (
@@ -24,16 +25,26 @@ var (
 // that the query can be used as a nested data source for other
 // queries.
 type Query struct {
+	Distinct      bool
 	Select        []ColumnSelector
 	From          []SourceSelector
 	Into          *Binding
 	Where         Expr
 	GroupBy       []Expr
+	Having        Expr
 	OrderBy       []Order
 	LimitFrom     uint32
 	Limit         uint32
+	Explain       Explain
 	Global        *Scope
 	fromColumns   map[string]ColumnIndex
+	input         types.RowIterator
+	joins         []*hashJoin
+	windows       []*Window
+	plan          *plan
+	stats         *stats
+	rows          [][]types.Row
+	scanLimit     uint64
 	evaluated     bool
 	resultColumns []types.ColumnSelector
 	result        []types.Row
@@ -78,10 +89,17 @@ func (col ColumnSelector) String() string {
 	return fmt.Sprintf("%s TYPE %s", col.Expr, col.Type)
 }
 
-// SourceSelector defines an input source with an optional name alias.
+// SourceSelector defines an input source with an optional name
+// alias. The Join and On fields specify how the source is joined to
+// the preceding sources of the query.
 type SourceSelector struct {
 	Source types.Source
 	As     string
+	Join   JoinType
+	On     Expr
+	url    []string
+	filter string
+	format string
 }
 
 // Columns implements the Source.Columns().
@@ -89,61 +107,51 @@ func (iql *Query) Columns() []types.ColumnSelector {
 	return iql.resultColumns
 }
 
+// Rows implements the Source.Rows().
+func (iql *Query) Rows() (types.RowIterator, error) {
+	rows, err := iql.Get()
+	if err != nil {
+		return nil, err
+	}
+	return types.NewRowsIterator(rows), nil
+}
+
 // Get implements the Source.Get().
 func (iql *Query) Get() ([]types.Row, error) {
 	if iql.evaluated {
 		return iql.result, nil
 	}
 
-	// Eval all sources.
+	// Eval all sources. The first source is iterated lazily and the
+	// remaining sources are materialized for the joins. The
+	// filterable sources are evaluated after the query plan has
+	// pushed predicates into them.
 	for sourceIdx, from := range iql.From {
-		_, err := from.Source.Get()
-		if err != nil {
-			return nil, err
-		}
-		if false {
-			fmt.Printf("Source %d", sourceIdx)
-			if len(from.As) > 0 {
-				fmt.Printf("\tAS %s", from.As)
-			}
-			fmt.Println()
-			tab := tabulate.New(tabulate.Unicode)
-			tab.Header("Index")
-			tab.Header("data.Name")
-			tab.Header("data.As")
-			tab.Header("Type")
-
-			for idx, col := range from.Source.Columns() {
-				row := tab.Row()
-				row.Column(fmt.Sprintf("%d", idx))
-				row.Column(col.Name.String())
-				row.Column(col.As)
-				row.Column(col.Type.String())
+		// Table functions are bound to the preceding sources. The
+		// lateral functions are evaluated when their sources are
+		// joined.
+		tf, ok := from.Source.(*TableFunction)
+		if ok {
+			if err := tf.bind(iql, sourceIdx); err != nil {
+				return nil, err
 			}
-			tab.Print(os.Stdout)
 		}
-
-		// Collect column names.
-		for columnIdx, col := range from.Source.Columns() {
-			var columnName string
-			if len(col.As) > 0 {
-				columnName = col.As
-			} else {
-				columnName = col.Name.Column
-			}
-
-			var key string
-			if len(from.As) > 0 {
-				key = fmt.Sprintf("%s.%s", from.As, columnName)
+		_, filterable := from.Source.(types.Filterable)
+		if !filterable && !iql.lateral(sourceIdx) {
+			var err error
+			if sourceIdx == 0 {
+				iql.input, err = from.Source.Rows()
+				if err == nil {
+					defer iql.input.Close()
+				}
 			} else {
-				key = columnName
+				_, err = from.Source.Get()
 			}
-			iql.fromColumns[key] = ColumnIndex{
-				Source: sourceIdx,
-				Column: columnIdx,
-				Type:   col.Type,
+			if err != nil {
+				return nil, err
 			}
 		}
+		iql.collectColumns(sourceIdx)
 	}
 
 	if len(iql.Select) == 0 {
@@ -152,6 +160,9 @@ func (iql *Query) Get() ([]types.Row, error) {
 			columns := f.Source.Columns()
 			for _, col := range columns {
 				ref := col.Name
+				if len(col.As) != 0 {
+					ref.Column = col.As
+				}
 				if len(f.As) != 0 {
 					ref.Source = f.As
 				}
@@ -188,6 +199,7 @@ func (iql *Query) Get() ([]types.Row, error) {
 
 	// Bind SELECT expressions.
 	var idempotent = true
+	var aggregate bool
 	for _, sel := range iql.Select {
 		if err := sel.Expr.Bind(iql); err != nil {
 			return nil, err
@@ -195,7 +207,11 @@ func (iql *Query) Get() ([]types.Row, error) {
 		if !sel.Expr.IsIdempotent() {
 			idempotent = false
 		}
+		if hasAggregate(sel.Expr) {
+			aggregate = true
+		}
 	}
+	windows := len(iql.windows)
 	// Bind WHERE expressions.
 	if iql.Where != nil {
 		if err := iql.Where.Bind(iql); err != nil {
@@ -208,20 +224,111 @@ func (iql *Query) Get() ([]types.Row, error) {
 			return nil, err
 		}
 	}
+	// Bind HAVING expression.
+	if iql.Having != nil {
+		if err := iql.Having.Bind(iql); err != nil {
+			return nil, err
+		}
+	}
 	// Bind ORDER BY expressions.
 	for _, order := range iql.OrderBy {
 		if err := order.Expr.Bind(iql); err != nil {
 			return nil, err
 		}
 	}
+	if len(iql.windows) > windows {
+		return nil, fmt.Errorf("%s: window functions are allowed only in "+
+			"SELECT", iql.windows[windows])
+	}
+	// Bind ON expressions.
+	for idx, from := range iql.From {
+		if from.On == nil {
+			continue
+		}
+		if err := from.On.Bind(iql); err != nil {
+			return nil, err
+		}
+		sources, err := iql.sourcesOf(from.On)
+		if err != nil {
+			return nil, err
+		}
+		for source := range sources {
+			if source > idx {
+				return nil, fmt.Errorf("ON %s: references source '%s' "+
+					"before it is joined", from.On, iql.From[source].As)
+			}
+		}
+	}
+
+	// Create query plan and push predicates into sources.
+	var err error
+	iql.plan, err = newPlan(iql)
+	if err != nil {
+		return nil, err
+	}
+	switch iql.Explain {
+	case ExplainPlan:
+		return iql.explain()
+	case ExplainAnalyze:
+		iql.stats = newStats(len(iql.From))
+	}
+	if iql.input == nil && len(iql.From) > 0 {
+		iql.input, err = iql.From[0].Source.Rows()
+		if err != nil {
+			return nil, err
+		}
+		defer iql.input.Close()
+	}
+	if iql.stats != nil && iql.input != nil {
+		iql.input = &statsIterator{
+			it:    iql.input,
+			stage: &iql.stats.sources[0],
+		}
+	}
+
+	// Filter joined sources and create joins.
+	iql.joins = make([]*hashJoin, len(iql.From))
+	iql.rows = make([][]types.Row, len(iql.From))
+	for idx := 1; idx < len(iql.From); idx++ {
+		if iql.lateral(idx) {
+			continue
+		}
+		iql.rows[idx], err = iql.filterRows(idx)
+		if err != nil {
+			return nil, err
+		}
+		if iql.From[idx].On != nil {
+			start := time.Now()
+			iql.joins[idx], err = newHashJoin(iql, idx, iql.rows[idx])
+			if err != nil {
+				return nil, err
+			}
+			if iql.stats != nil {
+				iql.stats.joined[idx].time = time.Since(start)
+			}
+		}
+	}
+
+	// Without sorting, grouping, and aggregates, the input scanning
+	// can stop as soon as the LIMIT rows have been found.
+	iql.scanLimit = math.MaxUint64
+	if len(iql.GroupBy) == 0 && iql.Having == nil && len(iql.OrderBy) == 0 &&
+		len(iql.windows) == 0 && !iql.Distinct && !idempotent && !aggregate {
+		iql.scanLimit = uint64(iql.LimitFrom) + uint64(iql.Limit)
+	}
 
 	var matches []*Row
-	err := iql.eval(0, nil, &matches)
+	start := time.Now()
+	err = iql.eval(0, nil, &matches)
 	if err != nil {
 		return nil, err
 	}
+	if iql.stats != nil {
+		iql.stats.where.add(len(matches), start)
+	}
 
 	// Group by.
+	start = time.Now()
 	grouping := NewGrouping()
 	for _, match := range matches {
 		var key []types.Value
@@ -234,36 +341,35 @@ func (iql *Query) Get() ([]types.Row, error) {
 		}
 		grouping.Add(key, match)
 	}
+	groups := grouping.Get()
+	if iql.stats != nil {
+		iql.stats.group.add(len(groups), start)
+	}
 
-	// Select result columns.
-	matches = nil
-	format := Format(iql.Global)
-	for _, group := range grouping.Get() {
-		for _, match := range group {
-			var row types.Row
-			var i int
-			for _, sel := range iql.Select {
-				if !sel.IsPublic() {
-					continue
-				}
-				val, err := sel.Expr.Eval(match, group)
-				if err != nil {
-					return nil, err
-				}
-				if val == types.Null {
-					row = append(row, types.NullColumn{})
-				} else {
-					if format != nil {
-						val = types.NewFormattedValue(val, format)
-					}
-					row = append(row, types.NewValueColumn(val))
-					iql.resultColumns[i].ResolveValue(val)
-				}
-				i++
+	// Select result rows.
+	start = time.Now()
+	var selected []groupRow
+	for _, group := range groups {
+		if iql.Having != nil {
+			val, err := iql.Having.Eval(group[0], group)
+			if err != nil {
+				return nil, err
 			}
-			matches = append(matches, &Row{
-				Data:  []types.Row{row},
-				Order: match.Order,
+			match, err := val.Bool()
+			if err != nil {
+				return nil, err
+			}
+			if !match {
+				continue
+			}
+			if iql.stats != nil {
+				iql.stats.having.rows++
+			}
+		}
+		for _, match := range group {
+			selected = append(selected, groupRow{
+				row:   match,
+				group: group,
 			})
 			// Idempotent and GROUP BY return one result per group.
 			if idempotent || len(iql.GroupBy) > 0 {
@@ -272,7 +378,53 @@ func (iql *Query) Get() ([]types.Row, error) {
 		}
 	}
 
+	// Evaluate window functions over the selected rows.
+	for _, w := range iql.windows {
+		if err := w.eval(selected); err != nil {
+			return nil, err
+		}
+	}
+
+	// Select result columns.
+	matches = nil
+	format := Format(iql.Global)
+	for _, sel := range selected {
+		var row types.Row
+		var i int
+		for _, col := range iql.Select {
+			if !col.IsPublic() {
+				continue
+			}
+			val, err := col.Expr.Eval(sel.row, sel.group)
+			if err != nil {
+				return nil, err
+			}
+			if val == types.Null {
+				row = append(row, types.NullColumn{})
+			} else {
+				if format != nil && val.Type() == types.Float {
+					val = types.NewFormattedValue(val, format)
+				}
+				row = append(row, types.NewValueColumn(val))
+				iql.resultColumns[i].ResolveValue(val)
+			}
+			i++
+		}
+		matches = append(matches, &Row{
+			Data:  []types.Row{row},
+			Order: sel.row.Order,
+		})
+	}
+
+	if iql.Distinct {
+		matches = distinctRows(matches)
+	}
+	if iql.stats != nil {
+		iql.stats.selects.add(len(matches), start)
+	}
+
 	// Order results.
+	start = time.Now()
 	var sortErr error
 	sort.Slice(matches, func(i, j int) bool {
 		o1 := matches[i].Order
@@ -304,7 +456,11 @@ func (iql *Query) Get() ([]types.Row, error) {
 	if sortErr != nil {
 		return nil, sortErr
 	}
+	if iql.stats != nil {
+		iql.stats.order.add(len(matches), start)
+	}
 
+	start = time.Now()
 	for idx, match := range matches {
 		if uint32(idx) < iql.LimitFrom ||
 			uint32(idx) >= iql.LimitFrom+iql.Limit {
@@ -312,28 +468,186 @@ func (iql *Query) Get() ([]types.Row, error) {
 		}
 		iql.result = append(iql.result, match.Data[0])
 	}
-
 	iql.evaluated = true
 
+	if iql.stats != nil {
+		iql.stats.limit.add(len(iql.result), start)
+		return iql.explain()
+	}
 	return iql.result, nil
 }
 
+// distinctRows removes the duplicate result rows. The rows are
+// duplicates if all their columns are the same by sameValue.
+func distinctRows(rows []*Row) []*Row {
+	seen := make(distinctSet)
+	var result []*Row
+	for _, row := range rows {
+		var values []types.Value
+		for _, col := range row.Data[0] {
+			values = append(values, columnValue(col))
+		}
+		if seen.add(values) {
+			result = append(result, row)
+		}
+	}
+	return result
+}
+
+// distinctSet holds distinct value tuples. The tuples are hashed by
+// their writeKey keys and the tuples with the same key are compared
+// with sameValue. The keys of the values, which are the same by
+// sameValue, are equal, but the values of different types can share
+// keys, such as 1 and '1'.
+type distinctSet map[string][][]types.Value
+
+// add adds the values to the set. The function returns false if the
+// set already holds the same values.
+func (set distinctSet) add(values []types.Value) bool {
+	var sb strings.Builder
+	for _, val := range values {
+		writeKey(&sb, val)
+	}
+	key := sb.String()
+next:
+	for _, seen := range set[key] {
+		for i, val := range seen {
+			if !sameValue(val, values[i]) {
+				continue next
+			}
+		}
+		return false
+	}
+	set[key] = append(set[key], values)
+	return true
+}
+
+// sameValue tests if the values are not distinct. The values are
+// compared with types.Compare so that the NULL values are the same,
+// and the values of different types are distinct. The integer and
+// float values are compared as numbers so that 1 and 1.0 are the same
+// as by the '=' operator.
+func sameValue(a, b types.Value) bool {
+	switch a.(type) {
+	case types.IntValue, types.FloatValue:
+		switch b.(type) {
+		case types.IntValue, types.FloatValue:
+			if a.Type() != b.Type() {
+				fa, _ := a.Float()
+				fb, _ := b.Float()
+				return fa == fb
+			}
+		}
+	}
+	cmp, err := types.Compare(a, b)
+	if err != nil {
+		// The array and record values are compared by their
+		// string representations.
+		return a.Type() == b.Type() && a.String() == b.String()
+	}
+	return cmp == 0
+}
+
+// reset resets the query evaluation state so that the query can be
+// evaluated again.
+func (iql *Query) reset() {
+	iql.input = nil
+	iql.joins = nil
+	iql.windows = nil
+	iql.plan = nil
+	iql.stats = nil
+	iql.rows = nil
+	iql.evaluated = false
+	iql.resultColumns = nil
+	iql.result = nil
+}
+
+// collectColumns collects the column names of the source
+// sourceIdx. The source's columns must be resolved before this
+// function is called.
+func (iql *Query) collectColumns(sourceIdx int) {
+	from := iql.From[sourceIdx]
+	for columnIdx, col := range from.Source.Columns() {
+		var columnName string
+		if len(col.As) > 0 {
+			columnName = col.As
+		} else {
+			columnName = col.Name.Column
+		}
+
+		var key string
+		if len(from.As) > 0 {
+			key = fmt.Sprintf("%s.%s", from.As, columnName)
+		} else {
+			key = columnName
+		}
+		iql.fromColumns[key] = ColumnIndex{
+			Source:   sourceIdx,
+			Column:   columnIdx,
+			Type:     col.Type,
+			ElemType: col.ElemType,
+		}
+	}
+}
+
+// filterRows returns the rows of the source idx that match the
+// source's filters.
+func (iql *Query) filterRows(idx int) ([]types.Row, error) {
+	start := time.Now()
+	rows, err := iql.From[idx].Source.Get()
+	if err != nil {
+		return nil, err
+	}
+	if iql.stats != nil {
+		iql.stats.sources[idx].add(len(rows), start)
+	}
+	filter := iql.plan.filters[idx]
+	if filter == nil {
+		return rows, nil
+	}
+	start = time.Now()
+	var result []types.Row
+	row := &Row{
+		Data: make([]types.Row, idx+1),
+	}
+	for _, r := range rows {
+		row.Data[idx] = r
+		match, err := evalFilter(filter, row)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			result = append(result, r)
+		}
+	}
+	if iql.stats != nil {
+		iql.stats.filters[idx].add(len(result), start)
+	}
+	return result, nil
+}
+
 func (iql *Query) eval(idx int, data []types.Row, result *[]*Row) error {
 
+	// Check the terms whose sources have been joined.
+	if idx > 0 {
+		match, err := evalFilter(iql.plan.joins[idx-1], &Row{
+			Data: data,
+		})
+		if err != nil || !match {
+			return err
+		}
+		if iql.stats != nil {
+			iql.stats.joined[idx-1].rows++
+		}
+	}
+
 	if idx >= len(iql.From) {
-		match := true
 		row := &Row{
 			Data: data,
 		}
-		if iql.Where != nil {
-			val, err := iql.Where.Eval(row, nil)
-			if err != nil {
-				return err
-			}
-			match, err = val.Bool()
-			if err != nil {
-				return err
-			}
+		match, err := evalFilter(iql.plan.residual, row)
+		if err != nil {
+			return err
 		}
 		if match {
 			// ORDER BY
@@ -350,13 +664,130 @@ func (iql *Query) eval(idx int, data []types.Row, result *[]*Row) error {
 		return nil
 	}
 
-	rows, err := iql.From[idx].Source.Get()
+	if idx == 0 {
+		for uint64(len(*result)) < iql.scanLimit {
+			row, err := iql.input.Next()
+			if err != nil {
+				if err == io.EOF {
+					return iql.evalUnmatched(result)
+				}
+				return err
+			}
+			data := []types.Row{row}
+			var start time.Time
+			if iql.stats != nil {
+				start = time.Now()
+			}
+			match, err := evalFilter(iql.plan.filters[0], &Row{
+				Data: data,
+			})
+			if err != nil {
+				return err
+			}
+			if iql.stats != nil {
+				iql.stats.joined[0].time += time.Since(start)
+			}
+			if !match {
+				continue
+			}
+			err = iql.eval(idx+1, data, result)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if iql.lateral(idx) {
+		return iql.evalLateral(idx, data, result)
+	}
+
+	join := iql.joins[idx]
+	if join != nil {
+		return join.join(data, func(data []types.Row) error {
+			return iql.eval(idx+1, data, result)
+		})
+	}
+
+	for _, row := range iql.rows[idx] {
+		err := iql.eval(idx+1, append(data[:idx:idx], row), result)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lateral tests if the source idx is a table function that
+// references the columns of its preceding sources.
+func (iql *Query) lateral(idx int) bool {
+	tf, ok := iql.From[idx].Source.(*TableFunction)
+	return ok && tf.lateral
+}
+
+// evalLateral evaluates the lateral table function of the source idx
+// for the data rows of the preceding sources and joins the function
+// rows to the data.
+func (iql *Query) evalLateral(idx int, data []types.Row,
+	result *[]*Row) error {
+
+	from := iql.From[idx]
+	tf := from.Source.(*TableFunction)
+
+	start := time.Now()
+	rows, err := tf.eval(&Row{
+		Data: data[:idx:idx],
+	}, false)
 	if err != nil {
 		return err
 	}
+	if iql.stats != nil {
+		iql.stats.sources[idx].add(len(rows), start)
+	}
+	var match bool
+	for _, r := range rows {
+		joined := append(data[:idx:idx], r)
+		row := &Row{
+			Data: joined,
+		}
+		ok, err := evalFilter(iql.plan.filters[idx], row)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if iql.stats != nil {
+			iql.stats.filters[idx].rows++
+		}
+		ok, err = evalFilter(from.On, row)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		match = true
+		if err := iql.eval(idx+1, joined, result); err != nil {
+			return err
+		}
+	}
+	if !match && from.Join == JoinLeft {
+		return iql.eval(idx+1,
+			append(data[:idx:idx], nullRow(len(tf.Columns()))), result)
+	}
+	return nil
+}
 
-	for _, row := range rows {
-		err := iql.eval(idx+1, append(data, row), result)
+// evalUnmatched evaluates the unmatched rows of RIGHT and FULL joins.
+func (iql *Query) evalUnmatched(result *[]*Row) error {
+	for idx, join := range iql.joins {
+		if join == nil || !join.typ.Outer() {
+			continue
+		}
+		err := join.unmatched(iql, func(data []types.Row) error {
+			return iql.eval(idx+1, data, result)
+		})
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,183 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/iql/data"
+	"github.com/markkurossi/iql/types"
+)
+
+// failingReader returns an error after its data has been read.
+type failingReader struct {
+	in io.Reader
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.in.Read(p)
+	if err == io.EOF {
+		return n, errors.New("read past limit")
+	}
+	return n, err
+}
+
+func (r *failingReader) Close() error {
+	return nil
+}
+
+func TestQueryLimitStopsReading(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("Ints\n")
+	for i := 0; i < 100; i++ {
+		sb.WriteString("1\n")
+	}
+	source, err := data.NewCSV([]io.ReadCloser{
+		&failingReader{
+			in: strings.NewReader(sb.String()),
+		},
+	}, "sample=10", nil)
+	if err != nil {
+		t.Fatalf("NewCSV failed: %s", err)
+	}
+
+	q := NewQuery(NewScope(nil))
+	q.From = []SourceSelector{
+		{
+			Source: source,
+		},
+	}
+	q.Limit = 5
+
+	rows, err := q.Get()
+	if err != nil {
+		t.Fatalf("q.Get failed: %s", err)
+	}
+	if len(rows) != 5 {
+		t.Errorf("got %d rows, expected 5", len(rows))
+	}
+}
+
+func TestQueryExplainAnalyze(t *testing.T) {
+	parser := NewParser(NewScope(nil), strings.NewReader(`
+EXPLAIN ANALYZE SELECT c.name, o.amount
+FROM 'data:text/csv;base64,aWQsbmFtZQoxLGFsaWNlCjIsYm9iCjMsY2Fyb2wK' AS c
+JOIN 'data:text/csv;base64,Y2lkLGFtb3VudAoxLDEwCjEsMjAKMywzMAo0LDQwCg==' AS o
+ON c.id = o.cid
+WHERE o.amount > 10;`), "explain", ioutil.Discard)
+	q, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	rows, err := q.Get()
+	if err != nil {
+		t.Fatalf("q.Get failed: %s", err)
+	}
+	if len(q.Columns()) != 5 {
+		t.Fatalf("got %d columns, expected 5", len(q.Columns()))
+	}
+	expected := map[string]string{
+		"SCAN":       "3",
+		"SOURCE":     "3",
+		"FILTER":     "3",
+		"INNER JOIN": "2",
+		"WHERE":      "2",
+		"SELECT":     "2",
+	}
+	for _, row := range rows {
+		op := row[1].String()
+		count, ok := expected[op]
+		if !ok {
+			continue
+		}
+		if row[3].String() != count {
+			t.Errorf("%s: got %s rows, expected %s", op, row[3], count)
+		}
+		delete(expected, op)
+	}
+	for op := range expected {
+		t.Errorf("operation %s not explained", op)
+	}
+}
+
+func TestQueryTypeChangeAfterSample(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("a,b\n")
+	for i := 0; i < data.DefaultSampleSize+100; i++ {
+		sb.WriteString("1,2\n")
+	}
+	sb.WriteString("1.5,N/A\n")
+
+	file := filepath.Join(t.TempDir(), "input.csv")
+	err := ioutil.WriteFile(file, []byte(sb.String()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		q string
+		v []string
+	}{
+		{
+			q: "SELECT SUM(a), COUNT(b) FROM '%s';",
+			v: []string{"1125.5", "1125"},
+		},
+		{
+			q: "SELECT a, b FROM '%s' WHERE a > 1;",
+			v: []string{"1.5", "N/A"},
+		},
+	}
+	for _, test := range tests {
+		q := fmt.Sprintf(test.q, file)
+		parser := NewParser(NewScope(nil), strings.NewReader(q), "test",
+			ioutil.Discard)
+		query, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %s", q, err)
+		}
+		rows, err := query.Get()
+		if err != nil {
+			t.Fatalf("%s: Get failed: %s", q, err)
+		}
+		if len(rows) != 1 {
+			t.Fatalf("%s: got %d rows, expected 1", q, len(rows))
+		}
+		for idx, col := range rows[0] {
+			if col.String() != test.v[idx] {
+				t.Errorf("%s: column %d: got %s, expected %s", q, idx,
+					col, test.v[idx])
+			}
+		}
+	}
+}
+
+func TestDistinctSet(t *testing.T) {
+	set := make(distinctSet)
+	for idx, test := range []struct {
+		values []types.Value
+		added  bool
+	}{
+		{[]types.Value{types.IntValue(1), types.Null}, true},
+		{[]types.Value{types.FloatValue(1), types.Null}, false},
+		{[]types.Value{types.StringValue("1"), types.Null}, true},
+		{[]types.Value{types.StringValue("1"), types.Null}, false},
+		{[]types.Value{types.IntValue(1), types.StringValue("")}, true},
+		{[]types.Value{types.FloatValue(1.5), types.Null}, true},
+		{[]types.Value{types.IntValue(1), types.Null}, false},
+	} {
+		if added := set.add(test.values); added != test.added {
+			t.Errorf("test %d: add(%v): got %v, expected %v",
+				idx, test.values, added, test.added)
+		}
+	}
+}
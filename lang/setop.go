@@ -0,0 +1,216 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/markkurossi/iql/types"
+)
+
+var (
+	_ types.Source = &SetOperation{}
+)
+
+// SetOp specifies set operations.
+type SetOp int
+
+// Set operations.
+const (
+	SetUnion SetOp = iota
+	SetIntersect
+	SetExcept
+)
+
+var setOps = map[SetOp]string{
+	SetUnion:     "UNION",
+	SetIntersect: "INTERSECT",
+	SetExcept:    "EXCEPT",
+}
+
+func (op SetOp) String() string {
+	name, ok := setOps[op]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{SetOp %d}", op)
+}
+
+// SetOperation implements the UNION, INTERSECT, and EXCEPT set
+// operations between the rows of two sources. The sources must have
+// the same number of columns and the column types must be
+// compatible. Unless All is true, the duplicate rows are removed from
+// the result.
+type SetOperation struct {
+	Op        SetOp
+	All       bool
+	Left      types.Source
+	Right     types.Source
+	columns   []types.ColumnSelector
+	rows      []types.Row
+	evaluated bool
+}
+
+func (set *SetOperation) String() string {
+	if set.All {
+		return fmt.Sprintf("%s ALL", set.Op)
+	}
+	return set.Op.String()
+}
+
+// Columns implements the Source.Columns().
+func (set *SetOperation) Columns() []types.ColumnSelector {
+	return set.columns
+}
+
+// Get implements the Source.Get().
+func (set *SetOperation) Get() ([]types.Row, error) {
+	if set.evaluated {
+		return set.rows, nil
+	}
+	left, err := set.Left.Get()
+	if err != nil {
+		return nil, err
+	}
+	right, err := set.Right.Get()
+	if err != nil {
+		return nil, err
+	}
+	set.columns, err = set.resolveColumns()
+	if err != nil {
+		return nil, err
+	}
+
+	switch set.Op {
+	case SetUnion:
+		rows := append(left[:len(left):len(left)], right...)
+		if set.All {
+			set.rows = rows
+		} else {
+			set.rows = set.distinct(rows)
+		}
+
+	case SetIntersect, SetExcept:
+		counts := make(map[string]int)
+		for _, row := range right {
+			counts[rowKey(set.columns, row)]++
+		}
+		if !set.All {
+			left = set.distinct(left)
+		}
+		for _, row := range left {
+			key := rowKey(set.columns, row)
+			count := counts[key]
+			if set.All && count > 0 {
+				// Each right row matches one left row.
+				counts[key] = count - 1
+			}
+			if (set.Op == SetIntersect) == (count > 0) {
+				set.rows = append(set.rows, row)
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported set operation: %s", set.Op)
+	}
+	set.evaluated = true
+
+	return set.rows, nil
+}
+
+// Rows implements the Source.Rows().
+func (set *SetOperation) Rows() (types.RowIterator, error) {
+	rows, err := set.Get()
+	if err != nil {
+		return nil, err
+	}
+	return types.NewRowsIterator(rows), nil
+}
+
+// resolveColumns checks that the left and right sources have
+// compatible columns. The function returns the columns of the left
+// source with the common types of the columns.
+func (set *SetOperation) resolveColumns() ([]types.ColumnSelector, error) {
+	left := set.Left.Columns()
+	right := set.Right.Columns()
+	if len(left) != len(right) {
+		return nil, fmt.Errorf("%s: queries have different number of "+
+			"columns: %d and %d", set, len(left), len(right))
+	}
+	result := make([]types.ColumnSelector, len(left))
+	copy(result, left)
+	for idx := range result {
+		t, ok := commonType(left[idx].Type, right[idx].Type)
+		if !ok {
+			return nil, fmt.Errorf("%s: column %d types %s and %s are "+
+				"incompatible", set, idx+1, left[idx].Type, right[idx].Type)
+		}
+		result[idx].Type = t
+	}
+	return result, nil
+}
+
+// distinct returns the rows without duplicates.
+func (set *SetOperation) distinct(rows []types.Row) []types.Row {
+	seen := make(map[string]bool)
+	var result []types.Row
+	for _, row := range rows {
+		key := rowKey(set.columns, row)
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, row)
+		}
+	}
+	return result
+}
+
+// commonType returns the type that can hold the values of both
+// types. The bool type is the type of the columns whose type is not
+// resolved so it is compatible with all types. The integer and float
+// types are widened to float.
+func commonType(t1, t2 types.Type) (types.Type, bool) {
+	if t1 == t2 {
+		return t1, true
+	}
+	if t1 > t2 {
+		t1, t2 = t2, t1
+	}
+	switch {
+	case t1 == types.Bool, t2 == types.Any:
+		return t2, true
+	case t1 == types.Int && t2 == types.Float:
+		return t2, true
+	default:
+		return t1, false
+	}
+}
+
+// rowKey returns a key that identifies the row values. The values are
+// converted to the column types so that the rows, whose values are
+// equal by the '=' operator, have the same keys.
+func rowKey(columns []types.ColumnSelector, row types.Row) string {
+	var sb strings.Builder
+	for idx, col := range row {
+		var val types.Value
+		var err error
+
+		switch columns[idx].Type {
+		case types.Int:
+			val, err = col.Int()
+		case types.Float:
+			val, err = col.Float()
+		default:
+			val = columnValue(col)
+		}
+		if err != nil {
+			val = columnValue(col)
+		}
+		writeKey(&sb, val)
+	}
+	return sb.String()
+}
@@ -8,19 +8,42 @@ package lang
 
 import (
 	"fmt"
+	"net/url"
+	"time"
 
+	"github.com/markkurossi/iql/data"
 	"github.com/markkurossi/iql/types"
 	"github.com/markkurossi/tabulate"
 )
 
 // System variables.
 const (
-	SysARGS     = "ARGS"
-	SysRealFmt  = "REALFMT"
-	SysTableFmt = "TABLEFMT"
-	SysTermOut  = "TERMOUT"
+	SysARGS          = "ARGS"
+	SysHTTPAuth      = "HTTPAUTH"
+	SysHTTPBody      = "HTTPBODY"
+	SysHTTPCache     = "HTTPCACHE"
+	SysHTTPCacheDir  = "HTTPCACHEDIR"
+	SysHTTPCacheTTL  = "HTTPCACHETTL"
+	SysHTTPHeaders   = "HTTPHEADERS"
+	SysHTTPHosts     = "HTTPHOSTS"
+	SysHTTPMethod    = "HTTPMETHOD"
+	SysHTTPPageMax   = "HTTPPAGEMAX"
+	SysHTTPPageNext  = "HTTPPAGENEXT"
+	SysHTTPPageParam = "HTTPPAGEPARAM"
+	SysHTTPPaging    = "HTTPPAGING"
+	SysHTTPProxy     = "HTTPPROXY"
+	SysHTTPRetry     = "HTTPRETRY"
+	SysHTTPTimeout   = "HTTPTIMEOUT"
+	SysOutFmt        = "OUTFMT"
+	SysRealFmt       = "REALFMT"
+	SysTableFmt      = "TABLEFMT"
+	SysTermOut       = "TERMOUT"
 )
 
+// OutFmtTable specifies the default output format that prints the
+// results as tables, formatted with the TABLEFMT style.
+const OutFmtTable = "table"
+
 var sysvars = []struct {
 	name string
 	typ  types.Type
@@ -34,6 +57,129 @@ var sysvars = []struct {
 			ElemType: types.String,
 		},
 	},
+	{
+		name: SysHTTPAuth,
+		typ:  types.String,
+		def:  types.StringValue(""),
+		ver: func(name string, t types.Type, v types.Value) error {
+			if v == types.Null || len(v.String()) == 0 {
+				return nil
+			}
+			_, _, err := data.ParseAuth(v.String())
+			return err
+		},
+	},
+	{
+		name: SysHTTPBody,
+		typ:  types.String,
+		def:  types.StringValue(""),
+	},
+	{
+		name: SysHTTPCache,
+		typ:  types.String,
+		def:  types.StringValue(data.CacheOff.String()),
+		ver: func(name string, t types.Type, v types.Value) error {
+			_, err := data.ParseCacheMode(v.String())
+			return err
+		},
+	},
+	{
+		name: SysHTTPCacheDir,
+		typ:  types.String,
+		def:  types.StringValue(""),
+	},
+	{
+		name: SysHTTPCacheTTL,
+		typ:  types.Int,
+		def:  types.IntValue(0),
+		ver:  verifyNonNegative,
+	},
+	{
+		name: SysHTTPHeaders,
+		typ:  types.String,
+		def:  types.StringValue(""),
+		ver: func(name string, t types.Type, v types.Value) error {
+			if v == types.Null {
+				return nil
+			}
+			_, err := data.ParseHeaders(v.String())
+			return err
+		},
+	},
+	{
+		name: SysHTTPHosts,
+		typ:  types.String,
+		def:  types.StringValue(""),
+	},
+	{
+		name: SysHTTPMethod,
+		typ:  types.String,
+		def:  types.StringValue("GET"),
+	},
+	{
+		name: SysHTTPPageMax,
+		typ:  types.Int,
+		def:  types.IntValue(100),
+		ver:  verifyNonNegative,
+	},
+	{
+		name: SysHTTPPageNext,
+		typ:  types.String,
+		def:  types.StringValue(""),
+	},
+	{
+		name: SysHTTPPageParam,
+		typ:  types.String,
+		def:  types.StringValue(""),
+	},
+	{
+		name: SysHTTPPaging,
+		typ:  types.String,
+		def:  types.StringValue(data.PageNone.String()),
+		ver: func(name string, t types.Type, v types.Value) error {
+			_, err := data.ParsePageMode(v.String())
+			return err
+		},
+	},
+	{
+		name: SysHTTPProxy,
+		typ:  types.String,
+		def:  types.StringValue(""),
+		ver: func(name string, t types.Type, v types.Value) error {
+			if v == types.Null {
+				return nil
+			}
+			_, err := url.Parse(v.String())
+			return err
+		},
+	},
+	{
+		name: SysHTTPRetry,
+		typ:  types.Int,
+		def:  types.IntValue(0),
+		ver:  verifyNonNegative,
+	},
+	{
+		name: SysHTTPTimeout,
+		typ:  types.Int,
+		def:  types.IntValue(0),
+		ver:  verifyNonNegative,
+	},
+	{
+		name: SysOutFmt,
+		typ:  types.String,
+		def:  types.StringValue(OutFmtTable),
+		ver: func(name string, t types.Type, v types.Value) error {
+			if v.String() == OutFmtTable {
+				return nil
+			}
+			_, ok := types.Writers[v.String()]
+			if !ok {
+				return fmt.Errorf("invalid output format: %s", v.String())
+			}
+			return nil
+		},
+	},
 	{
 		name: SysRealFmt,
 		typ:  types.String,
@@ -58,6 +204,20 @@ var sysvars = []struct {
 	},
 }
 
+func verifyNonNegative(name string, t types.Type, v types.Value) error {
+	if v == types.Null {
+		return nil
+	}
+	i, err := v.Int()
+	if err != nil {
+		return err
+	}
+	if i < 0 {
+		return fmt.Errorf("%s must not be negative: %d", name, i)
+	}
+	return nil
+}
+
 // InitSystemVariables initializes the global system variables for the
 // scope.
 func InitSystemVariables(scope *Scope) {
@@ -81,3 +241,62 @@ func Format(scope *Scope) *types.Format {
 		Float: real.Value.String(),
 	}
 }
+
+// DataOptions gets the data source options from the scope.
+func DataOptions(scope *Scope) (*data.Options, error) {
+	options := new(data.Options)
+
+	str := func(name string) string {
+		b := scope.Get(name)
+		if b == nil || b.Value == types.Null {
+			return ""
+		}
+		return b.Value.String()
+	}
+	integer := func(name string) int64 {
+		b := scope.Get(name)
+		if b == nil || b.Value == types.Null {
+			return 0
+		}
+		i, err := b.Value.Int()
+		if err != nil {
+			return 0
+		}
+		return i
+	}
+
+	options.HTTP.Method = str(SysHTTPMethod)
+	options.HTTP.Body = str(SysHTTPBody)
+	options.HTTP.Auth = str(SysHTTPAuth)
+	options.HTTP.Hosts = data.ParseHosts(str(SysHTTPHosts))
+	options.HTTP.Proxy = str(SysHTTPProxy)
+	options.HTTP.Retry = int(integer(SysHTTPRetry))
+	options.HTTP.Timeout = time.Duration(integer(SysHTTPTimeout)) * time.Second
+
+	header, err := data.ParseHeaders(str(SysHTTPHeaders))
+	if err != nil {
+		return nil, err
+	}
+	options.HTTP.Header = header
+
+	if b := scope.Get(SysHTTPCache); b != nil {
+		options.Cache.Mode, err = data.ParseCacheMode(b.Value.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+	options.Cache.TTL = time.Duration(integer(SysHTTPCacheTTL)) * time.Second
+	options.Cache.Dir = str(SysHTTPCacheDir)
+
+	if b := scope.Get(SysHTTPPaging); b != nil {
+		options.Page.Mode, err = data.ParsePageMode(b.Value.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+	options.Page.Next = str(SysHTTPPageNext)
+	options.Page.Param = str(SysHTTPPageParam)
+	options.Page.Max = int(integer(SysHTTPPageMax))
+
+	return options, nil
+}
@@ -11,8 +11,13 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+
+	"github.com/markkurossi/iql/types"
 )
 
 var systemTests = []struct {
@@ -63,3 +68,51 @@ func TestSystem(t *testing.T) {
 		}
 	}
 }
+
+func TestSystemHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" ||
+				r.Header.Get("Accept") != "text/csv" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Type", "text/csv")
+			w.Write([]byte("a,b\n1,2\n"))
+		}))
+	defer server.Close()
+
+	global := NewScope(nil)
+	InitSystemVariables(global)
+	err := global.Set(SysHTTPCacheDir, types.StringValue(t.TempDir()))
+	if err != nil {
+		t.Fatalf("failed to set cache directory: %v", err)
+	}
+	parser := NewParser(global, strings.NewReader(`
+SET HTTPAUTH = 'Bearer token';
+SET HTTPHEADERS = 'Accept: text/csv';
+SELECT a, b FROM url;
+SET HTTPHOSTS = 'example.com, 127.0.0.1';
+SELECT a, b FROM url;`), "http", os.Stdout)
+	parser.SetString("url", server.URL)
+
+	// The credentials are not sent without HTTPHOSTS.
+	_, err = parser.Parse()
+	if err == nil || !strings.Contains(err.Error(), "not sent to host") {
+		t.Fatalf("expected credentials error, got: %v", err)
+	}
+	q, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	verifyResult(t, "http", "", q, [][]string{{"1", "2"}})
+
+	err = global.Set(SysHTTPAuth, types.StringValue("Digest foo"))
+	if err == nil {
+		t.Errorf("invalid HTTPAUTH accepted")
+	}
+	err = global.Set(SysHTTPRetry, types.IntValue(-1))
+	if err == nil {
+		t.Errorf("negative HTTPRETRY accepted")
+	}
+}
@@ -0,0 +1,233 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/markkurossi/iql/types"
+)
+
+var (
+	_ types.Source = &Table{}
+)
+
+// Table implements a mutable in-memory table. The tables are created
+// with the CREATE TABLE statement and modified with the INSERT,
+// UPDATE, and DELETE statements.
+type Table struct {
+	Name    string
+	columns []types.ColumnSelector
+	rows    []types.Row
+}
+
+// Assignment defines a column assignment of the UPDATE statement.
+type Assignment struct {
+	Column string
+	Expr   Expr
+}
+
+// NewTable creates a new table with the name and columns.
+func NewTable(name string, columns []types.ColumnSelector) (*Table, error) {
+	seen := make(map[string]bool)
+	for _, col := range columns {
+		key := strings.ToUpper(col.Name.Column)
+		if seen[key] {
+			return nil, fmt.Errorf("table %s: duplicate column '%s'",
+				name, col.Name.Column)
+		}
+		seen[key] = true
+	}
+	return &Table{
+		Name:    name,
+		columns: columns,
+	}, nil
+}
+
+// Columns implements the Source.Columns().
+func (t *Table) Columns() []types.ColumnSelector {
+	return t.columns
+}
+
+// Get implements the Source.Get().
+func (t *Table) Get() ([]types.Row, error) {
+	return t.rows, nil
+}
+
+// Rows implements the Source.Rows().
+func (t *Table) Rows() (types.RowIterator, error) {
+	return types.NewRowsIterator(t.rows), nil
+}
+
+// column returns the index of the named column.
+func (t *Table) column(name string) (int, error) {
+	for idx, col := range t.columns {
+		if strings.EqualFold(col.Name.Column, name) {
+			return idx, nil
+		}
+	}
+	return 0, fmt.Errorf("table %s: undefined column '%s'", t.Name, name)
+}
+
+// Insert inserts a row into the table. The columns specify the
+// target columns of the values. If the columns are empty, the values
+// are assigned to all table columns in order. The columns that are
+// not assigned are set to null.
+func (t *Table) Insert(columns []string, values []types.Value) error {
+	indices := make([]int, len(columns))
+	for i, name := range columns {
+		idx, err := t.column(name)
+		if err != nil {
+			return err
+		}
+		indices[i] = idx
+	}
+	if len(columns) == 0 {
+		indices = make([]int, len(t.columns))
+		for i := range indices {
+			indices[i] = i
+		}
+	}
+	if len(values) != len(indices) {
+		return fmt.Errorf("table %s: got %d values, expected %d",
+			t.Name, len(values), len(indices))
+	}
+
+	row := make(types.Row, len(t.columns))
+	for i := range row {
+		row[i] = types.NullColumn{}
+	}
+	for i, val := range values {
+		col, err := t.convert(indices[i], val)
+		if err != nil {
+			return err
+		}
+		row[indices[i]] = col
+	}
+	t.rows = append(t.rows, row)
+	return nil
+}
+
+// Update updates the table rows matching the where expression. The
+// function returns the number of updated rows.
+func (t *Table) Update(global *Scope, set []Assignment,
+	where Expr) (int, error) {
+
+	q := t.query(global)
+	indices := make([]int, len(set))
+	for i, a := range set {
+		var err error
+		indices[i], err = t.column(a.Column)
+		if err != nil {
+			return 0, err
+		}
+		if err := a.Expr.Bind(q); err != nil {
+			return 0, err
+		}
+	}
+	if where != nil {
+		if err := where.Bind(q); err != nil {
+			return 0, err
+		}
+	}
+
+	var count int
+	for rowIdx, data := range t.rows {
+		row := &Row{
+			Data: []types.Row{data},
+		}
+		match, err := t.match(where, row)
+		if err != nil {
+			return 0, err
+		}
+		if !match {
+			continue
+		}
+		// Evaluate all assignments against the original row.
+		updated := make(types.Row, len(data))
+		copy(updated, data)
+		for i, a := range set {
+			val, err := a.Expr.Eval(row, nil)
+			if err != nil {
+				return 0, err
+			}
+			updated[indices[i]], err = t.convert(indices[i], val)
+			if err != nil {
+				return 0, err
+			}
+		}
+		t.rows[rowIdx] = updated
+		count++
+	}
+	return count, nil
+}
+
+// Delete deletes the table rows matching the where expression. The
+// function returns the number of deleted rows.
+func (t *Table) Delete(global *Scope, where Expr) (int, error) {
+	q := t.query(global)
+	if where != nil {
+		if err := where.Bind(q); err != nil {
+			return 0, err
+		}
+	}
+
+	var rows []types.Row
+	for _, data := range t.rows {
+		match, err := t.match(where, &Row{
+			Data: []types.Row{data},
+		})
+		if err != nil {
+			return 0, err
+		}
+		if !match {
+			rows = append(rows, data)
+		}
+	}
+	count := len(t.rows) - len(rows)
+	t.rows = rows
+	return count, nil
+}
+
+// query creates a query for binding expressions to the table
+// columns.
+func (t *Table) query(global *Scope) *Query {
+	q := NewQuery(global)
+	q.From = []SourceSelector{
+		{
+			Source: t,
+			As:     t.Name,
+		},
+	}
+	q.collectColumns(0)
+	return q
+}
+
+func (t *Table) match(where Expr, row *Row) (bool, error) {
+	if where == nil {
+		return true, nil
+	}
+	val, err := where.Eval(row, nil)
+	if err != nil {
+		return false, err
+	}
+	return val.Bool()
+}
+
+// convert converts the value to the type of the column idx.
+func (t *Table) convert(idx int, val types.Value) (types.Column, error) {
+	if val == types.Null {
+		return types.NullColumn{}, nil
+	}
+	v, err := castValue(val, t.columns[idx].Type)
+	if err != nil {
+		return nil, fmt.Errorf("table %s: column '%s': %s",
+			t.Name, t.columns[idx].Name.Column, err)
+	}
+	return types.NewValueColumn(v), nil
+}
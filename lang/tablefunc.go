@@ -0,0 +1,480 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markkurossi/iql/types"
+)
+
+var (
+	_ types.Source = &TableFunction{}
+)
+
+// TableFunction implements table-valued functions of the FROM
+// clause. The functions whose arguments reference only constants and
+// variables are evaluated once when the query binds its sources. The
+// lateral functions reference the columns of the preceding sources of
+// the query and they are evaluated for each row of the preceding
+// sources.
+type TableFunction struct {
+	Name      string
+	Arguments []Expr
+	Function  *TableFunctionDef
+	columns   []types.ColumnSelector
+	lateral   bool
+	rows      []types.Row
+}
+
+// TableFunctionDef defines a built-in table-valued function.
+type TableFunctionDef struct {
+	Name    string
+	Columns []string
+	Impl    TableFunctionImpl
+	Types   func(args []Expr) []types.Type
+	MinArgs int
+	MaxArgs int
+}
+
+// TableFunctionImpl implements the built-in table-valued functions.
+// The function returns the values of the generated rows.
+type TableFunctionImpl func(args []Expr, row *Row) ([][]types.Value, error)
+
+var tableFunctions = []TableFunctionDef{
+	{
+		Name:    "GENERATE_SERIES",
+		Columns: []string{"value"},
+		Impl:    tableGenerateSeries,
+		Types:   typesGenerateSeries,
+		MinArgs: 2,
+		MaxArgs: 3,
+	},
+	{
+		Name:    "SPLIT_TO_TABLE",
+		Columns: []string{"value", "index"},
+		Impl:    tableSplitToTable,
+		Types:   typesStringIndex,
+		MinArgs: 2,
+		MaxArgs: 2,
+	},
+	{
+		Name:    "UNNEST",
+		Columns: []string{"value", "index"},
+		Impl:    tableUnnest,
+		Types:   typesUnnest,
+		MinArgs: 1,
+		MaxArgs: 1,
+	},
+}
+
+var tableFunctionsByName map[string]*TableFunctionDef
+
+func init() {
+	tableFunctionsByName = make(map[string]*TableFunctionDef)
+	for idx, tf := range tableFunctions {
+		tableFunctionsByName[tf.Name] = &tableFunctions[idx]
+	}
+}
+
+func tableFunction(name string) *TableFunctionDef {
+	return tableFunctionsByName[name]
+}
+
+func (tf *TableFunction) String() string {
+	return fmt.Sprintf("%s(%s)", tf.Name, exprList(tf.Arguments))
+}
+
+// Columns implements the Source.Columns().
+func (tf *TableFunction) Columns() []types.ColumnSelector {
+	return tf.columns
+}
+
+// Get implements the Source.Get().
+func (tf *TableFunction) Get() ([]types.Row, error) {
+	if tf.lateral {
+		return nil, fmt.Errorf("%s: lateral function evaluated without "+
+			"input row", tf.Name)
+	}
+	return tf.rows, nil
+}
+
+// Rows implements the Source.Rows().
+func (tf *TableFunction) Rows() (types.RowIterator, error) {
+	rows, err := tf.Get()
+	if err != nil {
+		return nil, err
+	}
+	return types.NewRowsIterator(rows), nil
+}
+
+// bind binds the function arguments to the query where the function
+// is the source idx. The arguments can reference the columns of the
+// sources before idx. The functions without column references are
+// evaluated and their column types are resolved from the generated
+// values.
+func (tf *TableFunction) bind(iql *Query, idx int) error {
+	if len(tf.Arguments) < tf.Function.MinArgs {
+		return fmt.Errorf("%s: too few arguments: got %d, expected %d",
+			tf.Name, len(tf.Arguments), tf.Function.MinArgs)
+	}
+	if len(tf.Arguments) > tf.Function.MaxArgs {
+		return fmt.Errorf("%s: too many arguments: got %d, expected %d",
+			tf.Name, len(tf.Arguments), tf.Function.MaxArgs)
+	}
+	tf.columns = nil
+	tf.lateral = false
+	for _, arg := range tf.Arguments {
+		if err := arg.Bind(iql); err != nil {
+			return fmt.Errorf("%s: %s", tf.Name, err)
+		}
+		sources, err := iql.sourcesOf(arg)
+		if err != nil {
+			return err
+		}
+		if len(sources) > 0 {
+			tf.lateral = true
+		}
+	}
+	if tf.lateral {
+		switch iql.From[idx].Join {
+		case JoinRight, JoinFull:
+			return fmt.Errorf("%s: lateral function can't be %s joined",
+				tf.Name, iql.From[idx].Join)
+		}
+	}
+
+	for _, name := range tf.Function.Columns {
+		tf.columns = append(tf.columns, types.ColumnSelector{
+			Name: types.Reference{
+				Column: name,
+			},
+		})
+	}
+	if tf.lateral {
+		for i, t := range tf.Function.Types(tf.Arguments) {
+			tf.columns[i].Type = t
+		}
+		return nil
+	}
+
+	var err error
+	tf.rows, err = tf.eval(&Row{}, true)
+	return err
+}
+
+// eval evaluates the function for the row. If resolve is true, the
+// column types are resolved from the generated values.
+func (tf *TableFunction) eval(row *Row, resolve bool) ([]types.Row, error) {
+	values, err := tf.Function.Impl(tf.Arguments, row)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", tf.Name, err)
+	}
+	var rows []types.Row
+	for _, vals := range values {
+		var r types.Row
+		for i, val := range vals {
+			if val == types.Null {
+				r = append(r, types.NullColumn{})
+			} else {
+				r = append(r, types.NewValueColumn(val))
+				if resolve {
+					tf.columns[i].ResolveValue(val)
+				}
+			}
+		}
+		rows = append(rows, r)
+	}
+	return rows, nil
+}
+
+// argType returns the static type of the argument expression.
+func argType(arg Expr) types.Type {
+	switch a := arg.(type) {
+	case *Constant:
+		return a.Value.Type()
+	case *Reference:
+		if a.binding != nil {
+			return a.binding.Type
+		}
+		return a.index.Type
+	case *Cast:
+		return a.Type
+	default:
+		return types.Any
+	}
+}
+
+func typesGenerateSeries(args []Expr) []types.Type {
+	if argType(args[0]) == types.Date {
+		return []types.Type{types.Date}
+	}
+	return []types.Type{types.Int}
+}
+
+func typesStringIndex(args []Expr) []types.Type {
+	return []types.Type{types.String, types.Int}
+}
+
+func typesUnnest(args []Expr) []types.Type {
+	return []types.Type{elemType(args[0]), types.Int}
+}
+
+// elemType returns the static type of the elements of the array
+// argument expression. The values of other types are unnested as
+// themselves. The arrays whose element types are not known are
+// unnested as strings.
+func elemType(arg Expr) types.Type {
+	var val types.Value
+	switch a := arg.(type) {
+	case *Constant:
+		val = a.Value
+	case *Reference:
+		if a.binding != nil {
+			val = a.binding.Value
+		} else if a.index.Type == types.Array {
+			return a.index.ElemType
+		}
+	}
+	if arr, ok := val.(types.ArrayValue); ok {
+		return arr.ElemType
+	}
+	switch t := argType(arg); t {
+	case types.Array, types.Table, types.Any:
+		return types.String
+	default:
+		return t
+	}
+}
+
+func tableGenerateSeries(args []Expr, row *Row) ([][]types.Value, error) {
+	var vals []types.Value
+	for _, arg := range args {
+		val, err := arg.Eval(row, nil)
+		if err != nil {
+			return nil, err
+		}
+		if val == types.Null {
+			return nil, nil
+		}
+		vals = append(vals, val)
+	}
+	start, startDate := seriesDate(vals[0])
+	stop, stopDate := seriesDate(vals[1])
+	if startDate || stopDate {
+		if !startDate || !stopDate {
+			return nil, fmt.Errorf("invalid datetime range: %s, %s",
+				vals[0], vals[1])
+		}
+		step := "1 day"
+		if len(vals) > 2 {
+			step = vals[2].String()
+		}
+		return dateSeries(start, stop, step)
+	}
+
+	from, err := vals[0].Int()
+	if err != nil {
+		return nil, err
+	}
+	to, err := vals[1].Int()
+	if err != nil {
+		return nil, err
+	}
+	var step int64 = 1
+	if len(vals) > 2 {
+		step, err = vals[2].Int()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if step == 0 {
+		return nil, fmt.Errorf("step can't be zero")
+	}
+	var result [][]types.Value
+	for i := from; (step > 0 && i <= to) || (step < 0 && i >= to); i += step {
+		result = append(result, []types.Value{types.IntValue(i)})
+		if (step > 0 && i > to-step) || (step < 0 && i < to-step) {
+			// The next value would overflow or pass the stop value.
+			break
+		}
+	}
+	return result, nil
+}
+
+// seriesDate tests if the value is a datetime value or a string that
+// can be parsed as a datetime value.
+func seriesDate(val types.Value) (time.Time, bool) {
+	switch val.(type) {
+	case types.DateValue:
+		d, err := val.Date()
+		return d, err == nil
+	case types.StringValue:
+		d, err := types.ParseDate(val.String())
+		return d, err == nil
+	default:
+		return time.Time{}, false
+	}
+}
+
+// dateSeries generates the datetime values from start to stop with
+// the step interval.
+func dateSeries(start, stop time.Time, step string) ([][]types.Value, error) {
+	years, months, days, duration, err := parseInterval(step)
+	if err != nil {
+		return nil, err
+	}
+	next := func(i int) time.Time {
+		return addInterval(start, i*years, i*months, i*days,
+			time.Duration(i)*duration)
+	}
+	forward := next(1).After(start)
+	if !forward && !next(1).Before(start) {
+		return nil, fmt.Errorf("step can't be zero: %s", step)
+	}
+	var result [][]types.Value
+	for i := 0; ; i++ {
+		t := next(i)
+		if (forward && t.After(stop)) || (!forward && t.Before(stop)) {
+			break
+		}
+		result = append(result, []types.Value{types.DateValue(t)})
+	}
+	return result, nil
+}
+
+// addInterval adds the interval to the time t. Unlike
+// time.Time.AddDate, the month and year intervals are clamped to the
+// last day of the resulting month.
+func addInterval(t time.Time, years, months, days int,
+	duration time.Duration) time.Time {
+
+	y, m, _ := t.Date()
+	first := time.Date(y+years, m+time.Month(months), 1, 0, 0, 0, 0,
+		t.Location())
+	day := t.Day()
+	last := first.AddDate(0, 1, -1).Day()
+	if day > last {
+		day = last
+	}
+	hour, min, sec := t.Clock()
+	return time.Date(first.Year(), first.Month(), day+days, hour, min, sec,
+		t.Nanosecond(), t.Location()).Add(duration)
+}
+
+// parseInterval parses the interval value. The interval is an
+// integer followed by the unit year, month, week, day, hour, minute,
+// or second, for example '1 day' or '-2 weeks', or a duration value,
+// for example '1h30m'.
+func parseInterval(val string) (years, months, days int,
+	duration time.Duration, err error) {
+
+	parts := strings.Fields(val)
+	if len(parts) == 2 {
+		n, aerr := strconv.Atoi(parts[0])
+		if aerr != nil {
+			return 0, 0, 0, 0, fmt.Errorf("invalid interval: %s", val)
+		}
+		switch strings.TrimSuffix(strings.ToLower(parts[1]), "s") {
+		case "year":
+			years = n
+		case "month":
+			months = n
+		case "week":
+			days = 7 * n
+		case "day":
+			days = n
+		case "hour":
+			duration = time.Duration(n) * time.Hour
+		case "minute":
+			duration = time.Duration(n) * time.Minute
+		case "second":
+			duration = time.Duration(n) * time.Second
+		default:
+			return 0, 0, 0, 0, fmt.Errorf("invalid interval unit: %s", val)
+		}
+		return
+	}
+	duration, err = time.ParseDuration(val)
+	if err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("invalid interval: %s", val)
+	}
+	return
+}
+
+func tableSplitToTable(args []Expr, row *Row) ([][]types.Value, error) {
+	strVal, err := args[0].Eval(row, nil)
+	if err != nil {
+		return nil, err
+	}
+	sepVal, err := args[1].Eval(row, nil)
+	if err != nil {
+		return nil, err
+	}
+	if strVal == types.Null || sepVal == types.Null {
+		return nil, nil
+	}
+	str := strVal.String()
+	sep := sepVal.String()
+
+	var parts []string
+	if len(sep) == 0 {
+		parts = []string{str}
+	} else {
+		parts = strings.Split(str, sep)
+	}
+	var result [][]types.Value
+	for idx, part := range parts {
+		result = append(result, []types.Value{
+			types.StringValue(part),
+			types.IntValue(idx + 1),
+		})
+	}
+	return result, nil
+}
+
+func tableUnnest(args []Expr, row *Row) ([][]types.Value, error) {
+	var elements []types.Value
+
+	// The multi-valued columns of HTML and XML sources are expanded
+	// from the source column.
+	ref, ok := args[0].(*Reference)
+	if ok && ref.binding == nil && ref.bound {
+		switch col := row.Data[ref.index.Source][ref.index.Column].(type) {
+		case types.StringsColumn:
+			for _, s := range col {
+				elements = append(elements, types.StringValue(s))
+			}
+			return indexed(elements), nil
+		}
+	}
+
+	val, err := args[0].Eval(row, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch v := val.(type) {
+	case types.NullValue:
+	case types.ArrayValue:
+		elements = v.Data
+	default:
+		elements = append(elements, val)
+	}
+	return indexed(elements), nil
+}
+
+// indexed returns the rows of the values and their 1-based indices.
+func indexed(values []types.Value) [][]types.Value {
+	var result [][]types.Value
+	for idx, val := range values {
+		result = append(result, []types.Value{val, types.IntValue(idx + 1)})
+	}
+	return result
+}
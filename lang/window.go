@@ -0,0 +1,708 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package lang
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/markkurossi/iql/types"
+)
+
+// Window implements window function calls. The window functions are
+// evaluated after grouping and HAVING for the rows of the query
+// result. The rows are partitioned by the PARTITION BY expressions
+// and each partition is ordered by the ORDER BY expressions. The
+// ranking and offset functions are computed over the partition and
+// the value and aggregate functions over the window frame of the
+// row.
+type Window struct {
+	Name        string
+	Arguments   []Expr
+	Function    *WindowFunction
+	PartitionBy []Expr
+	OrderBy     []Order
+	Frame       *Frame
+	args        []map[*Row]types.Value
+	order       map[*Row][]types.Value
+	values      map[*Row]types.Value
+}
+
+// WindowFunction defines a window function.
+type WindowFunction struct {
+	Name    string
+	Impl    WindowFunctionImpl
+	MinArgs int
+	MaxArgs int
+}
+
+// WindowFunctionImpl implements the window functions. The function
+// returns the values for the rows of the ordered partition.
+type WindowFunctionImpl func(w *Window, rows []*Row) ([]types.Value, error)
+
+// Frame defines the ROWS window frame relative to the current row.
+type Frame struct {
+	Start FrameBound
+	End   FrameBound
+}
+
+func (f *Frame) String() string {
+	return fmt.Sprintf("ROWS BETWEEN %s AND %s", f.Start, f.End)
+}
+
+// FrameBound defines a window frame bound.
+type FrameBound struct {
+	Type   BoundType
+	Offset int
+}
+
+func (b FrameBound) String() string {
+	switch b.Type {
+	case BoundPreceding, BoundFollowing:
+		return fmt.Sprintf("%d %s", b.Offset, b.Type)
+	default:
+		return b.Type.String()
+	}
+}
+
+// index returns the partition index of the bound for the row pos.
+func (b FrameBound) index(pos, count int) int {
+	switch b.Type {
+	case BoundUnboundedPreceding:
+		return 0
+	case BoundPreceding:
+		return pos - b.Offset
+	case BoundCurrentRow:
+		return pos
+	case BoundFollowing:
+		return pos + b.Offset
+	default:
+		return count - 1
+	}
+}
+
+// BoundType specifies the window frame bound types.
+type BoundType int
+
+// Window frame bound types.
+const (
+	BoundUnboundedPreceding BoundType = iota
+	BoundPreceding
+	BoundCurrentRow
+	BoundFollowing
+	BoundUnboundedFollowing
+)
+
+var boundTypes = map[BoundType]string{
+	BoundUnboundedPreceding: "UNBOUNDED PRECEDING",
+	BoundPreceding:          "PRECEDING",
+	BoundCurrentRow:         "CURRENT ROW",
+	BoundFollowing:          "FOLLOWING",
+	BoundUnboundedFollowing: "UNBOUNDED FOLLOWING",
+}
+
+func (t BoundType) String() string {
+	name, ok := boundTypes[t]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{BoundType %d}", t)
+}
+
+var windowFunctions = []WindowFunction{
+	// Ranking functions.
+	{
+		Name: "ROW_NUMBER",
+		Impl: windowRowNumber,
+	},
+	{
+		Name: "RANK",
+		Impl: windowRank,
+	},
+	{
+		Name: "DENSE_RANK",
+		Impl: windowDenseRank,
+	},
+
+	// Offset functions.
+	{
+		Name:    "LAG",
+		Impl:    windowLag,
+		MinArgs: 1,
+		MaxArgs: 3,
+	},
+	{
+		Name:    "LEAD",
+		Impl:    windowLead,
+		MinArgs: 1,
+		MaxArgs: 3,
+	},
+
+	// Value functions.
+	{
+		Name:    "FIRST_VALUE",
+		Impl:    windowFirstValue,
+		MinArgs: 1,
+		MaxArgs: 1,
+	},
+	{
+		Name:    "LAST_VALUE",
+		Impl:    windowLastValue,
+		MinArgs: 1,
+		MaxArgs: 1,
+	},
+
+	// Aggregate functions.
+	{
+		Name:    "AVG",
+		Impl:    windowAggregate,
+		MinArgs: 1,
+		MaxArgs: 1,
+	},
+	{
+		Name:    "COUNT",
+		Impl:    windowAggregate,
+		MinArgs: 1,
+		MaxArgs: 1,
+	},
+	{
+		Name:    "MAX",
+		Impl:    windowAggregate,
+		MinArgs: 1,
+		MaxArgs: 1,
+	},
+	{
+		Name:    "MIN",
+		Impl:    windowAggregate,
+		MinArgs: 1,
+		MaxArgs: 1,
+	},
+	{
+		Name:    "SUM",
+		Impl:    windowAggregate,
+		MinArgs: 1,
+		MaxArgs: 1,
+	},
+}
+
+var windowFunctionsByName map[string]*WindowFunction
+
+func init() {
+	windowFunctionsByName = make(map[string]*WindowFunction)
+	for idx, wf := range windowFunctions {
+		windowFunctionsByName[wf.Name] = &windowFunctions[idx]
+	}
+}
+
+func windowFunction(name string) *WindowFunction {
+	return windowFunctionsByName[name]
+}
+
+// Bind implements the Expr.Bind().
+func (w *Window) Bind(iql *Query) error {
+	for _, arg := range w.Arguments {
+		if err := arg.Bind(iql); err != nil {
+			return err
+		}
+	}
+	for _, expr := range w.PartitionBy {
+		if err := expr.Bind(iql); err != nil {
+			return err
+		}
+	}
+	for _, order := range w.OrderBy {
+		if err := order.Expr.Bind(iql); err != nil {
+			return err
+		}
+	}
+	iql.windows = append(iql.windows, w)
+	return nil
+}
+
+// Eval implements the Expr.Eval().
+func (w *Window) Eval(row *Row, rows []*Row) (types.Value, error) {
+	val, ok := w.values[row]
+	if !ok {
+		return nil, fmt.Errorf("%s: window function used outside SELECT",
+			w.Name)
+	}
+	return val, nil
+}
+
+// IsIdempotent implements the Expr.IsIdempotent().
+func (w *Window) IsIdempotent() bool {
+	return false
+}
+
+func (w *Window) String() string {
+	var parts []string
+	if len(w.PartitionBy) > 0 {
+		parts = append(parts, "PARTITION BY "+exprList(w.PartitionBy))
+	}
+	if len(w.OrderBy) > 0 {
+		var orders []string
+		for _, order := range w.OrderBy {
+			if order.Desc {
+				orders = append(orders, fmt.Sprintf("%s DESC", order.Expr))
+			} else {
+				orders = append(orders, order.Expr.String())
+			}
+		}
+		parts = append(parts, "ORDER BY "+strings.Join(orders, ", "))
+	}
+	if w.Frame != nil {
+		parts = append(parts, w.Frame.String())
+	}
+	return fmt.Sprintf("%s(%s) OVER (%s)", w.Name, exprList(w.Arguments),
+		strings.Join(parts, " "))
+}
+
+// References implements the Expr.References().
+func (w *Window) References() (result []types.Reference) {
+	for _, arg := range w.Arguments {
+		result = append(result, arg.References()...)
+	}
+	for _, expr := range w.PartitionBy {
+		result = append(result, expr.References()...)
+	}
+	for _, order := range w.OrderBy {
+		result = append(result, order.Expr.References()...)
+	}
+	return result
+}
+
+// groupRow holds a row of the query result and the group of rows it
+// represents.
+type groupRow struct {
+	row   *Row
+	group []*Row
+}
+
+// eval computes the window function values for the result rows.
+func (w *Window) eval(rows []groupRow) error {
+	w.args = make([]map[*Row]types.Value, len(w.Arguments))
+	for i := range w.args {
+		w.args[i] = make(map[*Row]types.Value)
+	}
+	w.order = make(map[*Row][]types.Value)
+	w.values = make(map[*Row]types.Value)
+
+	// Evaluate the arguments, partitions, and order keys of the rows.
+	partitions := NewGrouping()
+	for _, r := range rows {
+		for i, arg := range w.Arguments {
+			val, err := arg.Eval(r.row, r.group)
+			if err != nil {
+				return err
+			}
+			w.args[i][r.row] = val
+		}
+		var key []types.Value
+		for _, expr := range w.PartitionBy {
+			val, err := expr.Eval(r.row, r.group)
+			if err != nil {
+				return err
+			}
+			key = append(key, val)
+		}
+		var order []types.Value
+		for _, o := range w.OrderBy {
+			val, err := o.Expr.Eval(r.row, r.group)
+			if err != nil {
+				return err
+			}
+			order = append(order, val)
+		}
+		w.order[r.row] = order
+		partitions.Add(key, r.row)
+	}
+
+	for _, partition := range partitions.Get() {
+		var sortErr error
+		sort.SliceStable(partition, func(i, j int) bool {
+			cmp, err := w.compare(partition[i], partition[j])
+			if err != nil {
+				sortErr = err
+			}
+			return cmp < 0
+		})
+		if sortErr != nil {
+			return sortErr
+		}
+		values, err := w.Function.Impl(w, partition)
+		if err != nil {
+			return fmt.Errorf("%s: %s", w.Name, err)
+		}
+		for pos, row := range partition {
+			w.values[row] = values[pos]
+		}
+	}
+	return nil
+}
+
+// compare compares the order keys of the rows.
+func (w *Window) compare(a, b *Row) (int, error) {
+	o1 := w.order[a]
+	o2 := w.order[b]
+	for idx, order := range w.OrderBy {
+		cmp, err := types.Compare(o1[idx], o2[idx])
+		if err != nil {
+			return 0, err
+		}
+		if cmp == 0 {
+			continue
+		}
+		if order.Desc {
+			return -cmp, nil
+		}
+		return cmp, nil
+	}
+	return 0, nil
+}
+
+// peers returns the partition indices of the first and last peers of
+// the rows. The peers are the rows whose order keys are equal.
+func (w *Window) peers(rows []*Row) (first, last []int, err error) {
+	first = make([]int, len(rows))
+	last = make([]int, len(rows))
+	for pos := 1; pos < len(rows); pos++ {
+		cmp, err := w.compare(rows[pos-1], rows[pos])
+		if err != nil {
+			return nil, nil, err
+		}
+		if cmp == 0 {
+			first[pos] = first[pos-1]
+		} else {
+			first[pos] = pos
+		}
+	}
+	for pos := len(rows) - 1; pos >= 0; pos-- {
+		if pos+1 < len(rows) && first[pos+1] == first[pos] {
+			last[pos] = last[pos+1]
+		} else {
+			last[pos] = pos
+		}
+	}
+	return first, last, nil
+}
+
+// frames returns the partition indices of the first and last rows of
+// the window frames of the rows. The frame is empty if its first
+// index is greater than its last index. Both indices are
+// non-decreasing in the row order so the frames slide over the
+// partition.
+func (w *Window) frames(rows []*Row) (from, to []int, err error) {
+	from = make([]int, len(rows))
+	to = make([]int, len(rows))
+
+	var last []int
+	if w.Frame == nil && len(w.OrderBy) > 0 {
+		// The default frame extends from the start of the partition
+		// to the last peer of the current row.
+		_, last, err = w.peers(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	for pos := range rows {
+		if w.Frame != nil {
+			from[pos] = w.Frame.Start.index(pos, len(rows))
+			to[pos] = w.Frame.End.index(pos, len(rows))
+		} else if last != nil {
+			to[pos] = last[pos]
+		} else {
+			to[pos] = len(rows) - 1
+		}
+		if from[pos] < 0 {
+			from[pos] = 0
+		}
+		if to[pos] >= len(rows) {
+			to[pos] = len(rows) - 1
+		}
+	}
+	return from, to, nil
+}
+
+func windowRowNumber(w *Window, rows []*Row) ([]types.Value, error) {
+	result := make([]types.Value, len(rows))
+	for pos := range rows {
+		result[pos] = types.IntValue(pos + 1)
+	}
+	return result, nil
+}
+
+func windowRank(w *Window, rows []*Row) ([]types.Value, error) {
+	first, _, err := w.peers(rows)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]types.Value, len(rows))
+	for pos := range rows {
+		result[pos] = types.IntValue(first[pos] + 1)
+	}
+	return result, nil
+}
+
+func windowDenseRank(w *Window, rows []*Row) ([]types.Value, error) {
+	first, _, err := w.peers(rows)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]types.Value, len(rows))
+	var rank int
+	for pos := range rows {
+		if first[pos] == pos {
+			rank++
+		}
+		result[pos] = types.IntValue(rank)
+	}
+	return result, nil
+}
+
+func windowLag(w *Window, rows []*Row) ([]types.Value, error) {
+	return windowOffset(w, rows, -1)
+}
+
+func windowLead(w *Window, rows []*Row) ([]types.Value, error) {
+	return windowOffset(w, rows, 1)
+}
+
+// windowOffset returns the values of the rows that are the offset
+// argument rows from the rows in the direction dir. If the row is
+// outside the partition, the function returns the default argument.
+func windowOffset(w *Window, rows []*Row, dir int) ([]types.Value, error) {
+	result := make([]types.Value, len(rows))
+	for pos, row := range rows {
+		offset := 1
+		if len(w.args) > 1 {
+			val := w.args[1][row]
+			if val == types.Null {
+				result[pos] = types.Null
+				continue
+			}
+			i, err := val.Int()
+			if err != nil {
+				return nil, err
+			}
+			if i < 0 {
+				return nil, fmt.Errorf("negative offset: %d", i)
+			}
+			offset = Int64ToInt(i)
+		}
+		idx := pos + dir*offset
+		if idx < 0 || idx >= len(rows) {
+			if len(w.args) > 2 {
+				result[pos] = w.args[2][row]
+			} else {
+				result[pos] = types.Null
+			}
+			continue
+		}
+		result[pos] = w.args[0][rows[idx]]
+	}
+	return result, nil
+}
+
+func windowFirstValue(w *Window, rows []*Row) ([]types.Value, error) {
+	from, to, err := w.frames(rows)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]types.Value, len(rows))
+	for pos := range rows {
+		if from[pos] > to[pos] {
+			result[pos] = types.Null
+		} else {
+			result[pos] = w.args[0][rows[from[pos]]]
+		}
+	}
+	return result, nil
+}
+
+func windowLastValue(w *Window, rows []*Row) ([]types.Value, error) {
+	from, to, err := w.frames(rows)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]types.Value, len(rows))
+	for pos := range rows {
+		if from[pos] > to[pos] {
+			result[pos] = types.Null
+		} else {
+			result[pos] = w.args[0][rows[to[pos]]]
+		}
+	}
+	return result, nil
+}
+
+// windowAggregate computes the aggregate function over the window
+// frames of the rows. The aggregates are maintained incrementally as
+// the frames slide over the partition. The results match the
+// aggregate functions of the GROUP BY queries.
+func windowAggregate(w *Window, rows []*Row) ([]types.Value, error) {
+	from, to, err := w.frames(rows)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]types.Value, len(rows))
+	for pos, row := range rows {
+		values[pos] = w.args[0][row]
+		switch values[pos].(type) {
+		case types.NullValue, types.IntValue, types.FloatValue:
+		default:
+			if w.Name != "COUNT" {
+				return nil, fmt.Errorf("%s over %T", w.Name, values[pos])
+			}
+		}
+	}
+	switch w.Name {
+	case "MIN", "MAX":
+		return windowMinMax(w.Name == "MAX", values, from, to), nil
+	default:
+		return windowSum(w.Name, values, from, to), nil
+	}
+}
+
+// windowSum computes the COUNT, SUM, and AVG aggregates from the
+// prefix sums of the values.
+func windowSum(name string, values []types.Value, from, to []int) []types.Value {
+	// The prefix sums of the values before the index.
+	counts := make([]int, len(values)+1)
+	ints := make([]int, len(values)+1)
+	floats := make([]int, len(values)+1)
+	intSums := make([]int64, len(values)+1)
+	floatSums := make([]float64, len(values)+1)
+
+	for i, val := range values {
+		counts[i+1] = counts[i]
+		ints[i+1] = ints[i]
+		floats[i+1] = floats[i]
+		intSums[i+1] = intSums[i]
+		floatSums[i+1] = floatSums[i]
+
+		switch v := val.(type) {
+		case types.NullValue:
+			continue
+		case types.IntValue:
+			ints[i+1]++
+			intSums[i+1] += int64(v)
+		case types.FloatValue:
+			floats[i+1]++
+			floatSums[i+1] += float64(v)
+		}
+		counts[i+1]++
+	}
+
+	result := make([]types.Value, len(values))
+	for pos := range values {
+		start, end := from[pos], to[pos]+1
+		if start >= end {
+			if name == "COUNT" {
+				result[pos] = types.IntValue(0)
+			} else {
+				result[pos] = types.Null
+			}
+			continue
+		}
+		numInts := ints[end] - ints[start]
+		numFloats := floats[end] - floats[start]
+		intSum := intSums[end] - intSums[start]
+		floatSum := floatSums[end] - floatSums[start]
+
+		switch name {
+		case "COUNT":
+			result[pos] = types.IntValue(counts[end] - counts[start])
+
+		case "SUM":
+			if numFloats > 0 && numInts > 0 {
+				result[pos] = types.FloatValue(floatSum + float64(intSum))
+			} else if numFloats > 0 {
+				result[pos] = types.FloatValue(floatSum)
+			} else {
+				result[pos] = types.IntValue(intSum)
+			}
+
+		case "AVG":
+			if numFloats > 0 && numInts > 0 || numFloats+numInts == 0 {
+				result[pos] = types.Null
+			} else if numFloats > 0 {
+				result[pos] = types.FloatValue(floatSum / float64(numFloats))
+			} else {
+				result[pos] = types.IntValue(intSum / int64(numInts))
+			}
+		}
+	}
+	return result
+}
+
+// windowMinMax computes the MIN or MAX aggregates over the sliding
+// frames. The integer and float values are tracked separately with
+// monotonic queues of the frame values.
+func windowMinMax(max bool, values []types.Value, from, to []int) []types.Value {
+	// better tests if the value a is preferred over the value b.
+	better := func(a, b types.Value) bool {
+		switch av := a.(type) {
+		case types.IntValue:
+			bv := b.(types.IntValue)
+			return (max && av >= bv) || (!max && av <= bv)
+		case types.FloatValue:
+			bv := b.(types.FloatValue)
+			return (max && av >= bv) || (!max && av <= bv)
+		}
+		return false
+	}
+	var intQueue, floatQueue []int
+	push := func(queue []int, idx int) []int {
+		for len(queue) > 0 && better(values[idx], values[queue[len(queue)-1]]) {
+			queue = queue[:len(queue)-1]
+		}
+		return append(queue, idx)
+	}
+	expire := func(queue []int, start int) []int {
+		for len(queue) > 0 && queue[0] < start {
+			queue = queue[1:]
+		}
+		return queue
+	}
+
+	result := make([]types.Value, len(values))
+	next := 0
+	for pos := range values {
+		for ; next <= to[pos]; next++ {
+			switch values[next].(type) {
+			case types.IntValue:
+				intQueue = push(intQueue, next)
+			case types.FloatValue:
+				floatQueue = push(floatQueue, next)
+			}
+		}
+		intQueue = expire(intQueue, from[pos])
+		floatQueue = expire(floatQueue, from[pos])
+
+		if from[pos] > to[pos] {
+			result[pos] = types.Null
+			continue
+		}
+		switch {
+		case len(intQueue) > 0 && len(floatQueue) > 0:
+			i := float64(values[intQueue[0]].(types.IntValue))
+			f := float64(values[floatQueue[0]].(types.FloatValue))
+			if (max && i > f) || (!max && i < f) {
+				f = i
+			}
+			result[pos] = types.FloatValue(f)
+		case len(floatQueue) > 0:
+			result[pos] = values[floatQueue[0]]
+		case len(intQueue) > 0:
+			result[pos] = values[intQueue[0]]
+		default:
+			result[pos] = types.Null
+		}
+	}
+	return result
+}
@@ -0,0 +1,324 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package types
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Writer writes the source columns and rows to the output stream.
+type Writer func(out io.Writer, source Source) error
+
+// Writers define the output formats and their writers.
+var Writers = map[string]Writer{
+	"csv":      WriteCSV,
+	"json":     WriteJSON,
+	"ndjson":   WriteNDJSON,
+	"markdown": WriteMarkdown,
+	"html":     WriteHTML,
+}
+
+// WriterNames returns the names of the output formats.
+func WriterNames() []string {
+	var names []string
+	for name := range Writers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteCSV writes the source as comma-separated values. The first
+// line of the output contains the column names.
+func WriteCSV(out io.Writer, source Source) error {
+	rows, err := source.Get()
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(out)
+
+	var record []string
+	for _, col := range source.Columns() {
+		record = append(record, col.String())
+	}
+	if err := w.Write(record); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record = record[:0]
+		for _, col := range row {
+			record = append(record, columnString(col))
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// WriteJSON writes the source as a JSON array of objects. The object
+// keys are the column names.
+func WriteJSON(out io.Writer, source Source) error {
+	rows, err := source.Get()
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	columns := source.Columns()
+
+	w.WriteString("[")
+	for idx, row := range rows {
+		if idx > 0 {
+			w.WriteString(",")
+		}
+		w.WriteString("\n  ")
+		if err := writeJSONObject(w, columns, row); err != nil {
+			return err
+		}
+	}
+	if len(rows) > 0 {
+		w.WriteString("\n")
+	}
+	w.WriteString("]\n")
+	return w.Flush()
+}
+
+// WriteNDJSON writes the source as newline-delimited JSON objects,
+// one object for each row.
+func WriteNDJSON(out io.Writer, source Source) error {
+	rows, err := source.Get()
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	columns := source.Columns()
+
+	for _, row := range rows {
+		if err := writeJSONObject(w, columns, row); err != nil {
+			return err
+		}
+		w.WriteString("\n")
+	}
+	return w.Flush()
+}
+
+func writeJSONObject(w *bufio.Writer, columns []ColumnSelector,
+	row Row) error {
+
+	w.WriteString("{")
+	for idx, col := range row {
+		if idx > 0 {
+			w.WriteString(",")
+		}
+		if err := writeJSONString(w, columns[idx].String()); err != nil {
+			return err
+		}
+		w.WriteString(":")
+		if err := writeJSONValue(w, columns[idx].Type, col); err != nil {
+			return err
+		}
+	}
+	w.WriteString("}")
+	return nil
+}
+
+// writeJSONValue writes the column value as JSON. Boolean and numeric
+// columns are written as JSON booleans and numbers, array and record
+// columns as JSON arrays and objects, null columns as null, and all
+// other columns as strings.
+func writeJSONValue(w *bufio.Writer, t Type, col Column) error {
+	_, ok := col.(NullColumn)
+	if ok {
+		w.WriteString("null")
+		return nil
+	}
+	switch t {
+	case Bool:
+		v, err := col.Bool()
+		if err == nil {
+			b, err := v.Bool()
+			if err == nil {
+				w.WriteString(strconv.FormatBool(b))
+				return nil
+			}
+		}
+
+	case Int:
+		v, err := col.Int()
+		if err == nil {
+			i, err := v.Int()
+			if err == nil {
+				w.WriteString(strconv.FormatInt(i, 10))
+				return nil
+			}
+		}
+
+	case Float:
+		v, err := col.Float()
+		if err == nil {
+			f, err := v.Float()
+			if err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+				w.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+				return nil
+			}
+		}
+
+	case Array, Record:
+		vc, ok := col.(*ValueColumn)
+		if ok && vc.Value().Type() == t {
+			return writeJSONComposite(w, vc.Value())
+		}
+	}
+	return writeJSONString(w, col.String())
+}
+
+// writeJSONComposite writes the value as JSON. Array values are
+// written as JSON arrays and record values as JSON objects.
+func writeJSONComposite(w *bufio.Writer, val Value) error {
+	switch v := val.(type) {
+	case ArrayValue:
+		w.WriteString("[")
+		for idx, el := range v.Data {
+			if idx > 0 {
+				w.WriteString(",")
+			}
+			if err := writeJSONComposite(w, el); err != nil {
+				return err
+			}
+		}
+		w.WriteString("]")
+		return nil
+
+	case RecordValue:
+		w.WriteString("{")
+		for idx, key := range v.Keys() {
+			if idx > 0 {
+				w.WriteString(",")
+			}
+			if err := writeJSONString(w, key); err != nil {
+				return err
+			}
+			w.WriteString(":")
+			if err := writeJSONComposite(w, v.Data[key]); err != nil {
+				return err
+			}
+		}
+		w.WriteString("}")
+		return nil
+
+	case NullValue:
+		w.WriteString("null")
+		return nil
+
+	default:
+		return writeJSONValue(w, val.Type(), NewValueColumn(val))
+	}
+}
+
+func writeJSONString(w *bufio.Writer, val string) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	w.Write(data)
+	return nil
+}
+
+// WriteMarkdown writes the source as a Markdown table. The numeric
+// columns are aligned right.
+func WriteMarkdown(out io.Writer, source Source) error {
+	rows, err := source.Get()
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	columns := source.Columns()
+
+	w.WriteString("|")
+	for _, col := range columns {
+		w.WriteString(markdownEscape(col.String()))
+		w.WriteString("|")
+	}
+	w.WriteString("\n|")
+	for _, col := range columns {
+		switch col.Type {
+		case Int, Float:
+			w.WriteString("---:|")
+		default:
+			w.WriteString("---|")
+		}
+	}
+	w.WriteString("\n")
+	for _, row := range rows {
+		w.WriteString("|")
+		for _, col := range row {
+			w.WriteString(markdownEscape(columnString(col)))
+			w.WriteString("|")
+		}
+		w.WriteString("\n")
+	}
+	return w.Flush()
+}
+
+func markdownEscape(val string) string {
+	val = strings.ReplaceAll(val, "|", "\\|")
+	return strings.ReplaceAll(val, "\n", "<br>")
+}
+
+// WriteHTML writes the source as an HTML table. The numeric columns
+// are aligned right.
+func WriteHTML(out io.Writer, source Source) error {
+	rows, err := source.Get()
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	columns := source.Columns()
+
+	w.WriteString("<table>\n  <tr>\n")
+	for _, col := range columns {
+		w.WriteString("    <th>")
+		w.WriteString(html.EscapeString(col.String()))
+		w.WriteString("</th>\n")
+	}
+	w.WriteString("  </tr>\n")
+	for _, row := range rows {
+		w.WriteString("  <tr>\n")
+		for idx, col := range row {
+			switch columns[idx].Type {
+			case Int, Float:
+				w.WriteString("    <td align=\"right\">")
+			default:
+				w.WriteString("    <td>")
+			}
+			w.WriteString(html.EscapeString(columnString(col)))
+			w.WriteString("</td>\n")
+		}
+		w.WriteString("  </tr>\n")
+	}
+	w.WriteString("</table>\n")
+	return w.Flush()
+}
+
+// columnString returns the string representation of the column for
+// the text output formats. The null columns are empty strings.
+func columnString(col Column) string {
+	_, ok := col.(NullColumn)
+	if ok {
+		return ""
+	}
+	return col.String()
+}
@@ -0,0 +1,190 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package types
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Op defines predicate comparison operators.
+type Op int
+
+// Comparison operators.
+const (
+	OpEq Op = iota
+	OpNEq
+	OpLt
+	OpLe
+	OpGt
+	OpGe
+)
+
+var ops = map[Op]string{
+	OpEq:  "=",
+	OpNEq: "<>",
+	OpLt:  "<",
+	OpLe:  "<=",
+	OpGt:  ">",
+	OpGe:  ">=",
+}
+
+func (op Op) String() string {
+	name, ok := ops[op]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{Op %d}", op)
+}
+
+// Predicate defines a comparison between a source column and a
+// value.
+type Predicate struct {
+	Column int
+	Op     Op
+	Value  Value
+}
+
+func (p Predicate) String() string {
+	return fmt.Sprintf("$%d %s %s", p.Column, p.Op, p.Value)
+}
+
+// Filterable is implemented by sources that can evaluate predicates
+// natively while reading their input. The filterable sources must
+// resolve their columns when they are created so that the predicates
+// can be pushed into them before their rows are read.
+type Filterable interface {
+	// Filter adds the predicate to the source. The source must drop
+	// only rows for which the predicate does not hold. The function
+	// returns false if the source does not support the predicate.
+	Filter(pred Predicate) bool
+}
+
+// Match tests if the column value matches the predicate. The column
+// value is interpreted as the argument type. Null columns are equal
+// only to null values and they do not satisfy any ordering.
+func (p Predicate) Match(col Column, t Type) (bool, error) {
+	var val Value
+	var err error
+
+	_, ok := col.(NullColumn)
+	if ok {
+		val = Null
+	} else {
+		switch t {
+		case Bool:
+			val, err = col.Bool()
+		case Int:
+			val, err = col.Int()
+		case Float:
+			val, err = col.Float()
+		default:
+			val = StringValue(col.String())
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	_, lNull := val.(NullValue)
+	_, rNull := p.Value.(NullValue)
+	if lNull || rNull {
+		switch p.Op {
+		case OpEq:
+			return lNull && rNull, nil
+		case OpNEq:
+			return lNull != rNull, nil
+		default:
+			return false, nil
+		}
+	}
+
+	var cmp int
+	switch v := val.(type) {
+	case BoolValue:
+		r, err := p.Value.Bool()
+		if err != nil {
+			return false, err
+		}
+		if bool(v) == r {
+			cmp = 0
+		} else {
+			cmp = 1
+		}
+
+	case IntValue:
+		_, isFloat := p.Value.(FloatValue)
+		if isFloat {
+			cmp, err = compareFloat(float64(v), p.Value)
+		} else {
+			r, err := p.Value.Int()
+			if err != nil {
+				return false, err
+			}
+			cmp = compareInt(int64(v), r)
+		}
+
+	case FloatValue:
+		cmp, err = compareFloat(float64(v), p.Value)
+
+	default:
+		cmp = strings.Compare(val.String(), p.Value.String())
+	}
+	if err != nil {
+		if err == errUnordered {
+			// NaN values are not equal to anything.
+			return p.Op == OpNEq, nil
+		}
+		return false, err
+	}
+
+	switch p.Op {
+	case OpEq:
+		return cmp == 0, nil
+	case OpNEq:
+		return cmp != 0, nil
+	case OpLt:
+		return cmp < 0, nil
+	case OpLe:
+		return cmp <= 0, nil
+	case OpGt:
+		return cmp > 0, nil
+	case OpGe:
+		return cmp >= 0, nil
+	default:
+		return false, fmt.Errorf("invalid predicate operator: %s", p.Op)
+	}
+}
+
+func compareInt(l, r int64) int {
+	if l < r {
+		return -1
+	}
+	if l > r {
+		return 1
+	}
+	return 0
+}
+
+var errUnordered = fmt.Errorf("unordered values")
+
+func compareFloat(l float64, value Value) (int, error) {
+	r, err := value.Float()
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(l) || math.IsNaN(r) {
+		return 0, errUnordered
+	}
+	if l < r {
+		return -1, nil
+	}
+	if l > r {
+		return 1, nil
+	}
+	return 0, nil
+}
@@ -8,6 +8,7 @@ package types
 
 import (
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"unicode"
@@ -21,22 +22,87 @@ var (
 	_ Column = ValueColumn{}
 	_ Column = StringColumn("")
 	_ Column = StringsColumn([]string{})
+
+	_ RowIterator = &RowsIterator{}
 )
 
-// Source is an interface that defines data input sources.
+// Source is an interface that defines data input sources. The Rows
+// function returns an iterator that reads the source rows lazily. The
+// Get function returns all source rows and it is a compatibility
+// adapter for sources which must be materialized into memory.
 type Source interface {
 	Columns() []ColumnSelector
 	Get() ([]Row, error)
+	Rows() (RowIterator, error)
 }
 
 // Row defines an input data row.
 type Row []Column
 
-// ColumnSelector implements data column selector.
+// RowIterator implements iteration over data source rows.
+type RowIterator interface {
+	// Next returns the next row. The function returns io.EOF when
+	// all rows have been read.
+	Next() (Row, error)
+	// Close closes the iterator and releases all resources
+	// associated with it.
+	Close() error
+}
+
+// ReadAll reads all remaining rows from the iterator and closes the
+// iterator.
+func ReadAll(it RowIterator) ([]Row, error) {
+	defer it.Close()
+
+	var result []Row
+	for {
+		row, err := it.Next()
+		if err != nil {
+			if err == io.EOF {
+				return result, nil
+			}
+			return nil, err
+		}
+		result = append(result, row)
+	}
+}
+
+// RowsIterator implements RowIterator over in-memory rows.
+type RowsIterator struct {
+	rows []Row
+	next int
+}
+
+// NewRowsIterator creates a new iterator over the argument rows.
+func NewRowsIterator(rows []Row) *RowsIterator {
+	return &RowsIterator{
+		rows: rows,
+	}
+}
+
+// Next implements the RowIterator.Next().
+func (it *RowsIterator) Next() (Row, error) {
+	if it.next >= len(it.rows) {
+		return nil, io.EOF
+	}
+	row := it.rows[it.next]
+	it.next++
+	return row, nil
+}
+
+// Close implements the RowIterator.Close().
+func (it *RowsIterator) Close() error {
+	it.next = len(it.rows)
+	return nil
+}
+
+// ColumnSelector implements data column selector. The ElemType
+// specifies the element type of the Array columns.
 type ColumnSelector struct {
-	Name Reference
-	As   string
-	Type Type
+	Name     Reference
+	As       string
+	Type     Type
+	ElemType Type
 }
 
 // IsPublic reports if the column is public and should be included in
@@ -60,9 +126,18 @@ func (col *ColumnSelector) ResolveValue(val Value) {
 	if t > col.Type {
 		col.Type = t
 	}
-	if col.Type > String {
+	switch col.Type {
+	case Table, Any:
 		col.Type = String
 	}
+	arr, ok := val.(ArrayValue)
+	if ok && arr.ElemType > col.ElemType {
+		col.ElemType = arr.ElemType
+		switch col.ElemType {
+		case Table, Any:
+			col.ElemType = String
+		}
+	}
 }
 
 // ResolveString resolves the column type based on the argument column
@@ -224,6 +299,11 @@ func (c ValueColumn) String() string {
 	return c.v.String()
 }
 
+// Value returns the column value.
+func (c ValueColumn) Value() Value {
+	return c.v
+}
+
 // StringColumn implements a string column.
 type StringColumn string
 
@@ -318,12 +398,7 @@ func Tabulate(source Source, style tabulate.Style) (*tabulate.Tabulate, error) {
 	for _, columns := range rows {
 		row := tab.Row()
 		for _, col := range columns {
-			_, ok := col.(NullColumn)
-			if ok {
-				row.Column("")
-			} else {
-				row.Column(col.String())
-			}
+			row.Column(columnString(col))
 		}
 	}
 	return tab, nil
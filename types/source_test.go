@@ -0,0 +1,42 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package types
+
+import (
+	"io"
+	"testing"
+)
+
+func TestRowsIterator(t *testing.T) {
+	it := NewRowsIterator([]Row{
+		{StringColumn("a")},
+		{StringColumn("b")},
+	})
+	rows, err := ReadAll(it)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %s", err)
+	}
+	if len(rows) != 2 {
+		t.Errorf("got %d rows, expected 2", len(rows))
+	}
+	_, err = it.Next()
+	if err != io.EOF {
+		t.Errorf("closed iterator returned %v, expected EOF", err)
+	}
+}
+
+func TestResolveValue(t *testing.T) {
+	var col ColumnSelector
+	col.ResolveValue(NewArray(Int, []Value{IntValue(1)}))
+	col.ResolveValue(Null)
+	col.ResolveValue(NewArray(Float, []Value{FloatValue(1.5)}))
+	col.ResolveValue(NewArray(Bool, nil))
+	if col.Type != Array || col.ElemType != Float {
+		t.Errorf("got %s of %s, expected %s of %s",
+			col.Type, col.ElemType, Array, Float)
+	}
+}
@@ -27,6 +27,7 @@ const (
 	String
 	Table
 	Array
+	Record
 	Any
 )
 
@@ -85,6 +86,7 @@ var types = map[Type]string{
 	String: "varchar",
 	Table:  "table",
 	Array:  "array",
+	Record: "record",
 }
 
 func (t Type) String() string {
@@ -97,10 +99,12 @@ func (t Type) String() string {
 
 // Align returns the type specific column alignment type.
 func (t Type) Align() tabulate.Align {
-	if t == String {
+	switch t {
+	case String, Array, Record:
 		return tabulate.ML
+	default:
+		return tabulate.MR
 	}
-	return tabulate.MR
 }
 
 // CanAssign tests if the argument value can be assigned into a
@@ -121,6 +125,8 @@ func (t Type) CanAssign(v Value) bool {
 		return t == Table
 	case ArrayValue:
 		return t == Array
+	case RecordValue:
+		return t == Record
 	case NullValue:
 		return true
 	default:
@@ -9,6 +9,7 @@ package types
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -22,6 +23,7 @@ var (
 	_ Value = StringValue("")
 	_ Value = TableValue{}
 	_ Value = ArrayValue{}
+	_ Value = RecordValue{}
 	_ Value = &FormattedValue{}
 
 	// Null value specifies a non-existing value.
@@ -411,6 +413,66 @@ func (v ArrayValue) String() string {
 	return fmt.Sprintf("%v", v.Data)
 }
 
+// RecordValue implements records with named fields.
+type RecordValue struct {
+	Data map[string]Value
+}
+
+// NewRecord creates a new record value with the data.
+func NewRecord(data map[string]Value) Value {
+	return RecordValue{
+		Data: data,
+	}
+}
+
+// Type implements the Value.Type().
+func (v RecordValue) Type() Type {
+	return Record
+}
+
+// Date implements the Value.Date().
+func (v RecordValue) Date() (time.Time, error) {
+	return time.Time{}, fmt.Errorf("record used as date")
+}
+
+// Bool implements the Value.Bool().
+func (v RecordValue) Bool() (bool, error) {
+	return false, fmt.Errorf("record used as bool")
+}
+
+// Int implements the Value.Int().
+func (v RecordValue) Int() (int64, error) {
+	return int64(len(v.Data)), nil
+}
+
+// Float implements the Value.Float().
+func (v RecordValue) Float() (float64, error) {
+	return 0, fmt.Errorf("record used as float")
+}
+
+// Keys returns the record field names in sorted order.
+func (v RecordValue) Keys() []string {
+	var keys []string
+	for key := range v.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (v RecordValue) String() string {
+	var sb strings.Builder
+	sb.WriteRune('{')
+	for idx, key := range v.Keys() {
+		if idx > 0 {
+			sb.WriteRune(' ')
+		}
+		sb.WriteString(fmt.Sprintf("%s:%v", key, v.Data[key]))
+	}
+	sb.WriteRune('}')
+	return sb.String()
+}
+
 // NullValue implements non-existing value.
 type NullValue struct {
 }